@@ -0,0 +1,352 @@
+// Command qwak-predict sends feature vectors to a Qwak model and writes its predictions.
+//
+// Feature vectors are read from a csv file with a header row, from a json array of objects,
+// or from json objects one per line, e.g.
+//
+//	qwak-predict -model churn -environment prod -input vectors.csv
+//	echo '{"State": "PPP", "Age": 42}' | qwak-predict -model churn -environment prod -output-format csv
+//	qwak-predict -model churn -local http://localhost:5000 -input vectors.json
+//
+// The api key is read from -api-key, the QWAK_API_KEY environment variable, or the credentials file
+// of the qwak cli. It is also a working example of the SDK: see run for the client configuration.
+package main
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"encoding/csv"
+	"encoding/json"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"os/signal"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/qwak-ai/go-sdk/qwak"
+	"github.com/qwak-ai/go-sdk/qwak/http"
+)
+
+const (
+	formatCSV  = "csv"
+	formatJSON = "json"
+)
+
+// retryPolicies the retry policies selected with -retry
+var retryPolicies = map[string]func() http.RetryPolicy{
+	"none":              func() http.RetryPolicy { return http.RetryPolicy{} },
+	"basic":             http.BasicExponentialBackoffRetryPolicy,
+	"aggressive":        http.AggressiveRetryPolicy,
+	"latency-sensitive": http.LatencySensitiveRetryPolicy,
+	"batch":             http.BatchRetryPolicy,
+}
+
+type options struct {
+	modelId        string
+	environment    string
+	url            string
+	authUrl        string
+	localUrl       string
+	apiKey         string
+	bearerToken    string
+	input          string
+	inputFormat    string
+	output         string
+	outputFormat   string
+	retry          string
+	requestTimeout time.Duration
+	timeout        time.Duration
+	dryRun         bool
+}
+
+func main() {
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt)
+	defer stop()
+
+	if err := run(ctx, os.Args[1:], os.Stdin, os.Stdout, os.Stderr); err != nil {
+		if !errors.Is(err, flag.ErrHelp) {
+			fmt.Fprintf(os.Stderr, "qwak-predict: %v\n", err)
+		}
+		os.Exit(1)
+	}
+}
+
+func parseFlags(args []string, stderr io.Writer) (options, error) {
+	var opts options
+	flags := flag.NewFlagSet("qwak-predict", flag.ContinueOnError)
+	flags.SetOutput(stderr)
+	flags.StringVar(&opts.modelId, "model", "", "the model id (required)")
+	flags.StringVar(&opts.environment, "environment", "", "the Qwak environment of the model")
+	flags.StringVar(&opts.url, "url", "", "the base url of the model endpoints, overrides -environment")
+	flags.StringVar(&opts.authUrl, "auth-url", "", "the url of the authentication endpoint")
+	flags.StringVar(&opts.localUrl, "local", "", "the url of a model container running locally, e.g. http://localhost:5000, without authentication")
+	flags.StringVar(&opts.apiKey, "api-key", "", "the Qwak api key, default to QWAK_API_KEY or the qwak cli credentials")
+	flags.StringVar(&opts.bearerToken, "bearer-token", "", "a bearer token used instead of the api key")
+	flags.StringVar(&opts.input, "input", "-", "the feature vectors file, - for stdin")
+	flags.StringVar(&opts.inputFormat, "input-format", "", "csv or json, default to the input file extension, or json")
+	flags.StringVar(&opts.output, "output", "-", "the predictions file, - for stdout")
+	flags.StringVar(&opts.outputFormat, "output-format", formatJSON, "csv or json")
+	flags.StringVar(&opts.retry, "retry", "basic", "the retry policy: none, basic, aggressive, latency-sensitive or batch")
+	flags.DurationVar(&opts.requestTimeout, "request-timeout", 0, "the timeout of each http request, default to the SDK default")
+	flags.DurationVar(&opts.timeout, "timeout", time.Minute, "the timeout of the whole prediction, retries included")
+	flags.BoolVar(&opts.dryRun, "dry-run", false, "print the http request and an equivalent curl command instead of sending it")
+
+	if err := flags.Parse(args); err != nil {
+		return opts, err
+	}
+
+	if opts.modelId == "" {
+		return opts, errors.New("-model is required")
+	}
+
+	if opts.inputFormat == "" {
+		opts.inputFormat = formatJSON
+		if strings.EqualFold(filepath.Ext(opts.input), ".csv") {
+			opts.inputFormat = formatCSV
+		}
+	}
+
+	if opts.inputFormat != formatCSV && opts.inputFormat != formatJSON {
+		return opts, fmt.Errorf("unknown input format '%s'", opts.inputFormat)
+	}
+
+	if opts.outputFormat != formatCSV && opts.outputFormat != formatJSON {
+		return opts, fmt.Errorf("unknown output format '%s'", opts.outputFormat)
+	}
+
+	if _, ok := retryPolicies[opts.retry]; !ok {
+		return opts, fmt.Errorf("unknown retry policy '%s'", opts.retry)
+	}
+
+	return opts, nil
+}
+
+func run(ctx context.Context, args []string, stdin io.Reader, stdout io.Writer, stderr io.Writer) error {
+	opts, err := parseFlags(args, stderr)
+	if err != nil {
+		return err
+	}
+
+	client, err := qwak.NewRealTimeClient(clientConfig(opts))
+	if err != nil {
+		return err
+	}
+	defer client.Close()
+
+	vectors, err := readInput(opts, stdin)
+	if err != nil {
+		return err
+	}
+
+	predictionRequest := qwak.NewPredictionRequest(opts.modelId).AddFeatureVectors(vectors...)
+
+	ctx, cancel := context.WithTimeout(ctx, opts.timeout)
+	defer cancel()
+
+	if opts.dryRun {
+		return writeDryRun(ctx, client, predictionRequest, stdout)
+	}
+
+	response, err := client.PredictWithCtx(ctx, predictionRequest)
+	if err != nil {
+		return err
+	}
+	defer response.Release()
+
+	fmt.Fprintf(stderr, "%d predictions in %s, %d attempt(s)\n",
+		len(response.GetPredictions()), response.Meta().Latency.Round(time.Millisecond), response.Meta().Attempts)
+
+	return writeOutput(opts, response, stdout)
+}
+
+// clientConfig configures the client from the flags, leaving the SDK defaults otherwise
+func clientConfig(opts options) qwak.RealTimeClientConfig {
+	config := qwak.RealTimeClientConfig{
+		ApiKey:          opts.apiKey,
+		BearerToken:     opts.bearerToken,
+		Environment:     opts.environment,
+		Url:             opts.url,
+		AuthEndpointUrl: opts.authUrl,
+	}
+
+	if opts.localUrl != "" {
+		config = qwak.NewLocalClientConfig(opts.localUrl)
+	}
+
+	config.RetryPolicy = retryPolicies[opts.retry]()
+	config.RequestTimeout = opts.requestTimeout
+	return config
+}
+
+func readInput(opts options, stdin io.Reader) ([]*qwak.FeatureVector, error) {
+	input := stdin
+	if opts.input != "-" {
+		file, err := os.Open(opts.input)
+		if err != nil {
+			return nil, err
+		}
+		defer file.Close()
+		input = file
+	}
+
+	var rows []map[string]interface{}
+	var err error
+	if opts.inputFormat == formatCSV {
+		rows, err = readCSV(input)
+	} else {
+		rows, err = readJSON(input)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to read feature vectors: %w", err)
+	}
+
+	if len(rows) == 0 {
+		return nil, errors.New("no feature vectors in input")
+	}
+
+	vectors := make([]*qwak.FeatureVector, len(rows))
+	for idx, row := range rows {
+		names := make([]string, 0, len(row))
+		for name := range row {
+			names = append(names, name)
+		}
+		sort.Strings(names)
+
+		vectors[idx] = qwak.NewFeatureVector()
+		for _, name := range names {
+			vectors[idx].WithFeature(name, row[name])
+		}
+	}
+
+	return vectors, nil
+}
+
+// readJSON reads a json array of objects, or json objects one after the other
+func readJSON(input io.Reader) ([]map[string]interface{}, error) {
+	reader := bufio.NewReader(input)
+	first, err := firstNonSpace(reader)
+	if err == io.EOF {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	decoder := json.NewDecoder(reader)
+	if first == '[' {
+		var rows []map[string]interface{}
+		if err := decoder.Decode(&rows); err != nil {
+			return nil, err
+		}
+		return rows, nil
+	}
+
+	var rows []map[string]interface{}
+	for {
+		var row map[string]interface{}
+		if err := decoder.Decode(&row); err == io.EOF {
+			return rows, nil
+		} else if err != nil {
+			return nil, fmt.Errorf("object #%d: %w", len(rows)+1, err)
+		}
+		rows = append(rows, row)
+	}
+}
+
+func firstNonSpace(reader *bufio.Reader) (byte, error) {
+	for {
+		peeked, err := reader.Peek(1)
+		if err != nil {
+			return 0, err
+		}
+		if !bytes.ContainsAny(peeked, " \t\r\n") {
+			return peeked[0], nil
+		}
+		_, _ = reader.ReadByte()
+	}
+}
+
+// readCSV reads a header row of feature names followed by a row per feature vector.
+// Numbers and booleans are converted, empty fields are null, other fields are strings
+func readCSV(input io.Reader) ([]map[string]interface{}, error) {
+	records, err := csv.NewReader(input).ReadAll()
+	if err != nil {
+		return nil, err
+	}
+
+	if len(records) == 0 {
+		return nil, nil
+	}
+
+	header := records[0]
+	rows := make([]map[string]interface{}, 0, len(records)-1)
+	for _, record := range records[1:] {
+		row := make(map[string]interface{}, len(header))
+		for idx, name := range header {
+			row[name] = parseField(record[idx])
+		}
+		rows = append(rows, row)
+	}
+
+	return rows, nil
+}
+
+func parseField(field string) interface{} {
+	if field == "" {
+		return nil
+	}
+	if value, err := strconv.ParseInt(field, 10, 64); err == nil {
+		return value
+	}
+	if value, err := strconv.ParseFloat(field, 64); err == nil {
+		return value
+	}
+	if field == "true" || field == "false" {
+		return field == "true"
+	}
+	return field
+}
+
+func writeOutput(opts options, response *qwak.PredictionResponse, stdout io.Writer) error {
+	output := stdout
+	if opts.output != "-" {
+		file, err := os.Create(opts.output)
+		if err != nil {
+			return err
+		}
+		defer file.Close()
+		output = file
+	}
+
+	if opts.outputFormat == formatCSV {
+		return response.WriteCSV(output)
+	}
+
+	body, err := response.MarshalRecords()
+	if err != nil {
+		return err
+	}
+	_, err = fmt.Fprintf(output, "%s\n", body)
+	return err
+}
+
+// writeDryRun validates the request and writes the http request it would send, with an equivalent curl command
+func writeDryRun(ctx context.Context, client *qwak.RealTimeClient, predictionRequest *qwak.PredictionRequest, stdout io.Writer) error {
+	if err := predictionRequest.Validate(); err != nil {
+		return err
+	}
+
+	dump, err := client.DebugDump(ctx, predictionRequest)
+	if err != nil {
+		return err
+	}
+
+	_, err = io.WriteString(stdout, dump)
+	return err
+}
@@ -0,0 +1,55 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/qwak-ai/go-sdk/qwak/qwaktest"
+	"github.com/stretchr/testify/require"
+)
+
+func TestRun(t *testing.T) {
+	server := qwaktest.NewServer(qwaktest.ServerOptions{ApiKey: "key"}).
+		HandleModel("churn", func(rows []map[string]interface{}) ([]map[string]interface{}, error) {
+			outputs := make([]map[string]interface{}, len(rows))
+			for idx, row := range rows {
+				outputs[idx] = map[string]interface{}{"state": row["State"], "score": row["Age"].(float64) / 100}
+			}
+			return outputs, nil
+		})
+	defer server.Close()
+
+	flags := []string{"-model", "churn", "-url", server.URL(), "-auth-url", server.AuthURL(), "-api-key", "key"}
+
+	// json lines from stdin, csv output
+	var stdout, stderr bytes.Buffer
+	stdin := strings.NewReader("{\"State\": \"PPP\", \"Age\": 42}\n{\"State\": \"QQQ\", \"Age\": 7}\n")
+	err := run(context.Background(), append(flags, "-output-format", "csv"), stdin, &stdout, &stderr)
+	require.NoError(t, err)
+	require.Equal(t, "score,state\n0.42,PPP\n0.07,QQQ\n", stdout.String())
+	require.Contains(t, stderr.String(), "2 predictions")
+
+	// csv file, json output
+	input := filepath.Join(t.TempDir(), "vectors.csv")
+	require.NoError(t, os.WriteFile(input, []byte("State,Age\nPPP,42\n"), 0o600))
+	stdout.Reset()
+	err = run(context.Background(), append(flags, "-input", input), nil, &stdout, &stderr)
+	require.NoError(t, err)
+	require.JSONEq(t, `[{"score":0.42,"state":"PPP"}]`, stdout.String())
+
+	// dry run
+	stdout.Reset()
+	err = run(context.Background(), append(flags, "-dry-run"), strings.NewReader(`[{"Age": 42}]`), &stdout, &stderr)
+	require.NoError(t, err)
+	require.Contains(t, stdout.String(), "POST "+server.URL()+"/v1/churn/predict")
+	require.Contains(t, stdout.String(), `"columns":["Age"]`)
+	require.Contains(t, stdout.String(), "curl -X POST '"+server.URL()+"/v1/churn/predict'")
+	require.Equal(t, 2, server.PredictRequests("churn"))
+
+	err = run(context.Background(), []string{"-url", server.URL()}, nil, &stdout, &stderr)
+	require.EqualError(t, err, "-model is required")
+}
@@ -1,6 +1,7 @@
 package authentication
 
 import (
+	"bytes"
 	"context"
 	"encoding/json"
 	"errors"
@@ -12,27 +13,61 @@ import (
 )
 
 const (
-	TokenExpirationBuffer = 30 * time.Minute
-	stalenessTokenPeriod  = 2 * time.Hour
+	TokenExpirationBuffer    = 30 * time.Minute
+	stalenessTokenPeriod     = 2 * time.Hour
+	backgroundRenewalTimeout = 5 * time.Second
 )
 
 type Authenticator struct {
-	parentCtx     context.Context
-	ctx           context.Context
-	cancelContext context.CancelFunc
-	apiKey        string
-	httpClient    http.Client
-	singleFlight  singleflight.Group
+	credentials  CredentialsProvider
+	authUrl      string
+	httpClient   http.Client
+	singleFlight singleflight.Group
+	clock        http.Clock
+	strict       bool
+
+	lock             sync.Mutex
+	generation       int
+	ctx              context.Context
+	cancelContext    context.CancelFunc
+	stopped          bool
+	background       sync.WaitGroup
+	tokenWrapper     tokenWrapper
+	lastRenewalAt    time.Time
+	lastRenewalErr   error
+	lastRenewalErrAt time.Time
+}
 
-	lock         sync.Mutex
-	tokenWrapper tokenWrapper
+// TokenInfo describes the state of the authentication token, to monitor credentials health
+type TokenInfo struct {
+	// HasToken whether a token was obtained
+	HasToken bool
+	// ExpiresAt the expiration time of the token
+	ExpiresAt time.Time
+	// RefreshIn the time left until the token must be renewed before predicting, 0 when it must be renewed now.
+	// The token is renewed in the background once RefreshIn drops under 2 hours
+	RefreshIn time.Duration
+	// LastRenewalAt the time of the last successful renewal
+	LastRenewalAt time.Time
+	// LastRenewalError the error of the last renewal, nil when it succeeded
+	LastRenewalError error
+	// LastRenewalErrorAt the time of the last failed renewal
+	LastRenewalErrorAt time.Time
 }
 
 type AuthenticatorOptions struct {
-	// Deprecated: unused
-	Ctx        context.Context
-	ApiKey     string
-	HttpClient http.Client
+	// Deprecated: unused, bind the background renewals to a context with Authenticator.Start
+	Ctx    context.Context
+	ApiKey string
+	// Credentials provides the api key on each token renewal, overrides ApiKey when set
+	Credentials CredentialsProvider
+	HttpClient  http.Client
+	// AuthEndpointUrl override the authentication endpoint, default to http.DefaultAuthEndpointUri
+	AuthEndpointUrl string
+	// Clock tells the token expiry and the renewal times, default to http.SystemClock
+	Clock http.Clock
+	// StrictDecoding rejects authentication responses with unknown or missing fields
+	StrictDecoding bool
 }
 
 type authResponse struct {
@@ -47,9 +82,27 @@ type tokenWrapper struct {
 
 func NewAuthenticator(options *AuthenticatorOptions) *Authenticator {
 
+	authUrl := options.AuthEndpointUrl
+	if authUrl == "" {
+		authUrl = http.DefaultAuthEndpointUri
+	}
+
+	credentials := options.Credentials
+	if credentials == nil {
+		credentials = StaticCredentials(options.ApiKey)
+	}
+
+	clock := options.Clock
+	if clock == nil {
+		clock = http.SystemClock
+	}
+
 	authenticator := &Authenticator{
-		httpClient: options.HttpClient,
-		apiKey:     options.ApiKey,
+		httpClient:  options.HttpClient,
+		credentials: credentials,
+		authUrl:     authUrl,
+		clock:       clock,
+		strict:      options.StrictDecoding,
 	}
 
 	return authenticator
@@ -57,7 +110,7 @@ func NewAuthenticator(options *AuthenticatorOptions) *Authenticator {
 
 func (a *Authenticator) GetToken(ctx context.Context) (string, error) {
 	token := a.token()
-	expiredIn := getExpiredIn(token)
+	expiredIn := a.getExpiredIn(token)
 	if expiredIn <= 0 {
 		newToken, err := a.renewToken(ctx)
 		if err != nil {
@@ -70,16 +123,71 @@ func (a *Authenticator) GetToken(ctx context.Context) (string, error) {
 	return token.accessToken, nil
 }
 
+// TokenInfo returns the state of the authentication token
+func (a *Authenticator) TokenInfo() TokenInfo {
+	a.lock.Lock()
+	defer a.lock.Unlock()
+
+	return TokenInfo{
+		HasToken:           a.tokenWrapper.accessToken != "",
+		ExpiresAt:          a.tokenWrapper.expiredAt,
+		RefreshIn:          a.getExpiredIn(a.tokenWrapper),
+		LastRenewalAt:      a.lastRenewalAt,
+		LastRenewalError:   a.lastRenewalErr,
+		LastRenewalErrorAt: a.lastRenewalErrAt,
+	}
+}
+
 func (a *Authenticator) token() tokenWrapper {
 	a.lock.Lock()
 	defer a.lock.Unlock()
 	return a.tokenWrapper
 }
 
+// Start binds the background token renewals to ctx, cancelling them once ctx is done.
+// Without Start, background renewals are bound to context.Background() until Stop is called
+func (a *Authenticator) Start(ctx context.Context) {
+	a.lock.Lock()
+	defer a.lock.Unlock()
+
+	if a.cancelContext != nil {
+		a.cancelContext()
+	}
+	a.ctx, a.cancelContext = context.WithCancel(ctx)
+	a.stopped = false
+}
+
+// Stop cancels the running background token renewals and waits for them to return.
+// No background renewal is started afterwards, tokens are only renewed on demand by GetToken
+func (a *Authenticator) Stop() {
+	a.lock.Lock()
+	a.stopped = true
+	if a.cancelContext != nil {
+		a.cancelContext()
+	}
+	a.lock.Unlock()
+
+	a.background.Wait()
+}
+
 func (a *Authenticator) lazyRenewToken() {
+	a.lock.Lock()
+	defer a.lock.Unlock()
+
+	if a.stopped {
+		return
+	}
+
+	parentCtx := a.ctx
+	if parentCtx == nil {
+		parentCtx = context.Background()
+	}
+
+	a.background.Add(1)
 	go func() {
+		defer a.background.Done()
 		_, _, _ = a.singleFlight.Do("token-lazy-renew", func() (interface{}, error) {
-			ctx, cancelFunc := context.WithTimeout(context.Background(), 5*time.Second)
+			ctx, cancelFunc := context.WithTimeout(parentCtx, backgroundRenewalTimeout)
 			defer cancelFunc()
 			_, err := a.renewToken(ctx)
 			if err != nil {
@@ -91,22 +199,54 @@ func (a *Authenticator) lazyRenewToken() {
 
 }
 
-func (a *Authenticator) renewToken(ctx context.Context) (tokenWrapper, error) {
+// SetCredentials replaces the credentials provider, e.g. with StaticCredentials of a rotated api key.
+// The current token is dropped, so the next GetToken authenticates with the new credentials
+func (a *Authenticator) SetCredentials(credentials CredentialsProvider) {
+	a.lock.Lock()
+	defer a.lock.Unlock()
 
-	token, err, _ := a.singleFlight.Do("token-get", func() (interface{}, error) {
-		tokenResponse, err := a.doGetTokenRequest(ctx, a.apiKey)
+	a.credentials = credentials
+	a.generation++
+	a.tokenWrapper = tokenWrapper{}
+}
+
+func (a *Authenticator) renewToken(ctx context.Context) (tokenWrapper, error) {
+	a.lock.Lock()
+	credentials, generation := a.credentials, a.generation
+	a.lock.Unlock()
 
+	// renewals of replaced credentials are not joined
+	token, err, _ := a.singleFlight.Do(fmt.Sprintf("token-get-%d", generation), func() (interface{}, error) {
+		apiKey, err := credentials.ApiKey(ctx)
 		if err != nil {
-			return tokenWrapper{}, err
+			err = fmt.Errorf("failed to resolve api key: %w", err)
+		}
+
+		var tokenResponse authResponse
+		if err == nil {
+			tokenResponse, err = a.doGetTokenRequest(ctx, apiKey)
 		}
 
 		a.lock.Lock()
 		defer a.lock.Unlock()
-		a.tokenWrapper = tokenWrapper{
+
+		if err != nil {
+			a.lastRenewalErr = err
+			a.lastRenewalErrAt = a.clock.Now()
+			return tokenWrapper{}, err
+		}
+
+		token := tokenWrapper{
 			accessToken: tokenResponse.AccessToken,
 			expiredAt:   time.Unix(tokenResponse.ExpiredAt, 0),
 		}
-		return a.tokenWrapper, nil
+		// a token of replaced credentials is returned to its caller, but not kept
+		if generation == a.generation {
+			a.lastRenewalAt = a.clock.Now()
+			a.lastRenewalErr = nil
+			a.tokenWrapper = token
+		}
+		return token, nil
 
 	})
 
@@ -116,7 +256,7 @@ func (a *Authenticator) renewToken(ctx context.Context) (tokenWrapper, error) {
 func (a *Authenticator) doGetTokenRequest(ctx context.Context, apiKey string) (authResponse, error) {
 
 	decodedResponse := authResponse{}
-	request, err := http.GetAuthenticationRequest(ctx, apiKey)
+	request, err := http.GetAuthenticationRequestWithUrl(ctx, a.authUrl, apiKey)
 
 	if err != nil {
 		return decodedResponse, err
@@ -125,6 +265,7 @@ func (a *Authenticator) doGetTokenRequest(ctx context.Context, apiKey string) (a
 		MaxAttempts:              5,
 		IntervalMs:               200,
 		ExponentialBackoffFactor: 1.5,
+		Clock:                    a.clock,
 	})
 
 	if err != nil {
@@ -139,17 +280,42 @@ func (a *Authenticator) doGetTokenRequest(ctx context.Context, apiKey string) (a
 		return decodedResponse, fmt.Errorf("authentication failed. failed with code %d. response: '%s'", statusCode, body)
 	}
 
+	if a.strict {
+		return decodeAuthResponseStrict(body)
+	}
+
 	err = json.Unmarshal(body, &decodedResponse)
 
 	if err != nil {
-		return decodedResponse, errors.New("failed to unmarshal authentication response")
+		return decodedResponse, fmt.Errorf("failed to unmarshal authentication response: %w", http.DescribeJSONError(body, err))
+	}
+
+	return decodedResponse, nil
+}
+
+// decodeAuthResponseStrict decodes an authentication response having exactly the expected fields
+func decodeAuthResponseStrict(body []byte) (authResponse, error) {
+	decodedResponse := authResponse{}
+	decoder := json.NewDecoder(bytes.NewReader(body))
+	decoder.DisallowUnknownFields()
+
+	if err := decoder.Decode(&decodedResponse); err != nil {
+		return decodedResponse, fmt.Errorf("failed to unmarshal authentication response: %w", http.DescribeJSONError(body, err))
+	}
+
+	if decoder.More() {
+		return decodedResponse, errors.New("failed to unmarshal authentication response: unexpected data after the json object")
+	}
+
+	if decodedResponse.AccessToken == "" || decodedResponse.ExpiredAt <= 0 {
+		return decodedResponse, errors.New("failed to unmarshal authentication response: access token or expiration is missing")
 	}
 
 	return decodedResponse, nil
 }
 
-func getExpiredIn(token tokenWrapper) time.Duration {
-	now := time.Now()
+func (a *Authenticator) getExpiredIn(token tokenWrapper) time.Duration {
+	now := a.clock.Now()
 
 	if token.expiredAt.IsZero() {
 		return 0
@@ -0,0 +1,64 @@
+package authentication
+
+import (
+	"context"
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestAuthenticatorStopCancelsBackgroundRenewals(t *testing.T) {
+	var requests int32
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if atomic.AddInt32(&requests, 1) > 1 {
+			// background renewals hang until cancelled, which is noticed once the body was read
+			_, _ = io.Copy(io.Discard, r.Body)
+			<-r.Context().Done()
+			return
+		}
+		// a token to be renewed in the background, expiring in an hour
+		_ = json.NewEncoder(w).Encode(map[string]interface{}{
+			"accessToken": "token",
+			"expiredAt":   time.Now().Add(TokenExpirationBuffer + time.Hour).Unix(),
+		})
+	}))
+	defer server.Close()
+
+	authenticator := NewAuthenticator(&AuthenticatorOptions{
+		ApiKey:          "key",
+		HttpClient:      server.Client(),
+		AuthEndpointUrl: server.URL,
+	})
+	authenticator.Start(context.Background())
+
+	_, err := authenticator.GetToken(context.Background())
+	require.NoError(t, err)
+	token, err := authenticator.GetToken(context.Background())
+	require.NoError(t, err)
+	require.Equal(t, "token", token)
+	require.Eventually(t, func() bool { return atomic.LoadInt32(&requests) == 2 }, time.Second, time.Millisecond)
+
+	stopped := make(chan struct{})
+	go func() {
+		authenticator.Stop()
+		close(stopped)
+	}()
+
+	select {
+	case <-stopped:
+	case <-time.After(backgroundRenewalTimeout / 2):
+		t.Fatal("Stop did not cancel the background renewal")
+	}
+
+	token, err = authenticator.GetToken(context.Background())
+	require.NoError(t, err)
+	require.Equal(t, "token", token)
+	time.Sleep(20 * time.Millisecond)
+	require.Equal(t, int32(2), atomic.LoadInt32(&requests), "no background renewal is started after Stop")
+}
@@ -0,0 +1,192 @@
+package authentication
+
+import (
+	"bufio"
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+const (
+	// ApiKeyEnvVariable the environment variable read by EnvCredentials by default
+	ApiKeyEnvVariable = "QWAK_API_KEY"
+	// CredentialsFileEnvVariable overrides the path of the credentials file read by FileCredentials
+	CredentialsFileEnvVariable = "QWAK_CREDENTIALS_FILE"
+	// ProfileEnvVariable selects the profile of the credentials file read by FileCredentials
+	ProfileEnvVariable = "QWAK_PROFILE"
+	// DefaultCredentialsFile the path of the credentials file written by the qwak cli, relative to the home directory
+	DefaultCredentialsFile = ".qwak/auth/config"
+	// DefaultProfile the profile of the credentials file used when none is set
+	DefaultProfile = "default"
+)
+
+// ErrNoCredentials is returned by a CredentialsProvider having no api key to offer,
+// a CredentialsChain then tries the next provider
+var ErrNoCredentials = errors.New("no credentials found")
+
+// CredentialsProvider returns the Qwak api key. It is called whenever the authentication token is renewed,
+// so a provider may return a rotated key. Return an error wrapping ErrNoCredentials when no key is available
+type CredentialsProvider interface {
+	ApiKey(ctx context.Context) (string, error)
+}
+
+// CredentialsProviderFunc adapts a function to a CredentialsProvider, e.g. to read the api key
+// from an instance metadata service
+type CredentialsProviderFunc func(ctx context.Context) (string, error)
+
+// ApiKey calls the function
+func (f CredentialsProviderFunc) ApiKey(ctx context.Context) (string, error) {
+	return f(ctx)
+}
+
+// StaticCredentials is an api key set explicitly
+type StaticCredentials string
+
+// ApiKey returns the api key, or ErrNoCredentials when it is empty
+func (c StaticCredentials) ApiKey(context.Context) (string, error) {
+	if c == "" {
+		return "", fmt.Errorf("api key is not configured: %w", ErrNoCredentials)
+	}
+	return string(c), nil
+}
+
+// EnvCredentials reads the api key from an environment variable
+type EnvCredentials struct {
+	// Variable the environment variable name, default to ApiKeyEnvVariable
+	Variable string
+}
+
+// ApiKey returns the value of the environment variable, or ErrNoCredentials when it is not set
+func (c EnvCredentials) ApiKey(context.Context) (string, error) {
+	variable := c.Variable
+	if variable == "" {
+		variable = ApiKeyEnvVariable
+	}
+
+	apiKey := strings.TrimSpace(os.Getenv(variable))
+	if apiKey == "" {
+		return "", fmt.Errorf("environment variable %s is not set: %w", variable, ErrNoCredentials)
+	}
+	return apiKey, nil
+}
+
+// FileCredentials reads the api key from an ini formatted credentials file, such as the one written by
+// `qwak configure`:
+//
+//	[default]
+//	api_key = <your api key>
+type FileCredentials struct {
+	// Path the credentials file, default to the QWAK_CREDENTIALS_FILE environment variable,
+	// then to DefaultCredentialsFile in the home directory
+	Path string
+	// Profile the section of the file holding the api key, default to the QWAK_PROFILE environment variable,
+	// then to DefaultProfile
+	Profile string
+}
+
+// ApiKey returns the api_key of the profile, or ErrNoCredentials when the file or the key does not exist
+func (c FileCredentials) ApiKey(context.Context) (string, error) {
+	path, err := c.path()
+	if err != nil {
+		return "", err
+	}
+
+	profile := c.Profile
+	if profile == "" {
+		profile = os.Getenv(ProfileEnvVariable)
+	}
+	if profile == "" {
+		profile = DefaultProfile
+	}
+
+	file, err := os.Open(path)
+	if errors.Is(err, os.ErrNotExist) {
+		return "", fmt.Errorf("credentials file %s does not exist: %w", path, ErrNoCredentials)
+	}
+	if err != nil {
+		return "", fmt.Errorf("failed to open credentials file: %w", err)
+	}
+	defer file.Close()
+
+	section := ""
+	scanner := bufio.NewScanner(file)
+	for scanner.Scan() {
+		line := strings.TrimSpace(scanner.Text())
+		if line == "" || strings.HasPrefix(line, "#") || strings.HasPrefix(line, ";") {
+			continue
+		}
+
+		if strings.HasPrefix(line, "[") && strings.HasSuffix(line, "]") {
+			section = strings.TrimSpace(line[1 : len(line)-1])
+			continue
+		}
+
+		key, value, ok := strings.Cut(line, "=")
+		if ok && section == profile && strings.TrimSpace(key) == "api_key" {
+			if apiKey := strings.Trim(strings.TrimSpace(value), `"'`); apiKey != "" {
+				return apiKey, nil
+			}
+		}
+	}
+
+	if err := scanner.Err(); err != nil {
+		return "", fmt.Errorf("failed to read credentials file: %w", err)
+	}
+
+	return "", fmt.Errorf("profile '%s' of credentials file %s has no api_key: %w", profile, path, ErrNoCredentials)
+}
+
+func (c FileCredentials) path() (string, error) {
+	if c.Path != "" {
+		return c.Path, nil
+	}
+
+	if path := os.Getenv(CredentialsFileEnvVariable); path != "" {
+		return path, nil
+	}
+
+	home, err := os.UserHomeDir()
+	if err != nil {
+		return "", fmt.Errorf("failed to locate the credentials file: %s: %w", err.Error(), ErrNoCredentials)
+	}
+	return filepath.Join(home, DefaultCredentialsFile), nil
+}
+
+// CredentialsChain tries its providers in order, and returns the api key of the first one having credentials.
+// A provider failing with an error other than ErrNoCredentials stops the chain
+type CredentialsChain struct {
+	providers []CredentialsProvider
+}
+
+// NewCredentialsChain returns a CredentialsChain trying the providers in order
+func NewCredentialsChain(providers ...CredentialsProvider) *CredentialsChain {
+	return &CredentialsChain{providers: providers}
+}
+
+// DefaultCredentialsChain returns the chain used when no credentials provider is configured: the explicit
+// api key, the QWAK_API_KEY environment variable, the credentials file, then the custom providers
+func DefaultCredentialsChain(apiKey string, custom ...CredentialsProvider) *CredentialsChain {
+	providers := []CredentialsProvider{StaticCredentials(apiKey), EnvCredentials{}, FileCredentials{}}
+	return NewCredentialsChain(append(providers, custom...)...)
+}
+
+// ApiKey returns the api key of the first provider having credentials. When none has, the returned error
+// wraps ErrNoCredentials and describes why each provider was skipped
+func (c *CredentialsChain) ApiKey(ctx context.Context) (string, error) {
+	var skipped []string
+	for _, provider := range c.providers {
+		apiKey, err := provider.ApiKey(ctx)
+		if err == nil {
+			return apiKey, nil
+		}
+		if !errors.Is(err, ErrNoCredentials) {
+			return "", err
+		}
+		skipped = append(skipped, strings.TrimSuffix(err.Error(), ": "+ErrNoCredentials.Error()))
+	}
+
+	return "", fmt.Errorf("%w: %s", ErrNoCredentials, strings.Join(skipped, "; "))
+}
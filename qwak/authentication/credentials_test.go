@@ -0,0 +1,59 @@
+package authentication
+
+import (
+	"context"
+	"errors"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestCredentialsChainPrecedence(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "config")
+	require.NoError(t, os.WriteFile(path, []byte("[default]\napi_key = file-key\n\n[ci]\napi_key = \"ci-key\"\n"), 0600))
+	t.Setenv(CredentialsFileEnvVariable, path)
+	t.Setenv(ApiKeyEnvVariable, "env-key")
+	t.Setenv(ProfileEnvVariable, "")
+
+	apiKey, err := DefaultCredentialsChain("explicit-key").ApiKey(context.Background())
+	require.NoError(t, err)
+	require.Equal(t, "explicit-key", apiKey)
+
+	apiKey, err = DefaultCredentialsChain("").ApiKey(context.Background())
+	require.NoError(t, err)
+	require.Equal(t, "env-key", apiKey)
+
+	t.Setenv(ApiKeyEnvVariable, "")
+	apiKey, err = DefaultCredentialsChain("").ApiKey(context.Background())
+	require.NoError(t, err)
+	require.Equal(t, "file-key", apiKey)
+
+	t.Setenv(ProfileEnvVariable, "ci")
+	apiKey, err = DefaultCredentialsChain("").ApiKey(context.Background())
+	require.NoError(t, err)
+	require.Equal(t, "ci-key", apiKey)
+
+	t.Setenv(ProfileEnvVariable, "missing")
+	custom := CredentialsProviderFunc(func(context.Context) (string, error) { return "custom-key", nil })
+	apiKey, err = DefaultCredentialsChain("", custom).ApiKey(context.Background())
+	require.NoError(t, err)
+	require.Equal(t, "custom-key", apiKey)
+}
+
+func TestCredentialsChainErrors(t *testing.T) {
+	t.Setenv(CredentialsFileEnvVariable, filepath.Join(t.TempDir(), "missing"))
+	t.Setenv(ApiKeyEnvVariable, "")
+
+	_, err := DefaultCredentialsChain("").ApiKey(context.Background())
+	require.ErrorIs(t, err, ErrNoCredentials)
+	require.Contains(t, err.Error(), "api key is not configured")
+	require.Contains(t, err.Error(), "environment variable QWAK_API_KEY is not set")
+	require.Contains(t, err.Error(), "does not exist")
+
+	failure := errors.New("metadata service unreachable")
+	failing := CredentialsProviderFunc(func(context.Context) (string, error) { return "", failure })
+	_, err = DefaultCredentialsChain("", failing, StaticCredentials("never-used")).ApiKey(context.Background())
+	require.ErrorIs(t, err, failure)
+}
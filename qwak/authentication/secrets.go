@@ -0,0 +1,446 @@
+package authentication
+
+import (
+	"bytes"
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	gohttp "net/http"
+	"os"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/qwak-ai/go-sdk/qwak/http"
+)
+
+// DefaultSecretRefreshInterval how long an api key fetched from a secret store is used before being fetched again
+const DefaultSecretRefreshInterval = 15 * time.Minute
+
+// SecretFetcher fetches the raw value of a secret holding the api key
+type SecretFetcher func(ctx context.Context) (string, error)
+
+// SecretOptions controls how the api key is read from a secret
+type SecretOptions struct {
+	// Field the json field of the secret holding the api key, the whole secret is the api key when empty
+	Field string
+	// RefreshInterval how long the api key is used before the secret is fetched again,
+	// default to DefaultSecretRefreshInterval
+	RefreshInterval time.Duration
+}
+
+// SecretCredentials is a CredentialsProvider reading the api key from a secret store, so the key never
+// appears in environment variables or files. The key is fetched again once RefreshInterval elapsed, picking
+// up rotations. When fetching fails after a successful fetch, the previous key keeps being used
+type SecretCredentials struct {
+	fetch   SecretFetcher
+	options SecretOptions
+
+	lock      sync.Mutex
+	apiKey    string
+	fetchedAt time.Time
+}
+
+// NewSecretCredentials returns SecretCredentials reading the api key with fetch, e.g. to use a secret
+// store client already configured by the application
+func NewSecretCredentials(fetch SecretFetcher, options SecretOptions) *SecretCredentials {
+	if options.RefreshInterval <= 0 {
+		options.RefreshInterval = DefaultSecretRefreshInterval
+	}
+	return &SecretCredentials{fetch: fetch, options: options}
+}
+
+// ApiKey returns the api key read from the secret, fetching it when it is older than the refresh interval
+func (c *SecretCredentials) ApiKey(ctx context.Context) (string, error) {
+	c.lock.Lock()
+	defer c.lock.Unlock()
+
+	if c.apiKey != "" && time.Since(c.fetchedAt) < c.options.RefreshInterval {
+		return c.apiKey, nil
+	}
+
+	apiKey, err := c.fetchApiKey(ctx)
+	if err != nil {
+		if c.apiKey != "" {
+			return c.apiKey, nil
+		}
+		return "", err
+	}
+
+	c.apiKey = apiKey
+	c.fetchedAt = time.Now()
+	return apiKey, nil
+}
+
+func (c *SecretCredentials) fetchApiKey(ctx context.Context) (string, error) {
+	secret, err := c.fetch(ctx)
+	if err != nil {
+		return "", fmt.Errorf("failed to fetch api key secret: %w", err)
+	}
+
+	if c.options.Field == "" {
+		return strings.TrimSpace(secret), nil
+	}
+
+	var fields map[string]interface{}
+	if err := json.Unmarshal([]byte(secret), &fields); err != nil {
+		return "", errors.New("api key secret is not a json object")
+	}
+
+	apiKey, ok := fields[c.options.Field].(string)
+	if !ok || apiKey == "" {
+		return "", fmt.Errorf("api key secret has no '%s' field", c.options.Field)
+	}
+	return apiKey, nil
+}
+
+// VaultOptions locates the api key in a HashiCorp Vault KV secrets engine
+type VaultOptions struct {
+	SecretOptions
+	// Address the Vault server address, default to the VAULT_ADDR environment variable
+	Address string
+	// Token the Vault token, default to the VAULT_TOKEN environment variable
+	Token string
+	// Namespace the Vault enterprise namespace, default to the VAULT_NAMESPACE environment variable
+	Namespace string
+	// Mount the path of the KV secrets engine, default to "secret"
+	Mount string
+	// Path the path of the secret in the secrets engine
+	Path string
+	// KVVersion the version of the KV secrets engine, 1 or 2, default to 2
+	KVVersion int
+	// HttpClient override the http client, default to http.DefaultClient
+	HttpClient http.Client
+}
+
+// NewVaultCredentials returns SecretCredentials reading the api key from HashiCorp Vault.
+// The Field option defaults to "api_key"
+func NewVaultCredentials(options VaultOptions) (*SecretCredentials, error) {
+	if options.Address == "" {
+		options.Address = os.Getenv("VAULT_ADDR")
+	}
+	if options.Token == "" {
+		options.Token = os.Getenv("VAULT_TOKEN")
+	}
+	if options.Namespace == "" {
+		options.Namespace = os.Getenv("VAULT_NAMESPACE")
+	}
+	if options.Mount == "" {
+		options.Mount = "secret"
+	}
+	if options.Field == "" {
+		options.Field = "api_key"
+	}
+	if options.HttpClient == nil {
+		options.HttpClient = gohttp.DefaultClient
+	}
+
+	if options.Address == "" || options.Token == "" {
+		return nil, errors.New("vault address and token are mandatory")
+	}
+	if options.Path == "" {
+		return nil, errors.New("vault secret path is missing")
+	}
+
+	secretUrl := fmt.Sprintf("%s/v1/%s/data/%s", strings.TrimSuffix(options.Address, "/"),
+		strings.Trim(options.Mount, "/"), strings.Trim(options.Path, "/"))
+	if options.KVVersion == 1 {
+		secretUrl = fmt.Sprintf("%s/v1/%s/%s", strings.TrimSuffix(options.Address, "/"),
+			strings.Trim(options.Mount, "/"), strings.Trim(options.Path, "/"))
+	}
+
+	fetch := func(ctx context.Context) (string, error) {
+		request, err := gohttp.NewRequestWithContext(ctx, gohttp.MethodGet, secretUrl, nil)
+		if err != nil {
+			return "", err
+		}
+		request.Header.Set("X-Vault-Token", options.Token)
+		if options.Namespace != "" {
+			request.Header.Set("X-Vault-Namespace", options.Namespace)
+		}
+
+		var response struct {
+			Data json.RawMessage `json:"data"`
+		}
+		if err := doSecretRequest(options.HttpClient, request, &response); err != nil {
+			return "", fmt.Errorf("vault: %w", err)
+		}
+
+		data := response.Data
+		if options.KVVersion != 1 {
+			var versioned struct {
+				Data json.RawMessage `json:"data"`
+			}
+			if err := json.Unmarshal(response.Data, &versioned); err != nil {
+				return "", fmt.Errorf("vault: unexpected secret format: %w", err)
+			}
+			data = versioned.Data
+		}
+		return string(data), nil
+	}
+
+	return NewSecretCredentials(fetch, options.SecretOptions), nil
+}
+
+// AWSSecretsManagerOptions locates the api key in AWS Secrets Manager
+type AWSSecretsManagerOptions struct {
+	SecretOptions
+	// SecretId the name or the arn of the secret
+	SecretId string
+	// VersionStage the staging label of the secret version, default to AWSCURRENT
+	VersionStage string
+	// Region the AWS region, default to the AWS_REGION then AWS_DEFAULT_REGION environment variables
+	Region string
+	// AccessKeyId, SecretAccessKey and SessionToken the AWS credentials, default to the AWS_ACCESS_KEY_ID,
+	// AWS_SECRET_ACCESS_KEY and AWS_SESSION_TOKEN environment variables
+	AccessKeyId     string
+	SecretAccessKey string
+	SessionToken    string
+	// Endpoint override the Secrets Manager endpoint, e.g. for a vpc endpoint
+	Endpoint string
+	// HttpClient override the http client, default to http.DefaultClient
+	HttpClient http.Client
+}
+
+// NewAWSSecretsManagerCredentials returns SecretCredentials reading the api key from AWS Secrets Manager.
+// To use the credentials of the AWS SDK, e.g. an instance role, wrap its client with NewSecretCredentials instead
+func NewAWSSecretsManagerCredentials(options AWSSecretsManagerOptions) (*SecretCredentials, error) {
+	if options.Region == "" {
+		options.Region = os.Getenv("AWS_REGION")
+	}
+	if options.Region == "" {
+		options.Region = os.Getenv("AWS_DEFAULT_REGION")
+	}
+	if options.AccessKeyId == "" && options.SecretAccessKey == "" {
+		options.AccessKeyId = os.Getenv("AWS_ACCESS_KEY_ID")
+		options.SecretAccessKey = os.Getenv("AWS_SECRET_ACCESS_KEY")
+		options.SessionToken = os.Getenv("AWS_SESSION_TOKEN")
+	}
+	if options.HttpClient == nil {
+		options.HttpClient = gohttp.DefaultClient
+	}
+
+	if options.SecretId == "" {
+		return nil, errors.New("aws secret id is missing")
+	}
+	if options.Region == "" {
+		return nil, errors.New("aws region is missing")
+	}
+	if options.AccessKeyId == "" || options.SecretAccessKey == "" {
+		return nil, errors.New("aws access key id and secret access key are mandatory")
+	}
+	if options.Endpoint == "" {
+		options.Endpoint = fmt.Sprintf("https://secretsmanager.%s.amazonaws.com", options.Region)
+	}
+
+	fetch := func(ctx context.Context) (string, error) {
+		body, err := json.Marshal(struct {
+			SecretId     string `json:"SecretId"`
+			VersionStage string `json:"VersionStage,omitempty"`
+		}{options.SecretId, options.VersionStage})
+		if err != nil {
+			return "", err
+		}
+
+		request, err := gohttp.NewRequestWithContext(ctx, gohttp.MethodPost, options.Endpoint+"/", bytes.NewReader(body))
+		if err != nil {
+			return "", err
+		}
+		request.Header.Set("Content-Type", "application/x-amz-json-1.1")
+		request.Header.Set("X-Amz-Target", "secretsmanager.GetSecretValue")
+		signAWSRequest(request, body, options, time.Now())
+
+		var response struct {
+			SecretString string `json:"SecretString"`
+		}
+		if err := doSecretRequest(options.HttpClient, request, &response); err != nil {
+			return "", fmt.Errorf("aws secrets manager: %w", err)
+		}
+		return response.SecretString, nil
+	}
+
+	return NewSecretCredentials(fetch, options.SecretOptions), nil
+}
+
+// signAWSRequest signs the request with AWS signature version 4
+func signAWSRequest(request *gohttp.Request, body []byte, options AWSSecretsManagerOptions, now time.Time) {
+	amzDate := now.UTC().Format("20060102T150405Z")
+	date := amzDate[:8]
+
+	request.Header.Set("X-Amz-Date", amzDate)
+	if options.SessionToken != "" {
+		request.Header.Set("X-Amz-Security-Token", options.SessionToken)
+	}
+
+	headers := map[string]string{"host": request.URL.Host}
+	for name, values := range request.Header {
+		headers[strings.ToLower(name)] = strings.TrimSpace(strings.Join(values, ","))
+	}
+	names := make([]string, 0, len(headers))
+	for name := range headers {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	var canonicalHeaders strings.Builder
+	for _, name := range names {
+		canonicalHeaders.WriteString(name + ":" + headers[name] + "\n")
+	}
+	signedHeaders := strings.Join(names, ";")
+
+	path := request.URL.EscapedPath()
+	if path == "" {
+		path = "/"
+	}
+	canonicalRequest := strings.Join([]string{
+		request.Method, path, request.URL.RawQuery, canonicalHeaders.String(), signedHeaders, sha256Hex(body),
+	}, "\n")
+
+	scope := fmt.Sprintf("%s/%s/secretsmanager/aws4_request", date, options.Region)
+	stringToSign := strings.Join([]string{"AWS4-HMAC-SHA256", amzDate, scope, sha256Hex([]byte(canonicalRequest))}, "\n")
+
+	signingKey := awsSigningKey(options.SecretAccessKey, date, options.Region, "secretsmanager")
+	signature := hex.EncodeToString(hmacSHA256(signingKey, stringToSign))
+
+	request.Header.Set("Authorization", fmt.Sprintf("AWS4-HMAC-SHA256 Credential=%s/%s, SignedHeaders=%s, Signature=%s",
+		options.AccessKeyId, scope, signedHeaders, signature))
+}
+
+func awsSigningKey(secretAccessKey string, date string, region string, service string) []byte {
+	key := hmacSHA256([]byte("AWS4"+secretAccessKey), date)
+	key = hmacSHA256(key, region)
+	key = hmacSHA256(key, service)
+	return hmacSHA256(key, "aws4_request")
+}
+
+func hmacSHA256(key []byte, data string) []byte {
+	mac := hmac.New(sha256.New, key)
+	mac.Write([]byte(data))
+	return mac.Sum(nil)
+}
+
+func sha256Hex(data []byte) string {
+	sum := sha256.Sum256(data)
+	return hex.EncodeToString(sum[:])
+}
+
+// GCPMetadataTokenUrl the endpoint of the GCP metadata server returning access tokens of the instance service account
+const GCPMetadataTokenUrl = "http://metadata.google.internal/computeMetadata/v1/instance/service-accounts/default/token"
+
+// GCPSecretManagerOptions locates the api key in GCP Secret Manager
+type GCPSecretManagerOptions struct {
+	SecretOptions
+	// Project the project id or number holding the secret
+	Project string
+	// Secret the secret id
+	Secret string
+	// Version the secret version, default to "latest"
+	Version string
+	// AccessToken returns an OAuth2 access token authorized to access the secret,
+	// default to the token of the service account from the GCP metadata server
+	AccessToken func(ctx context.Context) (string, error)
+	// Endpoint override the Secret Manager endpoint, default to https://secretmanager.googleapis.com
+	Endpoint string
+	// HttpClient override the http client, default to http.DefaultClient
+	HttpClient http.Client
+}
+
+// NewGCPSecretManagerCredentials returns SecretCredentials reading the api key from GCP Secret Manager
+func NewGCPSecretManagerCredentials(options GCPSecretManagerOptions) (*SecretCredentials, error) {
+	if options.Project == "" || options.Secret == "" {
+		return nil, errors.New("gcp project and secret are mandatory")
+	}
+	if options.Version == "" {
+		options.Version = "latest"
+	}
+	if options.Endpoint == "" {
+		options.Endpoint = "https://secretmanager.googleapis.com"
+	}
+	if options.HttpClient == nil {
+		options.HttpClient = gohttp.DefaultClient
+	}
+	if options.AccessToken == nil {
+		options.AccessToken = gcpMetadataAccessToken(options.HttpClient)
+	}
+
+	secretUrl := fmt.Sprintf("%s/v1/projects/%s/secrets/%s/versions/%s:access",
+		strings.TrimSuffix(options.Endpoint, "/"), options.Project, options.Secret, options.Version)
+
+	fetch := func(ctx context.Context) (string, error) {
+		token, err := options.AccessToken(ctx)
+		if err != nil {
+			return "", fmt.Errorf("gcp secret manager: failed to get access token: %w", err)
+		}
+
+		request, err := gohttp.NewRequestWithContext(ctx, gohttp.MethodGet, secretUrl, nil)
+		if err != nil {
+			return "", err
+		}
+		request.Header.Set("Authorization", fmt.Sprintf(http.BearerTokenTemplate, token))
+
+		var response struct {
+			Payload struct {
+				Data string `json:"data"`
+			} `json:"payload"`
+		}
+		if err := doSecretRequest(options.HttpClient, request, &response); err != nil {
+			return "", fmt.Errorf("gcp secret manager: %w", err)
+		}
+
+		secret, err := base64.StdEncoding.DecodeString(response.Payload.Data)
+		if err != nil {
+			return "", fmt.Errorf("gcp secret manager: failed to decode secret payload: %w", err)
+		}
+		return string(secret), nil
+	}
+
+	return NewSecretCredentials(fetch, options.SecretOptions), nil
+}
+
+func gcpMetadataAccessToken(client http.Client) func(ctx context.Context) (string, error) {
+	return func(ctx context.Context) (string, error) {
+		request, err := gohttp.NewRequestWithContext(ctx, gohttp.MethodGet, GCPMetadataTokenUrl, nil)
+		if err != nil {
+			return "", err
+		}
+		request.Header.Set("Metadata-Flavor", "Google")
+
+		var response struct {
+			AccessToken string `json:"access_token"`
+		}
+		if err := doSecretRequest(client, request, &response); err != nil {
+			return "", err
+		}
+		return response.AccessToken, nil
+	}
+}
+
+// doSecretRequest sends a secret store request and decodes its json response into value
+func doSecretRequest(client http.Client, request *gohttp.Request, value interface{}) error {
+	response, err := client.Do(request)
+	if err != nil {
+		return err
+	}
+	defer response.Body.Close()
+
+	body, err := io.ReadAll(response.Body)
+	if err != nil {
+		return err
+	}
+
+	if response.StatusCode != gohttp.StatusOK {
+		return fmt.Errorf("request failed with status code %d. response: '%s'", response.StatusCode, body)
+	}
+
+	if err := json.Unmarshal(body, value); err != nil {
+		return fmt.Errorf("failed to decode response: %w", err)
+	}
+	return nil
+}
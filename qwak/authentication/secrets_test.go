@@ -0,0 +1,127 @@
+package authentication
+
+import (
+	"context"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestSecretCredentialsRefresh(t *testing.T) {
+	fetches := 0
+	secret := `{"api_key": "first"}`
+	var fetchErr error
+	credentials := NewSecretCredentials(func(context.Context) (string, error) {
+		fetches++
+		return secret, fetchErr
+	}, SecretOptions{Field: "api_key", RefreshInterval: 20 * time.Millisecond})
+
+	apiKey, err := credentials.ApiKey(context.Background())
+	require.NoError(t, err)
+	require.Equal(t, "first", apiKey)
+	_, _ = credentials.ApiKey(context.Background())
+	require.Equal(t, 1, fetches)
+
+	secret = `{"api_key": "rotated"}`
+	time.Sleep(30 * time.Millisecond)
+	apiKey, err = credentials.ApiKey(context.Background())
+	require.NoError(t, err)
+	require.Equal(t, "rotated", apiKey)
+
+	fetchErr = errors.New("unreachable")
+	time.Sleep(30 * time.Millisecond)
+	apiKey, err = credentials.ApiKey(context.Background())
+	require.NoError(t, err)
+	require.Equal(t, "rotated", apiKey, "the previous key is used when the secret store is unreachable")
+
+	_, err = NewSecretCredentials(func(context.Context) (string, error) {
+		return `{"other": "value"}`, nil
+	}, SecretOptions{Field: "api_key"}).ApiKey(context.Background())
+	require.EqualError(t, err, "api key secret has no 'api_key' field")
+}
+
+func TestVaultCredentials(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != "/v1/kv/data/qwak/prod" || r.Header.Get("X-Vault-Token") != "vault-token" {
+			w.WriteHeader(http.StatusForbidden)
+			return
+		}
+		_, _ = w.Write([]byte(`{"data": {"data": {"api_key": "vault-key"}, "metadata": {"version": 3}}}`))
+	}))
+	defer server.Close()
+
+	credentials, err := NewVaultCredentials(VaultOptions{Address: server.URL, Token: "vault-token", Mount: "kv", Path: "qwak/prod"})
+	require.NoError(t, err)
+
+	apiKey, err := credentials.ApiKey(context.Background())
+	require.NoError(t, err)
+	require.Equal(t, "vault-key", apiKey)
+}
+
+func TestAWSSecretsManagerCredentials(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var body map[string]string
+		_ = json.NewDecoder(r.Body).Decode(&body)
+		authorization := r.Header.Get("Authorization")
+		if r.Header.Get("X-Amz-Target") != "secretsmanager.GetSecretValue" || body["SecretId"] != "qwak/api-key" ||
+			!strings.HasPrefix(authorization, "AWS4-HMAC-SHA256 Credential=AKID/") ||
+			!strings.Contains(authorization, "/eu-west-1/secretsmanager/aws4_request") ||
+			!strings.Contains(authorization, "x-amz-security-token") {
+			w.WriteHeader(http.StatusBadRequest)
+			return
+		}
+		_, _ = w.Write([]byte(`{"Name": "qwak/api-key", "SecretString": "aws-key"}`))
+	}))
+	defer server.Close()
+
+	credentials, err := NewAWSSecretsManagerCredentials(AWSSecretsManagerOptions{
+		SecretId:        "qwak/api-key",
+		Region:          "eu-west-1",
+		AccessKeyId:     "AKID",
+		SecretAccessKey: "secret",
+		SessionToken:    "session",
+		Endpoint:        server.URL,
+	})
+	require.NoError(t, err)
+
+	apiKey, err := credentials.ApiKey(context.Background())
+	require.NoError(t, err)
+	require.Equal(t, "aws-key", apiKey)
+}
+
+func TestAWSSigningKey(t *testing.T) {
+	// example of the AWS signature version 4 documentation
+	key := awsSigningKey("wJalrXUtnFEMI/K7MDENG+bPxRfiCYEXAMPLEKEY", "20120215", "us-east-1", "iam")
+	require.Equal(t, "f4780e2d9f65fa895f9c67b32ce1baf0b0d8a43505a000a1a9e090d414db404d", hex.EncodeToString(key))
+}
+
+func TestGCPSecretManagerCredentials(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != "/v1/projects/acme/secrets/qwak-api-key/versions/latest:access" ||
+			r.Header.Get("Authorization") != "Bearer gcp-token" {
+			w.WriteHeader(http.StatusUnauthorized)
+			return
+		}
+		_, _ = w.Write([]byte(`{"payload": {"data": "Z2NwLWtleQ=="}}`))
+	}))
+	defer server.Close()
+
+	credentials, err := NewGCPSecretManagerCredentials(GCPSecretManagerOptions{
+		Project:     "acme",
+		Secret:      "qwak-api-key",
+		Endpoint:    server.URL,
+		AccessToken: func(context.Context) (string, error) { return "gcp-token", nil },
+	})
+	require.NoError(t, err)
+
+	apiKey, err := credentials.ApiKey(context.Background())
+	require.NoError(t, err)
+	require.Equal(t, "gcp-key", apiKey)
+}
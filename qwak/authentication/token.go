@@ -0,0 +1,33 @@
+package authentication
+
+import (
+	"context"
+	"errors"
+)
+
+// TokenSource returns the bearer token authorizing prediction requests. Authenticator is the default
+// token source, exchanging the api key for a token at the Qwak authentication endpoint
+type TokenSource interface {
+	GetToken(ctx context.Context) (string, error)
+}
+
+var _ TokenSource = (*Authenticator)(nil)
+
+// TokenSourceFunc adapts a function to a TokenSource, e.g. to read a token managed by a sidecar
+type TokenSourceFunc func(ctx context.Context) (string, error)
+
+// GetToken calls the function
+func (f TokenSourceFunc) GetToken(ctx context.Context) (string, error) {
+	return f(ctx)
+}
+
+// StaticToken is a bearer token used as is, without calling the authentication endpoint
+type StaticToken string
+
+// GetToken returns the token
+func (t StaticToken) GetToken(context.Context) (string, error) {
+	if t == "" {
+		return "", errors.New("bearer token is empty")
+	}
+	return string(t), nil
+}
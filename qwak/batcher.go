@@ -0,0 +1,224 @@
+package qwak
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sync"
+	"time"
+)
+
+const (
+	DefaultBatchMaxRows  = 32
+	DefaultBatchMaxDelay = 5 * time.Millisecond
+)
+
+// ErrBatcherClosed is returned by predictions submitted to a closed Batcher
+var ErrBatcherClosed = errors.New("batcher is closed")
+
+// BatcherOptions configures a Batcher
+type BatcherOptions struct {
+	// MaxRows the number of rows triggering a batch to be sent, default to 32
+	MaxRows int
+	// MaxDelay the longest time a prediction waits for a batch to fill, default to 5 milliseconds
+	MaxDelay time.Duration
+}
+
+// Batcher queues predictions of the same model for up to MaxDelay or MaxRows rows, and sends them as a single
+// request whose results are split back to each caller, trading a little latency for throughput.
+// A batch request is bound to the latest deadline of its callers, and is not bound to any deadline if one of them
+// has none. Per request idempotency keys are not sent by batch requests. It is safe for concurrent use
+type Batcher struct {
+	predictor Predictor
+	maxRows   int
+	maxDelay  time.Duration
+
+	lock    sync.Mutex
+	batches map[string]*pendingBatch
+	closed  bool
+	flushes sync.WaitGroup
+}
+
+var _ Predictor = (*Batcher)(nil)
+
+type pendingBatch struct {
+	modelId string
+	calls   []*batchedCall
+	rows    int
+	timer   *time.Timer
+}
+
+type batchedCall struct {
+	ctx     context.Context
+	request *PredictionRequest
+	vectors []*FeatureVector
+	result  chan batchedResult
+}
+
+type batchedResult struct {
+	response *PredictionResponse
+	err      error
+}
+
+// NewBatcher is a constructor for a Batcher sending its batches with predictor, usually a RealTimeClient
+func NewBatcher(predictor Predictor, options BatcherOptions) *Batcher {
+	if options.MaxRows <= 0 {
+		options.MaxRows = DefaultBatchMaxRows
+	}
+
+	if options.MaxDelay <= 0 {
+		options.MaxDelay = DefaultBatchMaxDelay
+	}
+
+	return &Batcher{
+		predictor: predictor,
+		maxRows:   options.MaxRows,
+		maxDelay:  options.MaxDelay,
+		batches:   map[string]*pendingBatch{},
+	}
+}
+
+// Predict queues the request feature vectors into the next batch of its model
+func (b *Batcher) Predict(predictionRequest *PredictionRequest) (*PredictionResponse, error) {
+	return b.PredictWithCtx(context.Background(), predictionRequest)
+}
+
+// PredictWithCtx queues the request feature vectors into the next batch of its model and waits for their results.
+// Requests of MaxRows rows or more are sent on their own
+func (b *Batcher) PredictWithCtx(ctx context.Context, predictionRequest *PredictionRequest) (*PredictionResponse, error) {
+	if len(predictionRequest.modelId) == 0 {
+		return nil, errors.New("model id is missing in request")
+	}
+
+	if len(predictionRequest.featuresVector) >= b.maxRows {
+		return b.predictor.PredictWithCtx(ctx, predictionRequest)
+	}
+
+	call := &batchedCall{
+		ctx:     ctx,
+		request: predictionRequest,
+		vectors: predictionRequest.featuresVector,
+		result:  make(chan batchedResult, 1),
+	}
+
+	if err := b.enqueue(predictionRequest.modelId, call); err != nil {
+		return nil, err
+	}
+
+	select {
+	case <-ctx.Done():
+		return nil, fmt.Errorf("qwak client failed to predict: %w", ctx.Err())
+	case result := <-call.result:
+		return result.response, result.err
+	}
+}
+
+// Close sends the pending batches and waits for all batches to complete.
+// Predictions submitted after Close fail with ErrBatcherClosed
+func (b *Batcher) Close() {
+	b.lock.Lock()
+	b.closed = true
+	for _, batch := range b.batches {
+		b.flushLocked(batch)
+	}
+	b.lock.Unlock()
+
+	b.flushes.Wait()
+}
+
+func (b *Batcher) enqueue(modelId string, call *batchedCall) error {
+	b.lock.Lock()
+	defer b.lock.Unlock()
+
+	if b.closed {
+		return ErrBatcherClosed
+	}
+
+	batch, ok := b.batches[modelId]
+	if !ok {
+		batch = &pendingBatch{modelId: modelId}
+		b.batches[modelId] = batch
+		batch.timer = time.AfterFunc(b.maxDelay, func() {
+			b.lock.Lock()
+			defer b.lock.Unlock()
+			if b.batches[modelId] == batch {
+				b.flushLocked(batch)
+			}
+		})
+	}
+
+	batch.calls = append(batch.calls, call)
+	batch.rows += len(call.vectors)
+
+	if batch.rows >= b.maxRows {
+		b.flushLocked(batch)
+	}
+
+	return nil
+}
+
+// flushLocked sends the batch in the background, it must be called with the lock held
+func (b *Batcher) flushLocked(batch *pendingBatch) {
+	batch.timer.Stop()
+	delete(b.batches, batch.modelId)
+
+	b.flushes.Add(1)
+	go func() {
+		defer b.flushes.Done()
+		b.send(batch)
+	}()
+}
+
+func (b *Batcher) send(batch *pendingBatch) {
+	ctx, cancel := batchContext(batch.calls)
+	defer cancel()
+
+	request := NewPredictionRequest(batch.modelId)
+	for _, call := range batch.calls {
+		request.AddFeatureVectors(call.vectors...)
+	}
+
+	response, err := b.predictor.PredictWithCtx(ctx, request)
+
+	if err == nil && len(response.predictions) != batch.rows {
+		err = fmt.Errorf("qwak batch prediction failed - model returned %d results for %d rows", len(response.predictions), batch.rows)
+	}
+
+	offset := 0
+	for _, call := range batch.calls {
+		if err != nil {
+			call.result <- batchedResult{err: err}
+			continue
+		}
+
+		callResponse := &PredictionResponse{
+			predictions: response.predictions[offset : offset+len(call.vectors)],
+			shared:      true,
+			request:     call.request,
+		}
+		offset += len(call.vectors)
+
+		if expectationErr := call.request.checkExpectedColumns(callResponse); expectationErr != nil {
+			call.result <- batchedResult{err: expectationErr}
+			continue
+		}
+		call.result <- batchedResult{response: callResponse}
+	}
+}
+
+// batchContext returns a context bound to the latest deadline of the calls, if all of them have one
+func batchContext(calls []*batchedCall) (context.Context, context.CancelFunc) {
+	var latest time.Time
+
+	for _, call := range calls {
+		deadline, ok := call.ctx.Deadline()
+		if !ok {
+			return context.WithCancel(context.Background())
+		}
+		if deadline.After(latest) {
+			latest = deadline
+		}
+	}
+
+	return context.WithDeadline(context.Background(), latest)
+}
@@ -0,0 +1,127 @@
+package qwak
+
+import (
+	"container/list"
+	"crypto/sha256"
+	"encoding/hex"
+	"sync"
+	"time"
+)
+
+// PredictionCache stores prediction responses by a key identifying the model and the feature vectors.
+// Implementations must be safe for concurrent use. Cached responses are shared between callers
+type PredictionCache interface {
+	// Get returns the response stored for key, if any
+	Get(key string) (*PredictionResponse, bool)
+	// Set stores the response of key
+	Set(key string, response *PredictionResponse)
+}
+
+// predictionCacheKey identifies a request by its model id and the hash of its encoded feature vectors
+func predictionCacheKey(modelId string, body []byte) string {
+	hash := sha256.New()
+	hash.Write([]byte(modelId))
+	hash.Write([]byte{0})
+	hash.Write(body)
+	return hex.EncodeToString(hash.Sum(nil))
+}
+
+type memoryCacheEntry struct {
+	key       string
+	response  *PredictionResponse
+	expiresAt time.Time
+}
+
+// MemoryPredictionCache is an in-memory PredictionCache evicting entries after a ttl,
+// and the least recently used entries when full
+type MemoryPredictionCache struct {
+	lock       sync.Mutex
+	ttl        time.Duration
+	maxEntries int
+	entries    map[string]*list.Element
+	recency    *list.List
+	now        func() time.Time
+}
+
+var _ PredictionCache = (*MemoryPredictionCache)(nil)
+
+// NewMemoryPredictionCache is a constructor for MemoryPredictionCache holding up to maxEntries responses for ttl
+func NewMemoryPredictionCache(maxEntries int, ttl time.Duration) *MemoryPredictionCache {
+	if maxEntries < 1 {
+		maxEntries = 1
+	}
+
+	return &MemoryPredictionCache{
+		ttl:        ttl,
+		maxEntries: maxEntries,
+		entries:    map[string]*list.Element{},
+		recency:    list.New(),
+		now:        time.Now,
+	}
+}
+
+// Get returns the response stored for key, unless it expired
+func (m *MemoryPredictionCache) Get(key string) (*PredictionResponse, bool) {
+	m.lock.Lock()
+	defer m.lock.Unlock()
+
+	element, ok := m.entries[key]
+	if !ok {
+		return nil, false
+	}
+
+	entry := element.Value.(*memoryCacheEntry)
+	if !m.now().Before(entry.expiresAt) {
+		m.remove(element)
+		return nil, false
+	}
+
+	m.recency.MoveToFront(element)
+	return entry.response, true
+}
+
+// Set stores the response of key, evicting the least recently used entry when the cache is full
+func (m *MemoryPredictionCache) Set(key string, response *PredictionResponse) {
+	m.lock.Lock()
+	defer m.lock.Unlock()
+
+	expiresAt := m.now().Add(m.ttl)
+
+	if element, ok := m.entries[key]; ok {
+		entry := element.Value.(*memoryCacheEntry)
+		entry.response = response
+		entry.expiresAt = expiresAt
+		m.recency.MoveToFront(element)
+		return
+	}
+
+	for m.recency.Len() >= m.maxEntries {
+		m.remove(m.recency.Back())
+	}
+
+	m.entries[key] = m.recency.PushFront(&memoryCacheEntry{
+		key:       key,
+		response:  response,
+		expiresAt: expiresAt,
+	})
+}
+
+// Len returns the number of stored entries, including expired entries not evicted yet
+func (m *MemoryPredictionCache) Len() int {
+	m.lock.Lock()
+	defer m.lock.Unlock()
+	return m.recency.Len()
+}
+
+// Purge removes all entries
+func (m *MemoryPredictionCache) Purge() {
+	m.lock.Lock()
+	defer m.lock.Unlock()
+	m.entries = map[string]*list.Element{}
+	m.recency.Init()
+}
+
+func (m *MemoryPredictionCache) remove(element *list.Element) {
+	m.recency.Remove(element)
+	delete(m.entries, element.Value.(*memoryCacheEntry).key)
+}
@@ -0,0 +1,40 @@
+package qwak
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestMemoryPredictionCacheExpiresAndEvicts(t *testing.T) {
+	now := time.Now()
+	cache := NewMemoryPredictionCache(2, time.Minute)
+	cache.now = func() time.Time { return now }
+
+	first, second, third := &PredictionResponse{}, &PredictionResponse{}, &PredictionResponse{}
+	cache.Set("first", first)
+	cache.Set("second", second)
+
+	response, ok := cache.Get("first")
+	require.True(t, ok)
+	require.Same(t, first, response)
+
+	// "second" is the least recently used entry
+	cache.Set("third", third)
+	_, ok = cache.Get("second")
+	require.False(t, ok)
+	require.Equal(t, 2, cache.Len())
+
+	now = now.Add(time.Minute)
+	_, ok = cache.Get("third")
+	require.False(t, ok)
+}
+
+func TestPredictionCacheKeyDependsOnModelAndVectors(t *testing.T) {
+	body := []byte(`{"columns":["a"],"index":[0],"data":[[1]]}`)
+
+	require.Equal(t, predictionCacheKey("model", body), predictionCacheKey("model", body))
+	require.NotEqual(t, predictionCacheKey("model", body), predictionCacheKey("other", body))
+	require.NotEqual(t, predictionCacheKey("model", body), predictionCacheKey("model", []byte(`{}`)))
+}
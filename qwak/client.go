@@ -4,8 +4,10 @@ import (
 	"context"
 	"errors"
 	"fmt"
+	gohttp "net/http"
 	"net/url"
 	"regexp"
+	"strings"
 	"time"
 
 	"github.com/qwak-ai/go-sdk/qwak/authentication"
@@ -15,70 +17,360 @@ import (
 const (
 	PredictionPathUrlTemplate = "/v1/%s/predict"
 	PredictionBaseUrlTemplate = "https://models.%s.qwak.ai"
+	// LocalPredictionPath the prediction path of a model container running locally, e.g. with `qwak models run-local`
+	LocalPredictionPath = "/predict"
 )
 
+// Predictor is the prediction API of RealTimeClient. Depend on it instead of the concrete
+// client to be able to replace it in tests, see the qwaktest package
+type Predictor interface {
+	Predict(predictionRequest *PredictionRequest) (*PredictionResponse, error)
+	PredictWithCtx(ctx context.Context, predictionRequest *PredictionRequest) (*PredictionResponse, error)
+}
+
+var _ Predictor = (*RealTimeClient)(nil)
+
 // RealTimeClient is a client using to inference Qwak models
 type RealTimeClient struct {
-	authenticator *authentication.Authenticator
-	httpClient    http.Client
-	environment   string
-	RetryPolicy   http.RetryPolicy
-	url           string
+	tokenSource      authentication.TokenSource
+	authenticator    *authentication.Authenticator
+	httpClient       http.Client
+	streamingClient  http.Client
+	environment      string
+	RetryPolicy      http.RetryPolicy
+	url              string
+	codec            Codec
+	connectionStats  *http.ConnectionStatsCollector
+	idempotencyKeys  bool
+	cache            PredictionCache
+	coalesceRequests bool
+	coalesced        coalescedCalls
+	contextHeaders   []ContextHeaderExtractor
+	onPrediction     PredictionHook
+	endpoints        *endpointSelector
+	baseUrlTemplate  string
+	pathUrlTemplate  string
+	urlResolver      PredictionUrlResolver
+	strictResponses  bool
+	tags             map[string]string
+	fallback         FallbackHandler
+	featureEncoding  *FeatureEncoding
+	models           modelConfigs
+	limiter          *concurrencyLimiter
+	maxRequestBytes  int
+	requestHooks     *http.RequestHooks
+	clock            http.Clock
+	rateThrottle     *rateThrottle
+	strictDecoding   bool
 }
 
 // RealTimeClientConfig a set of configuration for the RealTimeClient
 type RealTimeClientConfig struct {
 	// ApiKey Your qwak API key
 	ApiKey string
+	// Optional Credentials provides the api key on each token renewal, default to
+	// authentication.DefaultCredentialsChain of ApiKey: ApiKey, the QWAK_API_KEY environment variable, then the
+	// credentials file of the qwak cli
+	Credentials authentication.CredentialsProvider
+	// Optional BearerToken authorize prediction requests with a static token instead of the api key, skipping the
+	// authentication endpoint, e.g. when a gateway in front of the models already authenticates with Qwak
+	BearerToken string
+	// Optional TokenSource provides the bearer token of prediction requests instead of the api key, e.g. a token
+	// refreshed by a sidecar. Mutually exclusive with BearerToken
+	TokenSource authentication.TokenSource
+	// Optional BackgroundContext bounds the background token renewals of the client, e.g. a context of the service
+	// lifetime, cancelling them once done. Close stops them in any case. Ignored with a TokenSource
+	BackgroundContext context.Context
+	// DisableAuthentication send prediction requests without authorization, e.g. to a model container running
+	// locally, see NewLocalClientConfig. No api key or token is needed, and setting one is an error
+	DisableAuthentication bool
 	// Environment the environment name
 	Environment string
 	// Optional set a full url directly to the model prediction endpoint
 	Url string
+	// Optional BaseUrlTemplate the base url of the model prediction endpoints, where %s is replaced by Environment,
+	// default to PredictionBaseUrlTemplate
+	BaseUrlTemplate string
+	// Optional PathUrlTemplate the path of a model prediction endpoint, where %s is replaced by the model id,
+	// default to PredictionPathUrlTemplate
+	PathUrlTemplate string
+	// Optional UrlResolver returns the full prediction url of a model, overriding the url templates
+	UrlResolver PredictionUrlResolver
+	// Optional FailoverUrls ordered base urls tried after Url, or after the environment url, when the preferred one
+	// is unreachable or keeps failing with server errors, e.g. a disaster recovery region
+	FailoverUrls []string
+	// FailoverCooldown how long a failed url is skipped before traffic recovers back to it, default to 30 seconds
+	FailoverCooldown time.Duration
+	// Optional override the url of the authentication endpoint
+	AuthEndpointUrl string
 	// RetryPolicy how to retry predict requests, default to no retry
 	RetryPolicy http.RetryPolicy
-	// RequestTimeout is the timeout of each http request the client performs
+	// RequestTimeout is the overall timeout of each http request the client performs, including connecting
+	// and reading the response, default to 5 seconds. A negative value disables it. Use the Transport
+	// DialTimeout, TLSHandshakeTimeout and ResponseHeaderTimeout to bound each phase independently.
+	// It does not bound PredictStreaming and PredictEach responses, which last as long as their context
 	RequestTimeout time.Duration
+	// Transport tunes the connection pool, dialer and timeouts of the http client, ignored when HttpClient
+	// or RoundTripper is set
+	Transport http.TransportOptions
+	// RoundTripper replaces the transport of the http client created by the constructor, e.g. a transport of an
+	// http middleware library. To keep the SDK transport and wrap it instead, use Transport.WrapRoundTripper.
+	// Mutually exclusive with HttpClient
+	RoundTripper gohttp.RoundTripper
 
 	// Deprecated: use PredictWithCtx
 	Context context.Context
 	// HttpClient override the http client created by the NewRealTimeClient constructor
 	HttpClient http.Client
+	// Codec override the json encoding of requests and decoding of responses, default to encoding/json
+	Codec Codec
+	// FeatureEncoding controls the encoding of time, duration and nested feature values
+	FeatureEncoding FeatureEncoding
+	// IdempotencyKeys send a generated idempotency key with each prediction, preserved across its retries,
+	// so retried requests are not counted twice by the server
+	IdempotencyKeys bool
+	// ConcurrencyLimit bounds the predictions of the client in flight, see also ModelConfig.ConcurrencyLimit.
+	// Not limited by default
+	ConcurrencyLimit ConcurrencyLimit
+	// MaxResponseBytes fails predictions whose response body is larger with http.ErrResponseTooLarge, without
+	// retrying them, so a misbehaving model can't exhaust the memory of the service. Default to
+	// http.DefaultMaxResponseBytes, a negative value disables it. Streaming predictions are not limited
+	MaxResponseBytes int64
+	// MaxRequestBytes fails predictions whose encoded request is larger with ErrRequestTooLarge, not limited when 0
+	MaxRequestBytes int
+	// Cache memoize responses of identical prediction requests, see NewMemoryPredictionCache. Disabled by default
+	Cache PredictionCache
+	// CoalesceIdenticalRequests share a single upstream call between concurrent requests with the same model
+	// and feature vectors, protecting models from duplicate scoring. The responses are shared between the callers
+	CoalesceIdenticalRequests bool
+	// ContextHeaders set headers of the prediction requests from values of the caller context, see HeaderFromContextKey
+	ContextHeaders []ContextHeaderExtractor
+	// Tags attribute the usage of every prediction request in Qwak analytics, e.g. to a team or a use case.
+	// Tags set on a request with PredictionRequest.WithTag override them
+	Tags map[string]string
+	// Fallback serves predictions which failed after all retries, e.g. with a default score when the model is down,
+	// see StaticFallback. Disabled by default
+	Fallback FallbackHandler
+	// RequestHooks are invoked on each attempt, retry and final outcome of the prediction requests,
+	// e.g. for custom logging and alerting. Set hooks on a single request context with http.WithRequestHooks
+	RequestHooks http.RequestHooks
+	// OnPrediction is invoked after each successful prediction, e.g. to tee inputs and outputs for offline analysis
+	OnPrediction PredictionHook
+	// StrictDecoding rejects authentication responses with unknown or missing fields, and prediction responses
+	// which are not json arrays of objects, e.g. a null body, instead of decoding them leniently
+	StrictDecoding bool
+	// StrictResponses fails predictions answered with no results, or with results missing columns
+	// other results have, instead of returning responses whose results callers must check
+	StrictResponses bool
+	// AdaptToRateLimits delays predictions to stay under the rate limit advertised by the model responses
+	// X-RateLimit-* and Retry-After headers, spreading requests over the rest of the rate limit window.
+	// The advertised limit is available in PredictionMeta.RateLimit either way
+	AdaptToRateLimits bool
+	// Clock tells the token expiry, the retry backoffs and the failover cooldowns, default to http.SystemClock.
+	// Replace it with a qwaktest.FakeClock to test these behaviors without waiting
+	Clock http.Clock
+	// CollectConnectionStats trace the connections of the client requests, read them with RealTimeClient.ConnectionStats
+	CollectConnectionStats bool
+}
+
+// PredictionUrlResolver returns the full prediction url of a model in an environment
+type PredictionUrlResolver func(environment string, modelId string) string
+
+// noTokenSource is the token source of clients with authentication disabled
+type noTokenSource struct{}
+
+func (noTokenSource) GetToken(context.Context) (string, error) {
+	return "", nil
+}
+
+// NewLocalClientConfig returns the configuration of a client predicting a model container running locally
+// at baseUrl, e.g. http://localhost:5000, without authentication. Requests of any model id are sent to the container
+func NewLocalClientConfig(baseUrl string) RealTimeClientConfig {
+	predictionUrl := strings.TrimSuffix(baseUrl, "/") + LocalPredictionPath
+	return RealTimeClientConfig{
+		DisableAuthentication: true,
+		UrlResolver: func(string, string) string {
+			return predictionUrl
+		},
+	}
 }
 
 // NewRealTimeClient is a constructor to initiate a RealTimeClient using to model predictions
 func NewRealTimeClient(options RealTimeClientConfig) (*RealTimeClient, error) {
 
-	if len(options.ApiKey) == 0 {
-		return nil, errors.New("api key is missing")
+	if options.BearerToken != "" && options.TokenSource != nil {
+		return nil, errors.New("bearer token and token source are mutually exclusive")
+	}
+
+	if options.DisableAuthentication && (options.ApiKey != "" || options.Credentials != nil ||
+		options.BearerToken != "" || options.TokenSource != nil) {
+		return nil, errors.New("disabled authentication and credentials are mutually exclusive")
+	}
+
+	if options.BearerToken != "" {
+		options.TokenSource = authentication.StaticToken(options.BearerToken)
+	}
+
+	if options.DisableAuthentication {
+		options.TokenSource = noTokenSource{}
+	}
+
+	if options.TokenSource == nil && options.Credentials == nil {
+		credentials := authentication.DefaultCredentialsChain(options.ApiKey)
+		if _, err := credentials.ApiKey(context.Background()); err != nil {
+			return nil, fmt.Errorf("api key is missing: %w", err)
+		}
+		options.Credentials = credentials
 	}
 
-	if len(options.Environment) == 0 && options.Url == "" {
+	customBaseUrlTemplate := options.BaseUrlTemplate != ""
+	if !customBaseUrlTemplate {
+		options.BaseUrlTemplate = PredictionBaseUrlTemplate
+	}
+
+	if options.PathUrlTemplate == "" {
+		options.PathUrlTemplate = PredictionPathUrlTemplate
+	}
+
+	if !strings.Contains(options.PathUrlTemplate, "%s") {
+		return nil, errors.New("path url template must contain a %s placeholder for the model id")
+	}
+
+	if len(options.Environment) == 0 && options.Url == "" && options.UrlResolver == nil &&
+		strings.Contains(options.BaseUrlTemplate, "%s") {
 		return nil, errors.New("environment or url variables are mandatory")
 	}
 
+	// the template is checked alone, environments accepted by the default template are not rejected
+	if customBaseUrlTemplate && options.Url == "" && options.UrlResolver == nil &&
+		!isValidURL(formatUrlTemplate(options.BaseUrlTemplate, "environment")) {
+		return nil, errors.New("base url template is not valid")
+	}
+
 	if options.Url != "" && !isValidURL(options.Url) {
 		return nil, errors.New("url is not valid")
 	}
 
+	for _, failoverUrl := range options.FailoverUrls {
+		if !isValidURL(failoverUrl) {
+			return nil, fmt.Errorf("failover url '%s' is not valid", failoverUrl)
+		}
+	}
+
+	if options.AuthEndpointUrl != "" && !isValidURL(options.AuthEndpointUrl) {
+		return nil, errors.New("auth endpoint url is not valid")
+	}
+
+	if options.Transport.Proxy != nil {
+		if err := options.Transport.Proxy.Validate(); err != nil {
+			return nil, err
+		}
+	}
+
+	if options.Transport.LoadBalancing != nil {
+		if err := options.Transport.LoadBalancing.Validate(); err != nil {
+			return nil, err
+		}
+	}
+
 	if options.RequestTimeout == 0 {
 		options.RequestTimeout = 5 * time.Second
 	}
 
+	if options.Clock == nil {
+		options.Clock = http.SystemClock
+	}
+
+	if options.MaxResponseBytes == 0 {
+		options.MaxResponseBytes = http.DefaultMaxResponseBytes
+	}
+
+	if options.HttpClient != nil && options.RoundTripper != nil {
+		return nil, errors.New("http client and round tripper are mutually exclusive")
+	}
+
 	if options.HttpClient == nil {
-		client := http.GetDefaultHttpClient()
+		client := &gohttp.Client{Transport: options.RoundTripper}
+		if options.RoundTripper == nil {
+			client = http.GetHttpClient(options.Transport)
+		}
 		client.Timeout = options.RequestTimeout
+		if options.RequestTimeout < 0 {
+			client.Timeout = 0
+		}
 		options.HttpClient = client
 	}
 
+	var connectionStats *http.ConnectionStatsCollector
+	if options.CollectConnectionStats {
+		connectionStats = http.NewConnectionStatsCollector()
+	}
+
+	var endpoints *endpointSelector
+	if len(options.FailoverUrls) > 0 {
+		// an empty primary url is resolved from the environment
+		endpoints = newEndpointSelector(append([]string{options.Url}, options.FailoverUrls...), options.FailoverCooldown)
+		endpoints.now = options.Clock.Now
+	}
+
+	// streamed responses are bounded by their context only, the timeout of a go http client would cut them
+	streamingClient := options.HttpClient
+	if client, ok := options.HttpClient.(*gohttp.Client); ok && client.Timeout > 0 {
+		untimed := *client
+		untimed.Timeout = 0
+		streamingClient = &untimed
+	}
+
+	var requestHooks *http.RequestHooks
+	if options.RequestHooks.OnRequest != nil || options.RequestHooks.OnRetry != nil || options.RequestHooks.OnResponse != nil {
+		requestHooks = &options.RequestHooks
+	}
+
+	var authenticator *authentication.Authenticator
+	tokenSource := options.TokenSource
+	if tokenSource == nil {
+		authenticator = authentication.NewAuthenticator(&authentication.AuthenticatorOptions{
+			Credentials:     options.Credentials,
+			HttpClient:      options.HttpClient,
+			AuthEndpointUrl: options.AuthEndpointUrl,
+			Clock:           options.Clock,
+			StrictDecoding:  options.StrictDecoding,
+		})
+		if options.BackgroundContext != nil {
+			authenticator.Start(options.BackgroundContext)
+		}
+		tokenSource = authenticator
+	}
+
 	return &RealTimeClient{
-		authenticator: authentication.NewAuthenticator(&authentication.AuthenticatorOptions{
-			ApiKey:     options.ApiKey,
-			HttpClient: options.HttpClient,
-		}),
-		httpClient:  options.HttpClient,
-		environment: options.Environment,
-		url:         options.Url,
-		RetryPolicy: options.RetryPolicy,
+		tokenSource:      tokenSource,
+		authenticator:    authenticator,
+		httpClient:       http.LimitResponseSize(options.HttpClient, options.MaxResponseBytes),
+		streamingClient:  streamingClient,
+		environment:      options.Environment,
+		url:              options.Url,
+		RetryPolicy:      options.RetryPolicy,
+		codec:            options.Codec,
+		connectionStats:  connectionStats,
+		idempotencyKeys:  options.IdempotencyKeys,
+		cache:            options.Cache,
+		coalesceRequests: options.CoalesceIdenticalRequests,
+		contextHeaders:   options.ContextHeaders,
+		onPrediction:     options.OnPrediction,
+		endpoints:        endpoints,
+		baseUrlTemplate:  options.BaseUrlTemplate,
+		pathUrlTemplate:  options.PathUrlTemplate,
+		urlResolver:      options.UrlResolver,
+		strictResponses:  options.StrictResponses,
+		tags:             options.Tags,
+		fallback:         options.Fallback,
+		featureEncoding:  &options.FeatureEncoding,
+		limiter:          newConcurrencyLimiter(options.ConcurrencyLimit),
+		maxRequestBytes:  options.MaxRequestBytes,
+		requestHooks:     requestHooks,
+		clock:            options.Clock,
+		strictDecoding:   options.StrictDecoding,
+		rateThrottle:     newRateThrottle(options.AdaptToRateLimits, options.Clock),
 	}, nil
 }
 
@@ -94,8 +386,8 @@ func isValidURL(input string) bool {
 		return false
 	}
 
-	// Check if the host is DNS-compatible
-	if !isValidHost(u.Host) {
+	// Check if the host (without port) is DNS-compatible
+	if !isValidHost(u.Hostname()) {
 		return false
 	}
 
@@ -110,12 +402,20 @@ func isValidHost(host string) bool {
 	return regex.MatchString(host)
 }
 
-func getPredictionUrl(environment string, modelId string, url string) string {
-	if url != "" {
-		return url + fmt.Sprintf(PredictionPathUrlTemplate, modelId)
+// getPredictionUrl returns the prediction url of a model served under baseUrl,
+// or under the environment base url when baseUrl is empty
+func (c *RealTimeClient) getPredictionUrl(modelId string, baseUrl string) string {
+	if baseUrl == "" && c.urlResolver != nil {
+		return c.urlResolver(c.environment, modelId)
 	}
-	return fmt.Sprintf(PredictionBaseUrlTemplate, environment) +
-		fmt.Sprintf(PredictionPathUrlTemplate, modelId)
+	if baseUrl == "" {
+		baseUrl = formatUrlTemplate(c.baseUrlTemplate, c.environment)
+	}
+	return baseUrl + formatUrlTemplate(c.pathUrlTemplate, modelId)
+}
+
+func formatUrlTemplate(template string, value string) string {
+	return strings.Replace(template, "%s", value, 1)
 }
 
 // Predict using to perform an inference on your models hosting in Qwak
@@ -129,35 +429,291 @@ func (c *RealTimeClient) PredictWithCtx(ctx context.Context, predictionRequest *
 		return nil, errors.New("model id is missing in request")
 	}
 
-	token, err := c.authenticator.GetToken(ctx)
+	start := time.Now()
+	ctx, done := c.traceRequest(ctx)
+	defer done()
+	ctx, cancel := c.withModelTimeout(ctx, predictionRequest.modelId)
+	defer cancel()
+
+	body, err := encodeRequestBody(c.codec, c.featureEncoding, predictionRequest)
+
+	if err != nil {
+		return nil, fmt.Errorf("qwak client failed to encode prediction request: %w", err)
+	}
+
+	if err := c.checkRequestSize(body); err != nil {
+		return nil, err
+	}
+
+	// predictions authorized by a context token are neither cached nor coalesced, so callers
+	// cannot be served responses obtained with the credentials of others
+	_, tokenOverridden := tokenFromContext(ctx)
+	useCache := c.cache != nil && !tokenOverridden
+	coalesce := c.coalesceRequests && !tokenOverridden
+
+	var requestKey string
+	if useCache || coalesce {
+		requestKey = predictionCacheKey(predictionRequest.modelId, body)
+	}
+
+	if useCache {
+		if response, ok := c.cache.Get(requestKey); ok {
+			if err := predictionRequest.checkExpectedColumns(response); err != nil {
+				return nil, err
+			}
+			return c.completePrediction(predictionRequest, response, start, true), nil
+		}
+	}
+
+	var response *PredictionResponse
+	if coalesce {
+		response, err = c.doPredictCoalesced(ctx, predictionRequest, body, requestKey)
+	} else {
+		response, err = c.predict(ctx, predictionRequest, body)
+	}
+
+	if err != nil {
+		return c.predictFallback(ctx, predictionRequest, err, start)
+	}
+
+	if c.strictResponses {
+		if err := response.checkComplete(); err != nil {
+			response.Release()
+			return nil, fmt.Errorf("qwak client failed to predict: %w", err)
+		}
+	}
+
+	if useCache {
+		response.shared = true
+		c.cache.Set(requestKey, response)
+	}
+
+	if err := predictionRequest.checkExpectedColumns(response); err != nil {
+		response.Release()
+		return nil, err
+	}
+
+	return c.completePrediction(predictionRequest, response, start, false), nil
+}
+
+// completePrediction attaches the response to the request it answers with the call metadata,
+// and invokes the prediction hook
+func (c *RealTimeClient) completePrediction(predictionRequest *PredictionRequest, response *PredictionResponse, start time.Time, cached bool) *PredictionResponse {
+	view := response.forRequest(predictionRequest)
+	view.meta.ModelId = predictionRequest.modelId
+	view.meta.Latency = time.Since(start)
+	view.meta.Cached = cached
+	if cached {
+		view.meta.Attempts = 0
+	}
+
+	if c.onPrediction != nil {
+		c.onPrediction(predictionRequest, view, view.meta)
+	}
+
+	return view
+}
+
+// doPredictCoalesced shares a single upstream call between concurrent identical requests.
+// A caller stops waiting when its own context is done. The shared call is detached from the context
+// of the caller which started it, as batched calls are, so the other callers still get the result when
+// it gives up. The call keeps the values of that context, is bound by the model timeout, and is cancelled
+// once no caller waits for it anymore
+func (c *RealTimeClient) doPredictCoalesced(ctx context.Context, predictionRequest *PredictionRequest, body []byte, requestKey string) (*PredictionResponse, error) {
+	results, leave := c.coalesced.do(ctx, requestKey, func(callCtx context.Context) (interface{}, error) {
+		sharedCtx, cancel := c.withModelTimeout(callCtx, predictionRequest.modelId)
+		defer cancel()
+
+		response, err := c.predict(sharedCtx, predictionRequest, body)
+		if err != nil {
+			return nil, err
+		}
+		// marked before being handed to the callers, which may be several
+		response.shared = true
+		return response, nil
+	})
+	defer leave()
+
+	select {
+	case <-ctx.Done():
+		return nil, fmt.Errorf("qwak client failed to predict: %w", ctx.Err())
+	case result := <-results:
+		if result.Err != nil {
+			return nil, result.Err
+		}
+		return result.Val.(*PredictionResponse), nil
+	}
+}
+
+// detachedContext keeps the values of its parent, e.g. request hooks and context headers,
+// but neither its deadline nor its cancellation
+type detachedContext struct {
+	parent context.Context
+}
+
+func (detachedContext) Deadline() (time.Time, bool) {
+	return time.Time{}, false
+}
+
+func (detachedContext) Done() <-chan struct{} {
+	return nil
+}
+
+func (detachedContext) Err() error {
+	return nil
+}
+
+func (c detachedContext) Value(key interface{}) interface{} {
+	return c.parent.Value(key)
+}
+
+// newPredictionHttpRequest builds the http request of an encoded prediction request authorized with token
+func (c *RealTimeClient) newPredictionHttpRequest(ctx context.Context, predictionRequest *PredictionRequest, body []byte, baseUrl string, token string) (*gohttp.Request, error) {
+	predictionUrl := c.getPredictionUrl(predictionRequest.modelId, baseUrl)
+	request, err := http.GetPredictionRequestWithBody(ctx, predictionUrl, token, body)
+
+	if err != nil {
+		return nil, err
+	}
+
+	if err := c.decorateRequest(ctx, request, predictionRequest); err != nil {
+		return nil, err
+	}
+
+	return request, nil
+}
+
+// doPredict sends an encoded prediction request to the model and parses its response
+func (c *RealTimeClient) doPredict(ctx context.Context, predictionRequest *PredictionRequest, body []byte, baseUrl string) (*PredictionResponse, error) {
+	token, err := c.getToken(ctx)
 
 	if err != nil {
 		return nil, fmt.Errorf("qwak client failed to predict: %s", err.Error())
 	}
 
-	pandaOrientedDf := predictionRequest.asPandaOrientedDf()
-	predictionUrl := getPredictionUrl(c.environment, predictionRequest.modelId, c.url)
-	request, err := http.GetPredictionRequest(ctx, predictionUrl, token, pandaOrientedDf)
+	if c.requestHooks != nil {
+		ctx = http.WithRequestHooks(ctx, *c.requestHooks)
+	}
+
+	request, err := c.newPredictionHttpRequest(ctx, predictionRequest, body, baseUrl, token)
 
 	if err != nil {
 		return nil, fmt.Errorf("qwak client failed to predict: %s", err.Error())
 	}
 
-	responseBody, statusCode, err := http.DoRequestWithRetry(c.httpClient, request, c.RetryPolicy)
+	if err := c.rateThrottle.wait(ctx); err != nil {
+		return nil, fmt.Errorf("qwak client failed to predict: %w", err)
+	}
+
+	result, err := http.DoRequest(c.httpClient, request, c.retryPolicyFor(predictionRequest.modelId))
+
+	rateLimit, hasRateLimit := http.ParseRateLimit(result.Header, c.clock.Now())
+	if hasRateLimit {
+		c.rateThrottle.observe(rateLimit)
+	}
 
 	if err != nil {
 		return nil, fmt.Errorf("qwak client failed to send predict request: %w", err)
 	}
 
-	if statusCode != 200 {
-		return nil, fmt.Errorf("qwak prediction failed - model respond with status code %d. response: %s", statusCode, responseBody)
+	if result.StatusCode != 200 {
+		return nil, fmt.Errorf("qwak prediction failed - model respond with status code %d. response: %s", result.StatusCode, result.Body)
 	}
 
-	response, err := responseFromRaw(responseBody)
+	response, err := responseFromRawWithCodec(result.Body, c.codec)
+
+	if err == nil && c.strictDecoding {
+		if err = checkArrayOfObjects(result.Body, response); err != nil {
+			response.Release()
+		}
+	}
 
 	if err != nil {
 		return nil, fmt.Errorf("qwak client failed to parse response from model: %s", err.Error())
 	}
 
+	response.meta = PredictionMeta{
+		ModelId:      predictionRequest.modelId,
+		Attempts:     result.Attempts,
+		StatusCode:   result.StatusCode,
+		RequestId:    result.Header.Get(http.RequestIdHeader),
+		ServerTiming: parseServerTiming(result.Header.Values(http.ServerTimingHeader)),
+	}
+	if hasRateLimit {
+		response.meta.RateLimit = &rateLimit
+	}
+
 	return response, nil
 }
+
+// ConnectionStats returns the connection usage of the client requests.
+// Stats are collected only when RealTimeClientConfig.CollectConnectionStats is set
+func (c *RealTimeClient) ConnectionStats() http.ConnectionStats {
+	if c.connectionStats == nil {
+		return http.ConnectionStats{}
+	}
+	return c.connectionStats.Snapshot()
+}
+
+func (c *RealTimeClient) traceRequest(ctx context.Context) (context.Context, func()) {
+	if c.connectionStats == nil {
+		return ctx, func() {}
+	}
+	return c.connectionStats.WithTrace(ctx), c.connectionStats.RequestStarted()
+}
+
+// setIdempotencyKey sets the request idempotency key header, when the prediction request has a key
+// or the client generates them. The header is kept by all the attempts of the request
+func (c *RealTimeClient) setIdempotencyKey(request *gohttp.Request, predictionRequest *PredictionRequest) error {
+	key := predictionRequest.idempotencyKey
+
+	if key == "" && c.idempotencyKeys {
+		generated, err := http.NewIdempotencyKey()
+		if err != nil {
+			return fmt.Errorf("failed to generate idempotency key: %w", err)
+		}
+		key = generated
+	}
+
+	if key != "" {
+		request.Header.Set(http.IdempotencyKeyHeader, key)
+	}
+
+	return nil
+}
+
+// TokenInfo returns the state of the client authentication token, to monitor credentials health.
+// It is the zero TokenInfo when the token is provided by a TokenSource not reporting its state
+func (c *RealTimeClient) TokenInfo() authentication.TokenInfo {
+	if reporter, ok := c.tokenSource.(interface {
+		TokenInfo() authentication.TokenInfo
+	}); ok {
+		return reporter.TokenInfo()
+	}
+	return authentication.TokenInfo{}
+}
+
+// Close stops the background work of the client, waiting for the running token renewals to return.
+// A TokenSource set in the configuration is left to its owner. The client must not be used after Close
+func (c *RealTimeClient) Close() {
+	if c.authenticator != nil {
+		c.authenticator.Stop()
+	}
+}
+
+// SetApiKey rotates the api key of the client, e.g. once rotated by a secret manager, keeping its connections.
+// The next prediction authenticates with the new key. It fails when the client is not authenticated by an api key,
+// i.e. with a BearerToken, a TokenSource or authentication disabled.
+// To resolve the api key on each token renewal instead, set RealTimeClientConfig.Credentials
+func (c *RealTimeClient) SetApiKey(apiKey string) error {
+	if c.authenticator == nil {
+		return errors.New("the client is not authenticated by an api key")
+	}
+
+	if apiKey == "" {
+		return errors.New("api key is empty")
+	}
+
+	c.authenticator.SetCredentials(authentication.StaticCredentials(apiKey))
+	return nil
+}
@@ -0,0 +1,87 @@
+package qwak
+
+// Clone returns a copy of the feature vector, which can be extended without changing the vector
+func (fr *FeatureVector) Clone() *FeatureVector {
+	clone := *fr
+	clone.features = append([]*feature(nil), fr.features...)
+	clone.frozen = false
+	return &clone
+}
+
+// Freeze makes the feature vector immutable, so it can be shared between requests and goroutines.
+// The methods setting features or the vector id of a frozen vector return a modified copy instead,
+// leaving the vector unchanged: use their result
+func (fr *FeatureVector) Freeze() *FeatureVector {
+	fr.frozen = true
+	return fr
+}
+
+// IsFrozen reports whether Freeze was called on the feature vector
+func (fr *FeatureVector) IsFrozen() bool {
+	return fr.frozen
+}
+
+// mutable returns the vector, or a copy of it when it is frozen
+func (fr *FeatureVector) mutable() *FeatureVector {
+	if fr.frozen {
+		return fr.Clone()
+	}
+	return fr
+}
+
+// Clone returns a copy of the request, which can be extended without changing the request.
+// Its feature vectors are cloned too, except frozen ones which are shared
+func (ir *PredictionRequest) Clone() *PredictionRequest {
+	clone := *ir
+	clone.frozen = false
+
+	clone.featuresVector = make([]*FeatureVector, len(ir.featuresVector))
+	for idx, vector := range ir.featuresVector {
+		if vector == nil || vector.frozen {
+			clone.featuresVector[idx] = vector
+			continue
+		}
+		clone.featuresVector[idx] = vector.Clone()
+	}
+
+	clone.expectedColumns = append([]expectedColumn(nil), ir.expectedColumns...)
+	if ir.tags != nil {
+		clone.tags = make(map[string]string, len(ir.tags))
+		for key, value := range ir.tags {
+			clone.tags[key] = value
+		}
+	}
+	if ir.defaults != nil {
+		clone.defaults = make(map[string]interface{}, len(ir.defaults))
+		for name, value := range ir.defaults {
+			clone.defaults[name] = value
+		}
+	}
+	return &clone
+}
+
+// Freeze makes the request and its feature vectors immutable, so it can be predicted concurrently and used
+// as a base for other requests. The fluent methods of a frozen request return a modified copy instead,
+// leaving the request unchanged: use their result
+func (ir *PredictionRequest) Freeze() *PredictionRequest {
+	for _, vector := range ir.featuresVector {
+		if vector != nil {
+			vector.Freeze()
+		}
+	}
+	ir.frozen = true
+	return ir
+}
+
+// IsFrozen reports whether Freeze was called on the request
+func (ir *PredictionRequest) IsFrozen() bool {
+	return ir.frozen
+}
+
+// mutable returns the request, or a copy of it when it is frozen
+func (ir *PredictionRequest) mutable() *PredictionRequest {
+	if ir.frozen {
+		return ir.Clone()
+	}
+	return ir
+}
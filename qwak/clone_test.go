@@ -0,0 +1,55 @@
+package qwak
+
+import (
+	"sync"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestCloneFeatureVector(t *testing.T) {
+	base := NewFeatureVector().WithFeature("age", 31)
+	first := base.Clone().WithFeature("plan", "basic")
+	second := base.Clone().WithFeature("plan", "pro")
+
+	_, ok := base.GetFeature("plan")
+	require.False(t, ok)
+	plan, _ := first.GetFeature("plan")
+	require.Equal(t, "basic", plan)
+	plan, _ = second.GetFeature("plan")
+	require.Equal(t, "pro", plan)
+}
+
+func TestFrozenRequest(t *testing.T) {
+	base := NewPredictionRequest("churn").
+		AddFeatureVector(NewFeatureVector().WithFeature("age", 31)).
+		WithTag("team", "growth").
+		Freeze()
+
+	var wg sync.WaitGroup
+	extended := make([]*PredictionRequest, 8)
+	for idx := range extended {
+		wg.Add(1)
+		go func(idx int) {
+			defer wg.Done()
+			extended[idx] = base.AddFeatureVector(NewFeatureVector().WithFeature("age", idx)).WithTag("worker", "yes")
+		}(idx)
+	}
+	wg.Wait()
+
+	require.Len(t, base.GetFeatureVectors(), 1)
+	require.Equal(t, map[string]string{"team": "growth"}, base.GetTags())
+	for _, request := range extended {
+		require.False(t, request.IsFrozen())
+		require.Len(t, request.GetFeatureVectors(), 2)
+		require.Len(t, request.GetTags(), 2)
+	}
+
+	frozenVector := base.GetFeatureVectors()[0]
+	require.True(t, frozenVector.IsFrozen())
+	changed := frozenVector.WithFeature("plan", "pro")
+	_, ok := frozenVector.GetFeature("plan")
+	require.False(t, ok)
+	_, ok = changed.GetFeature("plan")
+	require.True(t, ok)
+}
@@ -0,0 +1,61 @@
+package qwak
+
+import (
+	"context"
+	"sync"
+
+	"golang.org/x/sync/singleflight"
+)
+
+// coalescedCalls shares a single call between concurrent callers of the same key. The call is detached from
+// the context of the caller which started it, and cancelled once every caller stopped waiting for it
+type coalescedCalls struct {
+	lock  sync.Mutex
+	group singleflight.Group
+	calls map[string]*coalescedCall
+}
+
+type coalescedCall struct {
+	ctx     context.Context
+	cancel  context.CancelFunc
+	waiters int
+}
+
+// do joins the call of key, starting it with fn and the values of ctx if none is in flight.
+// The returned leave function must be called once the caller stops waiting for the result
+func (g *coalescedCalls) do(ctx context.Context, key string, fn func(ctx context.Context) (interface{}, error)) (<-chan singleflight.Result, func()) {
+	g.lock.Lock()
+	defer g.lock.Unlock()
+
+	if g.calls == nil {
+		g.calls = make(map[string]*coalescedCall)
+	}
+
+	call, ok := g.calls[key]
+	if !ok {
+		callCtx, cancel := context.WithCancel(detachedContext{parent: ctx})
+		call = &coalescedCall{ctx: callCtx, cancel: cancel}
+		g.calls[key] = call
+	}
+	call.waiters++
+
+	results := g.group.DoChan(key, func() (interface{}, error) {
+		return fn(call.ctx)
+	})
+	return results, func() { g.leave(key, call) }
+}
+
+func (g *coalescedCalls) leave(key string, call *coalescedCall) {
+	g.lock.Lock()
+	defer g.lock.Unlock()
+
+	call.waiters--
+	if call.waiters > 0 {
+		return
+	}
+
+	call.cancel()
+	delete(g.calls, key)
+	// the next callers start a new call rather than joining the cancelled one
+	g.group.Forget(key)
+}
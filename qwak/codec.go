@@ -0,0 +1,64 @@
+package qwak
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"io"
+)
+
+// Codec encodes prediction requests and decodes prediction responses.
+// Implement it to replace encoding/json with a faster json library, such as json-iterator or sonic
+type Codec interface {
+	// Marshal encodes the split oriented data frame of a prediction request
+	Marshal(value interface{}) ([]byte, error)
+	// Unmarshal decodes a model response into a *[]map[string]interface{}
+	Unmarshal(data []byte, value interface{}) error
+}
+
+// EncodingJSONCodec is a Codec using the standard library encoding/json package
+type EncodingJSONCodec struct {
+	// UseNumber decodes numbers as json.Number instead of float64, preserving large int64 ids
+	// and high precision decimals. Read them with GetValueAsInt64 or GetValueAsNumber
+	UseNumber bool
+}
+
+// Marshal encodes value with json.Marshal
+func (EncodingJSONCodec) Marshal(value interface{}) ([]byte, error) {
+	return json.Marshal(value)
+}
+
+// Unmarshal decodes data with json.Unmarshal, or with a json.Decoder when UseNumber is set
+func (c EncodingJSONCodec) Unmarshal(data []byte, value interface{}) error {
+	if !c.UseNumber {
+		return json.Unmarshal(data, value)
+	}
+
+	decoder := json.NewDecoder(bytes.NewReader(data))
+	decoder.UseNumber()
+	if err := decoder.Decode(value); err != nil {
+		return err
+	}
+
+	// json.Unmarshal rejects data following the value, the decoder would ignore it
+	if _, err := decoder.Token(); err != io.EOF {
+		return errors.New("invalid data after top-level value")
+	}
+	return nil
+}
+
+// defaultCodec is used when the client has no codec configured
+var defaultCodec Codec = EncodingJSONCodec{}
+
+// encodeRequestBody encodes the request with codec, or with the pooled streaming encoder when codec is nil
+func encodeRequestBody(codec Codec, encoding *FeatureEncoding, predictionRequest *PredictionRequest) ([]byte, error) {
+	if err := encoding.checkDuplicateFeatures(predictionRequest); err != nil {
+		return nil, err
+	}
+
+	if codec == nil {
+		return predictionRequest.encodeBodyWith(encoding)
+	}
+
+	return codec.Marshal(predictionRequest.asPandaOrientedDfWith(encoding))
+}
@@ -0,0 +1,41 @@
+package qwak
+
+// DataFrame is the subset of the github.com/go-gota/gota dataframe.DataFrame API used to build prediction
+// requests, so frames can be passed to the SDK without it depending on gota
+type DataFrame interface {
+	// Names returns the column names, in order
+	Names() []string
+	// Maps returns the rows as values keyed by column name
+	Maps() []map[string]interface{}
+}
+
+// NewPredictionRequestFromDataFrame returns a prediction request with a feature vector per row of the frame,
+// keeping the frame columns order
+func NewPredictionRequestFromDataFrame(modelId string, df DataFrame) *PredictionRequest {
+	names := df.Names()
+	rows := df.Maps()
+
+	request := NewPredictionRequest(modelId)
+	for _, row := range rows {
+		vector := NewFeatureVector()
+		for _, name := range names {
+			vector.WithFeature(name, row[name])
+		}
+		request.AddFeatureVector(vector)
+	}
+
+	return request
+}
+
+// Maps returns a copy of the results as rows keyed by column name, to materialize the response
+// into a gota frame with dataframe.LoadMaps
+func (pr *PredictionResponse) Maps() []map[string]interface{} {
+	rows := make([]map[string]interface{}, len(pr.predictions))
+	for idx, prediction := range pr.predictions {
+		rows[idx] = make(map[string]interface{}, len(prediction.valuesMap))
+		for column, value := range prediction.valuesMap {
+			rows[idx][column] = value
+		}
+	}
+	return rows
+}
@@ -0,0 +1,41 @@
+package qwak
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+type mapsFrame struct {
+	names []string
+	rows  []map[string]interface{}
+}
+
+func (f mapsFrame) Names() []string {
+	return f.names
+}
+
+func (f mapsFrame) Maps() []map[string]interface{} {
+	return f.rows
+}
+
+func TestDataFrameConversions(t *testing.T) {
+	frame := mapsFrame{
+		names: []string{"State", "Account_Length"},
+		rows: []map[string]interface{}{
+			{"State": "PPP", "Account_Length": 82},
+			{"State": "NY", "Account_Length": nil},
+		},
+	}
+
+	request := NewPredictionRequestFromDataFrame("model", frame)
+	body, err := request.encodeBody()
+	require.NoError(t, err)
+	require.JSONEq(t, `{"columns":["State","Account_Length"],"index":[0,1],"data":[["PPP",82],["NY",null]]}`, string(body))
+
+	response, err := responseFromRaw([]byte(`[{"churn":1},{"churn":0}]`))
+	require.NoError(t, err)
+	rows := response.Maps()
+	response.Release()
+	require.Equal(t, []map[string]interface{}{{"churn": 1.0}, {"churn": 0.0}}, rows)
+}
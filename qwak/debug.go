@@ -0,0 +1,56 @@
+package qwak
+
+import (
+	"context"
+	"fmt"
+	gohttp "net/http"
+	"sort"
+	"strings"
+)
+
+// DebugDump renders the http request a prediction would send, with the bearer token redacted, followed by
+// an equivalent curl command, to reproduce the call manually when triaging model issues. Nothing is sent,
+// and unlike DryRun the request is not validated
+func (c *RealTimeClient) DebugDump(ctx context.Context, predictionRequest *PredictionRequest) (string, error) {
+	result, err := c.dryRun(ctx, predictionRequest)
+	if err != nil {
+		return "", err
+	}
+
+	var dump strings.Builder
+	fmt.Fprintf(&dump, "%s %s\n", gohttp.MethodPost, result.Url)
+	for _, name := range sortedHeaderNames(result.Header) {
+		for _, value := range result.Header[name] {
+			fmt.Fprintf(&dump, "%s: %s\n", name, value)
+		}
+	}
+	fmt.Fprintf(&dump, "\n%s\n\n%s\n", result.Body, result.Curl())
+	return dump.String(), nil
+}
+
+// Curl returns a curl command sending the request. The bearer token must be replaced before running it
+func (r *DryRunResult) Curl() string {
+	var command strings.Builder
+	fmt.Fprintf(&command, "curl -X %s %s", gohttp.MethodPost, shellQuote(r.Url))
+	for _, name := range sortedHeaderNames(r.Header) {
+		for _, value := range r.Header[name] {
+			fmt.Fprintf(&command, " \\\n  -H %s", shellQuote(name+": "+value))
+		}
+	}
+	fmt.Fprintf(&command, " \\\n  --data-raw %s", shellQuote(string(r.Body)))
+	return command.String()
+}
+
+func sortedHeaderNames(header gohttp.Header) []string {
+	names := make([]string, 0, len(header))
+	for name := range header {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// shellQuote quotes a value for posix shells
+func shellQuote(value string) string {
+	return "'" + strings.ReplaceAll(value, "'", `'\''`) + "'"
+}
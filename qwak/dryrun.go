@@ -0,0 +1,68 @@
+package qwak
+
+import (
+	"context"
+	"fmt"
+	gohttp "net/http"
+)
+
+// RedactedToken replaces the bearer token in requests rendered for debugging
+const RedactedToken = "REDACTED"
+
+// DryRunResult is the http request a prediction would send
+type DryRunResult struct {
+	// Url the prediction url of the model
+	Url string
+	// Header the request headers, the bearer token is replaced by RedactedToken
+	Header gohttp.Header
+	// Body the exact encoded request body
+	Body []byte
+}
+
+// DryRun builds and validates the prediction request as PredictWithCtx would, without sending it nor
+// authenticating, and returns the http request that would be sent. The request is validated with
+// PredictionRequest.Validate, and its encoded size is checked against RealTimeClientConfig.MaxRequestBytes
+func (c *RealTimeClient) DryRun(ctx context.Context, predictionRequest *PredictionRequest) (*DryRunResult, error) {
+	if err := predictionRequest.validateWith(c.featureEncoding); err != nil {
+		return nil, err
+	}
+
+	return c.dryRun(ctx, predictionRequest)
+}
+
+// dryRun builds the http request of a prediction without validating it
+func (c *RealTimeClient) dryRun(ctx context.Context, predictionRequest *PredictionRequest) (*DryRunResult, error) {
+	body, err := encodeRequestBody(c.codec, c.featureEncoding, predictionRequest)
+	if err != nil {
+		return nil, fmt.Errorf("qwak client failed to encode prediction request: %w", err)
+	}
+
+	if err := c.checkRequestSize(body); err != nil {
+		return nil, err
+	}
+
+	token := RedactedToken
+	_, tokenOverridden := tokenFromContext(ctx)
+	if _, disabled := c.tokenSource.(noTokenSource); disabled && !tokenOverridden {
+		token = ""
+	}
+
+	request, err := c.newPredictionHttpRequest(ctx, predictionRequest, body, c.url, token)
+	if err != nil {
+		return nil, fmt.Errorf("qwak client failed to build prediction request: %w", err)
+	}
+
+	return &DryRunResult{
+		Url:    request.URL.String(),
+		Header: request.Header,
+		Body:   body,
+	}, nil
+}
+
+// checkRequestSize returns ErrRequestTooLarge when the encoded request exceeds the configured limit
+func (c *RealTimeClient) checkRequestSize(body []byte) error {
+	if c.maxRequestBytes > 0 && len(body) > c.maxRequestBytes {
+		return fmt.Errorf("%w: %d bytes, the limit is %d bytes", ErrRequestTooLarge, len(body), c.maxRequestBytes)
+	}
+	return nil
+}
@@ -0,0 +1,286 @@
+package qwak
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"math"
+	"strconv"
+	"sync"
+	"unicode/utf8"
+)
+
+// encoderState holds the scratch space reused between encodings of prediction requests
+type encoderState struct {
+	buf       bytes.Buffer
+	scratch   []byte
+	columns   []string
+	columnIdx map[string]int
+	row       []interface{}
+	encoding  *FeatureEncoding
+}
+
+var encoderStatePool = sync.Pool{
+	New: func() interface{} {
+		return &encoderState{columnIdx: map[string]int{}}
+	},
+}
+
+// maxPooledBufferSize prevents huge requests from pinning their buffers in the pool
+const maxPooledBufferSize = 1 << 20
+
+// encodeBody encodes the request as a split oriented data frame, the same as
+// json.Marshal(ir.asPandaOrientedDf()), reusing pooled buffers. Only the returned body is allocated.
+func (ir *PredictionRequest) encodeBody() ([]byte, error) {
+	return ir.encodeBodyWith(defaultFeatureEncoding)
+}
+
+// encodeBodyWith is encodeBody with feature values encoded according to encoding
+func (ir *PredictionRequest) encodeBodyWith(encoding *FeatureEncoding) ([]byte, error) {
+	ir = encoding.prepare(ir)
+	state := encoderStatePool.Get().(*encoderState)
+	defer releaseEncoderState(state)
+	state.encoding = encoding
+
+	if err := ir.encodeTo(state); err != nil {
+		return nil, err
+	}
+
+	body := make([]byte, state.buf.Len())
+	copy(body, state.buf.Bytes())
+	return body, nil
+}
+
+// EstimateSize returns the size in bytes of the encoded request body, e.g. to split batches exceeding
+// the body limit of a gateway before sending them. The size is exact for clients using the default
+// FeatureEncoding and Codec
+func (ir *PredictionRequest) EstimateSize() (int, error) {
+	state := encoderStatePool.Get().(*encoderState)
+	defer releaseEncoderState(state)
+	state.encoding = defaultFeatureEncoding
+
+	if err := defaultFeatureEncoding.prepare(ir).encodeTo(state); err != nil {
+		return 0, err
+	}
+
+	return state.buf.Len(), nil
+}
+
+func releaseEncoderState(state *encoderState) {
+	if state.buf.Cap() > maxPooledBufferSize {
+		return
+	}
+
+	state.buf.Reset()
+	state.columns = state.columns[:0]
+	for name := range state.columnIdx {
+		delete(state.columnIdx, name)
+	}
+	for idx := range state.row {
+		state.row[idx] = nil
+	}
+	state.row = state.row[:0]
+	state.encoding = nil
+	encoderStatePool.Put(state)
+}
+
+func (ir *PredictionRequest) encodeTo(state *encoderState) error {
+	template := ir.layoutTemplate()
+	if template != nil {
+		// the features of template rows are in the order of the template columns
+		state.columns = append(state.columns, template.columns...)
+	} else {
+		for _, vector := range ir.featuresVector {
+			for _, feature := range vector.features {
+				if _, ok := state.columnIdx[feature.name]; !ok {
+					state.columnIdx[feature.name] = len(state.columns)
+					state.columns = append(state.columns, feature.name)
+				}
+			}
+		}
+	}
+
+	buf := &state.buf
+	buf.WriteString(`{"columns":`)
+	if template != nil {
+		buf.Write(template.columnsJSON)
+	} else if len(state.columns) == 0 {
+		buf.WriteString("[]")
+	} else {
+		buf.WriteByte('[')
+		for idx, column := range state.columns {
+			if idx > 0 {
+				buf.WriteByte(',')
+			}
+			state.scratch = appendJSONString(state.scratch[:0], column)
+			buf.Write(state.scratch)
+		}
+		buf.WriteByte(']')
+	}
+
+	buf.WriteString(`,"index":[`)
+	for idx := range ir.featuresVector {
+		if idx > 0 {
+			buf.WriteByte(',')
+		}
+		state.scratch = strconv.AppendInt(state.scratch[:0], int64(idx), 10)
+		buf.Write(state.scratch)
+	}
+
+	buf.WriteString(`],"data":[`)
+	for vectorIdx, vector := range ir.featuresVector {
+		if vectorIdx > 0 {
+			buf.WriteByte(',')
+		}
+
+		state.row = state.row[:0]
+		if template != nil {
+			for _, feature := range vector.features {
+				state.row = append(state.row, state.encoding.encodeAny(feature.value))
+			}
+		} else {
+			for range state.columns {
+				state.row = append(state.row, nil)
+			}
+			for _, feature := range vector.features {
+				state.row[state.columnIdx[feature.name]] = state.encoding.encodeAny(feature.value)
+			}
+		}
+
+		buf.WriteByte('[')
+		for valueIdx, value := range state.row {
+			if valueIdx > 0 {
+				buf.WriteByte(',')
+			}
+			var err error
+			state.scratch, err = appendJSONValue(state.scratch[:0], value)
+			if err != nil {
+				return fmt.Errorf("failed to encode feature '%s': %w", state.columns[valueIdx], err)
+			}
+			buf.Write(state.scratch)
+		}
+		buf.WriteByte(']')
+	}
+	buf.WriteString("]}")
+
+	return nil
+}
+
+// appendJSONValue appends the json encoding of value, using fast paths for the common feature types
+func appendJSONValue(dst []byte, value interface{}) ([]byte, error) {
+	switch v := value.(type) {
+	case nil:
+		return append(dst, "null"...), nil
+	case string:
+		return appendJSONString(dst, v), nil
+	case bool:
+		return strconv.AppendBool(dst, v), nil
+	case int:
+		return strconv.AppendInt(dst, int64(v), 10), nil
+	case int8:
+		return strconv.AppendInt(dst, int64(v), 10), nil
+	case int16:
+		return strconv.AppendInt(dst, int64(v), 10), nil
+	case int32:
+		return strconv.AppendInt(dst, int64(v), 10), nil
+	case int64:
+		return strconv.AppendInt(dst, v, 10), nil
+	case uint:
+		return strconv.AppendUint(dst, uint64(v), 10), nil
+	case uint8:
+		return strconv.AppendUint(dst, uint64(v), 10), nil
+	case uint16:
+		return strconv.AppendUint(dst, uint64(v), 10), nil
+	case uint32:
+		return strconv.AppendUint(dst, uint64(v), 10), nil
+	case uint64:
+		return strconv.AppendUint(dst, v, 10), nil
+	case float64:
+		return appendJSONFloat(dst, v, 64)
+	case float32:
+		return appendJSONFloat(dst, float64(v), 32)
+	}
+
+	raw, err := json.Marshal(value)
+	if err != nil {
+		return dst, err
+	}
+	return append(dst, raw...), nil
+}
+
+// appendJSONFloat formats floats the same way encoding/json does
+func appendJSONFloat(dst []byte, value float64, bits int) ([]byte, error) {
+	if math.IsInf(value, 0) || math.IsNaN(value) {
+		return dst, fmt.Errorf("unsupported value: %s", strconv.FormatFloat(value, 'g', -1, bits))
+	}
+
+	format := byte('f')
+	if abs := math.Abs(value); abs != 0 {
+		if bits == 64 && (abs < 1e-6 || abs >= 1e21) || bits == 32 && (float32(abs) < 1e-6 || float32(abs) >= 1e21) {
+			format = 'e'
+		}
+	}
+
+	dst = strconv.AppendFloat(dst, value, format, -1, bits)
+	if format == 'e' {
+		// clean up e-09 to e-9
+		n := len(dst)
+		if n >= 4 && dst[n-4] == 'e' && dst[n-3] == '-' && dst[n-2] == '0' {
+			dst[n-2] = dst[n-1]
+			dst = dst[:n-1]
+		}
+	}
+
+	return dst, nil
+}
+
+const hexDigits = "0123456789abcdef"
+
+// appendJSONString appends a quoted json string, escaping the same characters as encoding/json
+func appendJSONString(dst []byte, value string) []byte {
+	dst = append(dst, '"')
+	start := 0
+	for idx := 0; idx < len(value); {
+		if b := value[idx]; b < utf8.RuneSelf {
+			if b >= 0x20 && b != '"' && b != '\\' && b != '<' && b != '>' && b != '&' {
+				idx++
+				continue
+			}
+			dst = append(dst, value[start:idx]...)
+			switch b {
+			case '"', '\\':
+				dst = append(dst, '\\', b)
+			case '\n':
+				dst = append(dst, '\\', 'n')
+			case '\r':
+				dst = append(dst, '\\', 'r')
+			case '\t':
+				dst = append(dst, '\\', 't')
+			default:
+				dst = append(dst, '\\', 'u', '0', '0', hexDigits[b>>4], hexDigits[b&0xF])
+			}
+			idx++
+			start = idx
+			continue
+		}
+
+		r, size := utf8.DecodeRuneInString(value[idx:])
+		if r == utf8.RuneError && size == 1 {
+			dst = append(dst, value[start:idx]...)
+			dst = append(dst, "\ufffd"...)
+			idx += size
+			start = idx
+			continue
+		}
+		if r == '\u2028' || r == '\u2029' {
+			dst = append(dst, value[start:idx]...)
+			dst = append(dst, '\\', 'u', '2', '0', '2', hexDigits[r&0xF])
+			idx += size
+			start = idx
+			continue
+		}
+		idx += size
+	}
+	dst = append(dst, value[start:]...)
+	return append(dst, '"')
+}
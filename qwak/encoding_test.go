@@ -0,0 +1,249 @@
+package qwak
+
+import (
+	"encoding/json"
+	"fmt"
+	"math/big"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestEncodeBodyMatchesJsonMarshal(t *testing.T) {
+	request := NewPredictionRequest("model").AddFeatureVectors(
+		NewFeatureVector().
+			WithFeature("string", "quote\" slash\\ <html> & \n\t\x01   \xff ünicode").
+			WithFeature("int", -42).
+			WithFeature("uint8", uint8(7)).
+			WithFeature("float", 12.5).
+			WithFeature("small_float", 0.0000001).
+			WithFeature("big_float", 1e22).
+			WithFeature("float32", float32(0.1)).
+			WithFeature("bool", true).
+			WithFeature("nil", nil).
+			WithFeature("slice", []string{"a", "b"}),
+		NewFeatureVector().
+			WithFeature("int", 1).
+			WithFeature("int", 2).
+			WithFeature("other", "value"),
+		NewFeatureVector(),
+	)
+
+	expected, err := json.Marshal(request.asPandaOrientedDf())
+	require.NoError(t, err)
+
+	actual, err := request.encodeBody()
+	require.NoError(t, err)
+	require.Equal(t, string(expected), string(actual))
+
+	empty, err := NewPredictionRequest("model").encodeBody()
+	require.NoError(t, err)
+	expectedEmpty, _ := json.Marshal(NewPredictionRequest("model").asPandaOrientedDf())
+	require.Equal(t, string(expectedEmpty), string(empty))
+}
+
+type civilDate struct {
+	year  int
+	month time.Month
+	day   int
+}
+
+func (d civilDate) In(location *time.Location) time.Time {
+	return time.Date(d.year, d.month, d.day, 0, 0, 0, 0, location)
+}
+
+func TestEncodeTimeFeatures(t *testing.T) {
+	at := time.Date(2023, 3, 14, 15, 9, 26, 500000000, time.FixedZone("IDT", 3*3600))
+	request := NewPredictionRequest("model").AddFeatureVector(
+		NewFeatureVector().
+			WithFeature("at", at).
+			WithFeature("timeout", 90*time.Second).
+			WithFeature("date", civilDate{2023, time.March, 14}),
+	)
+
+	body, err := request.encodeBody()
+	require.NoError(t, err)
+	require.JSONEq(t, `{"columns":["at","timeout","date"],"index":[0],"data":[["2023-03-14T12:09:26.5Z",90,"2023-03-14"]]}`, string(body))
+
+	epoch := &FeatureEncoding{TimeEpochUnit: time.Millisecond, DurationUnit: time.Millisecond}
+	body, err = request.encodeBodyWith(epoch)
+	require.NoError(t, err)
+	require.JSONEq(t, `{"columns":["at","timeout","date"],"index":[0],"data":[[1678795766500,90000,"2023-03-14"]]}`, string(body))
+
+	expected, err := json.Marshal(request.asPandaOrientedDfWith(epoch))
+	require.NoError(t, err)
+	require.Equal(t, string(expected), string(body))
+}
+
+type address struct {
+	City    string `qwak:"city"`
+	Zip     string `json:"zip,omitempty"`
+	private string
+}
+
+type customer struct {
+	Name    string
+	Address *address `qwak:"address"`
+	Tags    map[string]int
+}
+
+func TestEncodeNestedFeatures(t *testing.T) {
+	request := NewPredictionRequest("model").AddFeatureVector(
+		NewFeatureVector().
+			WithFeature("customer", customer{
+				Name:    "ann",
+				Address: &address{City: "Tel Aviv", private: "x"},
+				Tags:    map[string]int{"b": 2, "a": 1},
+			}).
+			WithFeature("history", []interface{}{map[string]time.Duration{"wait": time.Minute}}),
+	)
+
+	body, err := request.encodeBody()
+	require.NoError(t, err)
+	require.Equal(t, `{"columns":["customer","history"],"index":[0],"data":[[`+
+		`{"Name":"ann","Tags":{"a":1,"b":2},"address":{"city":"Tel Aviv"}},[{"wait":60}]]]}`, string(body))
+
+	flatten := &FeatureEncoding{FlattenNested: true}
+	body, err = request.encodeBodyWith(flatten)
+	require.NoError(t, err)
+	require.Equal(t, `{"columns":["customer.Name","customer.address.city","customer.Tags.a","customer.Tags.b","history"],`+
+		`"index":[0],"data":[["ann","Tel Aviv",1,2,[{"wait":60}]]]}`, string(body))
+
+	expected, err := json.Marshal(request.asPandaOrientedDfWith(flatten))
+	require.NoError(t, err)
+	require.Equal(t, string(expected), string(body))
+}
+
+func TestFlattenSkipsNilFeatureVectors(t *testing.T) {
+	request := NewPredictionRequest("model").AddFeatureVectors(
+		NewFeatureVector().WithFeature("customer", customer{Name: "ann"}),
+		nil,
+	)
+
+	flattened := (&FeatureEncoding{FlattenNested: true}).flatten(request)
+
+	require.Len(t, flattened.featuresVector, 2)
+	require.Nil(t, flattened.featuresVector[1])
+	value, ok := flattened.featuresVector[0].GetFeature("customer.Name")
+	require.True(t, ok)
+	require.Equal(t, "ann", value)
+}
+
+func benchmarkRequest() *PredictionRequest {
+	request := NewPredictionRequest("model")
+	for row := 0; row < 16; row++ {
+		vector := NewFeatureVector()
+		for column := 0; column < 20; column++ {
+			vector.WithFeature(fmt.Sprintf("feature_%d", column), float64(row*column)+0.5)
+		}
+		vector.WithFeature("state", "PPP")
+		request.AddFeatureVector(vector)
+	}
+	return request
+}
+
+func BenchmarkEncodeWithJsonMarshal(b *testing.B) {
+	request := benchmarkRequest()
+	b.ReportAllocs()
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		if _, err := json.Marshal(request.asPandaOrientedDf()); err != nil {
+			b.Fatal(err)
+		}
+	}
+}
+
+func BenchmarkEncodeBody(b *testing.B) {
+	request := benchmarkRequest()
+	b.ReportAllocs()
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		if _, err := request.encodeBody(); err != nil {
+			b.Fatal(err)
+		}
+	}
+}
+
+type fixedDecimal struct {
+	units int64
+	exp   int64
+}
+
+func (d fixedDecimal) Rat() *big.Rat {
+	return new(big.Rat).SetFrac(big.NewInt(d.units), new(big.Int).Exp(big.NewInt(10), big.NewInt(d.exp), nil))
+}
+
+func TestEncodeDecimalFeatures(t *testing.T) {
+	price, _ := new(big.Rat).SetString("12345678901234567.89")
+	request := NewPredictionRequest("model").AddFeatureVector(
+		NewFeatureVector().
+			WithFeature("price", price).
+			WithFeature("third", big.NewRat(1, 3)).
+			WithFeature("rate", big.NewFloat(0.25)).
+			WithFeature("fee", fixedDecimal{units: 1999, exp: 2}),
+	)
+
+	expected := `{"columns":["price","third","rate","fee"],"index":[0],"data":[[12345678901234567.89,0.333333333333333333,0.25,19.99]]}`
+	body, err := request.encodeBody()
+	require.NoError(t, err)
+	require.Equal(t, expected, string(body))
+
+	marshaled, err := json.Marshal(request.asPandaOrientedDf())
+	require.NoError(t, err)
+	require.Equal(t, expected, string(marshaled))
+	require.NoError(t, request.Validate())
+
+	body, err = request.encodeBodyWith(&FeatureEncoding{DecimalPlaces: 4})
+	require.NoError(t, err)
+	require.Contains(t, string(body), "0.3333,")
+}
+
+func TestEstimateSize(t *testing.T) {
+	request := NewPredictionRequest("model").AddFeatureVectors(
+		NewFeatureVector().WithFeature("name", "ünicode").WithFeature("at", time.Unix(0, 0)),
+		NewFeatureVector().WithFeature("name", "b"),
+	)
+
+	body, err := request.encodeBody()
+	require.NoError(t, err)
+	size, err := request.EstimateSize()
+	require.NoError(t, err)
+	require.Equal(t, len(body), size)
+
+	_, err = NewPredictionRequest("model").AddFeatureVector(NewFeatureVector().WithFeature("f", func() {})).EstimateSize()
+	require.Error(t, err)
+}
+
+func TestColumnNames(t *testing.T) {
+	for name, expected := range map[string]string{
+		"userId":         "user_id",
+		"HTTPStatus":     "http_status",
+		"already_snake":  "already_snake",
+		"last-login":     "last_login",
+		"Address.City":   "address.city",
+		"score2Weighted": "score2_weighted",
+	} {
+		require.Equal(t, expected, SnakeCase(name), name)
+	}
+
+	request := NewPredictionRequest("model").AddFeatureVector(
+		NewFeatureVector().
+			WithFeature("userId", 1).
+			WithFeature("ltv", 2.5).
+			WithFeature("Address", address{City: "TLV"}),
+	)
+	encoding := &FeatureEncoding{
+		FlattenNested: true,
+		ColumnAliases: map[string]string{"ltv": "lifetime_value"},
+		ColumnNamer:   SnakeCase,
+	}
+
+	body, err := request.encodeBodyWith(encoding)
+	require.NoError(t, err)
+	require.Equal(t, `{"columns":["user_id","lifetime_value","address.city"],"index":[0],"data":[[1,2.5,"TLV"]]}`, string(body))
+
+	expected, err := json.Marshal(request.asPandaOrientedDfWith(encoding))
+	require.NoError(t, err)
+	require.Equal(t, string(expected), string(body))
+}
@@ -0,0 +1,57 @@
+package qwak
+
+import (
+	"context"
+	"fmt"
+	"sync"
+)
+
+// ModelPrediction is the outcome of the prediction of a single model in PredictMany
+type ModelPrediction struct {
+	Response *PredictionResponse
+	Err      error
+}
+
+// PredictMany queries several models concurrently, with requests keyed by model id, and returns the outcome
+// of each model. The predictions share the deadline of ctx, and a failing model does not fail the others
+func (c *RealTimeClient) PredictMany(ctx context.Context, requests map[string]*PredictionRequest) map[string]ModelPrediction {
+	return PredictMany(ctx, c, requests)
+}
+
+// PredictMany is RealTimeClient.PredictMany for any Predictor, e.g. a Batcher or a fake client.
+// A request without a model id is sent to the model of its key
+func PredictMany(ctx context.Context, predictor Predictor, requests map[string]*PredictionRequest) map[string]ModelPrediction {
+	var lock sync.Mutex
+	var wg sync.WaitGroup
+	outcomes := make(map[string]ModelPrediction, len(requests))
+
+	for modelId, request := range requests {
+		if request.modelId == "" {
+			withModel := *request
+			withModel.modelId = modelId
+			request = &withModel
+		}
+
+		if request.modelId != modelId {
+			lock.Lock()
+			outcomes[modelId] = ModelPrediction{
+				Err: fmt.Errorf("request of model '%s' is keyed by model '%s'", request.modelId, modelId),
+			}
+			lock.Unlock()
+			continue
+		}
+
+		wg.Add(1)
+		go func(modelId string, request *PredictionRequest) {
+			defer wg.Done()
+			response, err := predictor.PredictWithCtx(ctx, request)
+
+			lock.Lock()
+			defer lock.Unlock()
+			outcomes[modelId] = ModelPrediction{Response: response, Err: err}
+		}(modelId, request)
+	}
+
+	wg.Wait()
+	return outcomes
+}
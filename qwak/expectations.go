@@ -0,0 +1,132 @@
+package qwak
+
+import (
+	"encoding/json"
+	"fmt"
+	"strings"
+)
+
+// ColumnType is the json type expected of a response column
+type ColumnType int
+
+const (
+	// AnyColumnType accepts any value, only the column presence is verified
+	AnyColumnType ColumnType = iota
+	// NumberColumnType expects a number
+	NumberColumnType
+	// StringColumnType expects a string
+	StringColumnType
+	// BoolColumnType expects a boolean
+	BoolColumnType
+	// ArrayColumnType expects an array
+	ArrayColumnType
+	// ObjectColumnType expects an object
+	ObjectColumnType
+)
+
+func (t ColumnType) String() string {
+	switch t {
+	case NumberColumnType:
+		return "number"
+	case StringColumnType:
+		return "string"
+	case BoolColumnType:
+		return "bool"
+	case ArrayColumnType:
+		return "array"
+	case ObjectColumnType:
+		return "object"
+	default:
+		return "any"
+	}
+}
+
+// matches reports whether a decoded json value is of the type, null values match no type but AnyColumnType
+func (t ColumnType) matches(value interface{}) bool {
+	switch value.(type) {
+	case float64, int, int64, float32, uint64, json.Number:
+		return t == AnyColumnType || t == NumberColumnType
+	case string:
+		return t == AnyColumnType || t == StringColumnType
+	case bool:
+		return t == AnyColumnType || t == BoolColumnType
+	case []interface{}:
+		return t == AnyColumnType || t == ArrayColumnType
+	case map[string]interface{}:
+		return t == AnyColumnType || t == ObjectColumnType
+	default:
+		return t == AnyColumnType
+	}
+}
+
+type expectedColumn struct {
+	name       string
+	columnType ColumnType
+}
+
+// SchemaMismatchError is returned when a prediction response lacks the columns expected by the request,
+// or when their values are of unexpected types, usually because the output schema of the model drifted
+type SchemaMismatchError struct {
+	ModelId string
+	// MissingColumns the expected columns missing from at least one result
+	MissingColumns []string
+	// MismatchedColumns describes the expected columns having a value of an unexpected type
+	MismatchedColumns []string
+}
+
+func (e *SchemaMismatchError) Error() string {
+	var problems []string
+	if len(e.MissingColumns) > 0 {
+		problems = append(problems, fmt.Sprintf("missing columns %s", strings.Join(e.MissingColumns, ", ")))
+	}
+	if len(e.MismatchedColumns) > 0 {
+		problems = append(problems, fmt.Sprintf("unexpected column types %s", strings.Join(e.MismatchedColumns, ", ")))
+	}
+	return fmt.Sprintf("response of model '%s' does not match the expected schema: %s", e.ModelId, strings.Join(problems, "; "))
+}
+
+// ExpectColumns verifies the results of the response include the columns, failing the prediction with
+// a SchemaMismatchError otherwise
+func (ir *PredictionRequest) ExpectColumns(columns ...string) *PredictionRequest {
+	ir = ir.mutable()
+	for _, column := range columns {
+		ir.ExpectColumnOfType(column, AnyColumnType)
+	}
+	return ir
+}
+
+// ExpectColumnOfType verifies the results of the response include the column with a value of the type,
+// failing the prediction with a SchemaMismatchError otherwise
+func (ir *PredictionRequest) ExpectColumnOfType(column string, columnType ColumnType) *PredictionRequest {
+	ir = ir.mutable()
+	ir.expectedColumns = append(ir.expectedColumns, expectedColumn{name: column, columnType: columnType})
+	return ir
+}
+
+// checkExpectedColumns returns a SchemaMismatchError when the response does not match the expected columns
+func (ir *PredictionRequest) checkExpectedColumns(response *PredictionResponse) error {
+	if len(ir.expectedColumns) == 0 {
+		return nil
+	}
+
+	schemaErr := &SchemaMismatchError{ModelId: ir.modelId}
+	for _, expected := range ir.expectedColumns {
+		for idx, prediction := range response.predictions {
+			value, ok := prediction.valuesMap[expected.name]
+			if !ok {
+				schemaErr.MissingColumns = append(schemaErr.MissingColumns, expected.name)
+				break
+			}
+			if !expected.columnType.matches(value) {
+				schemaErr.MismatchedColumns = append(schemaErr.MismatchedColumns,
+					fmt.Sprintf("'%s' at index %d expected %s got %T", expected.name, idx, expected.columnType, value))
+				break
+			}
+		}
+	}
+
+	if len(schemaErr.MissingColumns) > 0 || len(schemaErr.MismatchedColumns) > 0 {
+		return schemaErr
+	}
+	return nil
+}
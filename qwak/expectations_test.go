@@ -0,0 +1,31 @@
+package qwak
+
+import (
+	"errors"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestCheckExpectedColumns(t *testing.T) {
+	response, err := responseFromRaw([]byte(`[{"churn":1,"label":"a"},{"churn":0,"label":2}]`))
+	require.NoError(t, err)
+
+	require.NoError(t, NewPredictionRequest("model").checkExpectedColumns(response))
+	require.NoError(t, NewPredictionRequest("model").
+		ExpectColumns("churn").
+		ExpectColumnOfType("churn", NumberColumnType).
+		checkExpectedColumns(response))
+
+	err = NewPredictionRequest("model").
+		ExpectColumns("churn", "score").
+		ExpectColumnOfType("label", StringColumnType).
+		checkExpectedColumns(response)
+
+	var schemaErr *SchemaMismatchError
+	require.True(t, errors.As(err, &schemaErr))
+	require.Equal(t, []string{"score"}, schemaErr.MissingColumns)
+	require.Equal(t, []string{"'label' at index 1 expected string got float64"}, schemaErr.MismatchedColumns)
+	require.EqualError(t, err, "response of model 'model' does not match the expected schema: "+
+		"missing columns score; unexpected column types 'label' at index 1 expected string got float64")
+}
@@ -0,0 +1,184 @@
+package qwak
+
+import (
+	"encoding/csv"
+	"encoding/json"
+	"fmt"
+	"io"
+	"sort"
+	"strconv"
+
+	"github.com/qwak-ai/go-sdk/qwak/http"
+)
+
+// CSVOptions controls how PredictionResponse.WriteCSVWithOptions writes the results
+type CSVOptions struct {
+	// Columns the columns written, in order. Default to every column of the results, sorted by name
+	Columns []string
+	// OmitHeader skips the header row of column names
+	OmitHeader bool
+	// Comma the field delimiter, default to ','
+	Comma rune
+	// FloatFormat the strconv.FormatFloat format of numbers, e.g. 'e', default to 'f'
+	FloatFormat byte
+	// FloatPrecision the number of digits of numbers, as in strconv.FormatFloat. Numbers are written with
+	// the smallest number of digits representing them exactly when 0
+	FloatPrecision int
+	// NullValue the field written for missing columns and null values, default to an empty field
+	NullValue string
+}
+
+// WriteCSV writes the results as csv, a header row followed by a row per result. Columns are sorted by name,
+// arrays and objects are written as json. Use WriteCSVWithOptions to choose the columns and their formatting
+func (pr *PredictionResponse) WriteCSV(w io.Writer) error {
+	return pr.WriteCSVWithOptions(w, CSVOptions{})
+}
+
+// WriteCSVWithOptions writes the results as csv, formatted according to options
+func (pr *PredictionResponse) WriteCSVWithOptions(w io.Writer, options CSVOptions) error {
+	columns := options.Columns
+	if len(columns) == 0 {
+		columns = pr.columnNames()
+	}
+
+	writer := csv.NewWriter(w)
+	if options.Comma != 0 {
+		writer.Comma = options.Comma
+	}
+
+	if !options.OmitHeader {
+		if err := writer.Write(columns); err != nil {
+			return err
+		}
+	}
+
+	record := make([]string, len(columns))
+	for idx, prediction := range pr.predictions {
+		for columnIdx, column := range columns {
+			field, err := options.formatValue(prediction.valuesMap[column])
+			if err != nil {
+				return fmt.Errorf("failed to format column '%s' of result at index %d: %w", column, idx, err)
+			}
+			record[columnIdx] = field
+		}
+		if err := writer.Write(record); err != nil {
+			return err
+		}
+	}
+
+	writer.Flush()
+	return writer.Error()
+}
+
+// columnNames returns the columns of all the results, sorted by name
+func (pr *PredictionResponse) columnNames() []string {
+	seen := map[string]bool{}
+	var columns []string
+	for _, prediction := range pr.predictions {
+		for column := range prediction.valuesMap {
+			if !seen[column] {
+				seen[column] = true
+				columns = append(columns, column)
+			}
+		}
+	}
+	sort.Strings(columns)
+	return columns
+}
+
+func (options CSVOptions) formatValue(value interface{}) (string, error) {
+	switch v := value.(type) {
+	case nil:
+		return options.NullValue, nil
+	case string:
+		return v, nil
+	case bool:
+		return strconv.FormatBool(v), nil
+	case float64:
+		format := options.FloatFormat
+		if format == 0 {
+			format = 'f'
+		}
+		precision := options.FloatPrecision
+		if precision == 0 {
+			precision = -1
+		}
+		return strconv.FormatFloat(v, format, precision, 64), nil
+	case json.Number:
+		if options.FloatFormat == 0 && options.FloatPrecision == 0 {
+			// numbers decoded with EncodingJSONCodec.UseNumber are written as sent to keep their precision
+			return v.String(), nil
+		}
+		parsed, err := v.Float64()
+		if err != nil {
+			return "", err
+		}
+		return options.formatValue(parsed)
+	}
+
+	raw, err := json.Marshal(value)
+	if err != nil {
+		return "", err
+	}
+	return string(raw), nil
+}
+
+// MarshalJSON encodes the results as the model returned them, a records oriented json array of objects.
+// Decode responses with EncodingJSONCodec.UseNumber to forward numbers exactly as sent by the model
+func (pr *PredictionResponse) MarshalJSON() ([]byte, error) {
+	return pr.MarshalRecords()
+}
+
+// MarshalRecords encodes the results as a records oriented json array of objects, the format of model responses.
+// When columns are given, the other columns are left out
+func (pr *PredictionResponse) MarshalRecords(columns ...string) ([]byte, error) {
+	records := make([]map[string]interface{}, len(pr.predictions))
+	for idx, prediction := range pr.predictions {
+		if len(columns) == 0 {
+			records[idx] = prediction.valuesMap
+			continue
+		}
+
+		records[idx] = make(map[string]interface{}, len(columns))
+		for _, column := range columns {
+			if value, ok := prediction.valuesMap[column]; ok {
+				records[idx][column] = value
+			}
+		}
+	}
+
+	return json.Marshal(records)
+}
+
+// MarshalSplit encodes the results as a split oriented json data frame, the format of prediction requests,
+// with the columns sorted by name. When columns are given, only those are encoded, in order.
+// Missing values are encoded as null
+func (pr *PredictionResponse) MarshalSplit(columns ...string) ([]byte, error) {
+	if len(columns) == 0 {
+		columns = pr.columnNames()
+	}
+
+	index := make([]int, len(pr.predictions))
+	data := make([][]interface{}, len(pr.predictions))
+	for idx, prediction := range pr.predictions {
+		index[idx] = idx
+		data[idx] = make([]interface{}, len(columns))
+		for columnIdx, column := range columns {
+			data[idx][columnIdx] = prediction.valuesMap[column]
+		}
+	}
+
+	return json.Marshal(http.NewPandaOrientedDf(columns, index, data))
+}
+
+// Filter returns a response holding the results for which keep returns true, e.g. to forward part of the
+// results. The results are shared with the response, and must not be used after it is released
+func (pr *PredictionResponse) Filter(keep func(result *PredictionResult) bool) *PredictionResponse {
+	filtered := &PredictionResponse{shared: true, meta: pr.meta}
+	for _, prediction := range pr.predictions {
+		if keep(prediction) {
+			filtered.predictions = append(filtered.predictions, prediction)
+		}
+	}
+	return filtered
+}
@@ -0,0 +1,53 @@
+package qwak
+
+import (
+	"bytes"
+	"encoding/json"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestWriteCSV(t *testing.T) {
+	response, err := responseFromRaw([]byte(`[{"score":0.5,"label":"a, b","tags":["x"]},{"score":12,"flag":true}]`))
+	require.NoError(t, err)
+
+	var out bytes.Buffer
+	require.NoError(t, response.WriteCSV(&out))
+	require.Equal(t, "flag,label,score,tags\n,\"a, b\",0.5,\"[\"\"x\"\"]\"\ntrue,,12,\n", out.String())
+
+	out.Reset()
+	require.NoError(t, response.WriteCSVWithOptions(&out, CSVOptions{
+		Columns:        []string{"score", "flag"},
+		OmitHeader:     true,
+		Comma:          ';',
+		FloatPrecision: 2,
+		NullValue:      "NA",
+	}))
+	require.Equal(t, "0.50;NA\n12.00;true\n", out.String())
+}
+
+func TestReencodeResponse(t *testing.T) {
+	response, err := responseFromRawWithCodec([]byte(`[{"id":9007199254740993,"label":"a"},{"id":2}]`), EncodingJSONCodec{UseNumber: true})
+	require.NoError(t, err)
+
+	records, err := json.Marshal(response)
+	require.NoError(t, err)
+	require.Equal(t, `[{"id":9007199254740993,"label":"a"},{"id":2}]`, string(records))
+
+	records, err = response.MarshalRecords("label")
+	require.NoError(t, err)
+	require.Equal(t, `[{"label":"a"},{}]`, string(records))
+
+	split, err := response.MarshalSplit()
+	require.NoError(t, err)
+	require.Equal(t, `{"columns":["id","label"],"index":[0,1],"data":[[9007199254740993,"a"],[2,null]]}`, string(split))
+
+	labeled := response.Filter(func(result *PredictionResult) bool {
+		_, err := result.GetValueAsString("label")
+		return err == nil
+	})
+	split, err = labeled.MarshalSplit("label")
+	require.NoError(t, err)
+	require.Equal(t, `{"columns":["label"],"index":[0],"data":[["a"]]}`, string(split))
+}
@@ -0,0 +1,129 @@
+package qwak
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/qwak-ai/go-sdk/qwak/http"
+)
+
+// DefaultFailoverCooldown is how long a failed endpoint is skipped before being tried again
+const DefaultFailoverCooldown = 30 * time.Second
+
+// endpointSelector orders the failover endpoints, preferring the first healthy one.
+// An endpoint failing with a connection error or a server error is skipped until its cooldown ends,
+// after which traffic recovers back to it
+type endpointSelector struct {
+	lock           sync.Mutex
+	urls           []string
+	unhealthyUntil []time.Time
+	cooldown       time.Duration
+	now            func() time.Time
+}
+
+func newEndpointSelector(urls []string, cooldown time.Duration) *endpointSelector {
+	if cooldown <= 0 {
+		cooldown = DefaultFailoverCooldown
+	}
+
+	return &endpointSelector{
+		urls:           urls,
+		unhealthyUntil: make([]time.Time, len(urls)),
+		cooldown:       cooldown,
+		now:            time.Now,
+	}
+}
+
+// candidates returns the endpoint indices to try in order: healthy endpoints by priority,
+// then unhealthy ones by the end of their cooldown
+func (e *endpointSelector) candidates() []int {
+	e.lock.Lock()
+	defer e.lock.Unlock()
+
+	now := e.now()
+	healthy := make([]int, 0, len(e.urls))
+	var unhealthy []int
+
+	for idx := range e.urls {
+		if now.Before(e.unhealthyUntil[idx]) {
+			unhealthy = append(unhealthy, idx)
+		} else {
+			healthy = append(healthy, idx)
+		}
+	}
+
+	sort.Slice(unhealthy, func(i, j int) bool {
+		return e.unhealthyUntil[unhealthy[i]].Before(e.unhealthyUntil[unhealthy[j]])
+	})
+
+	return append(healthy, unhealthy...)
+}
+
+func (e *endpointSelector) markFailed(idx int) {
+	e.lock.Lock()
+	defer e.lock.Unlock()
+	e.unhealthyUntil[idx] = e.now().Add(e.cooldown)
+}
+
+func (e *endpointSelector) markHealthy(idx int) {
+	e.lock.Lock()
+	defer e.lock.Unlock()
+	e.unhealthyUntil[idx] = time.Time{}
+}
+
+// shouldFailover reports whether a prediction error means the endpoint is unavailable:
+// the request could not reach it or it kept answering with server errors
+func shouldFailover(err error) bool {
+	var retryErr *http.RetryError
+	if !errors.As(err, &retryErr) || len(retryErr.Attempts) == 0 {
+		return false
+	}
+
+	last := retryErr.Attempts[len(retryErr.Attempts)-1]
+	if last.Discarded || errors.Is(last.Err, context.Canceled) {
+		return false
+	}
+
+	return last.StatusCode == 0 || last.StatusCode >= 500
+}
+
+// predict sends the prediction once an in-flight slot is available, reporting the outcome to the limiters
+func (c *RealTimeClient) predict(ctx context.Context, predictionRequest *PredictionRequest, body []byte) (*PredictionResponse, error) {
+	release, err := c.acquireSlots(ctx, predictionRequest.modelId)
+	if err != nil {
+		return nil, fmt.Errorf("qwak client failed to predict: %w", err)
+	}
+
+	response, err := c.predictWithFailover(ctx, predictionRequest, body)
+	release(err)
+	return response, err
+}
+
+// predictWithFailover sends the prediction to the first available endpoint, failing over to the next ones
+func (c *RealTimeClient) predictWithFailover(ctx context.Context, predictionRequest *PredictionRequest, body []byte) (*PredictionResponse, error) {
+	if c.endpoints == nil {
+		return c.doPredict(ctx, predictionRequest, body, c.url)
+	}
+
+	var lastErr error
+	for _, idx := range c.endpoints.candidates() {
+		response, err := c.doPredict(ctx, predictionRequest, body, c.endpoints.urls[idx])
+
+		if err == nil {
+			c.endpoints.markHealthy(idx)
+			return response, nil
+		}
+
+		lastErr = err
+		if !shouldFailover(err) || ctx.Err() != nil {
+			return nil, err
+		}
+		c.endpoints.markFailed(idx)
+	}
+
+	return nil, lastErr
+}
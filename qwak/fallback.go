@@ -0,0 +1,47 @@
+package qwak
+
+import (
+	"context"
+	"time"
+)
+
+// FallbackHandler serves a prediction when the model could not be reached or failed, after the retries
+// were exhausted, e.g. with a default score or a previously cached result. Returning an error fails the
+// prediction with it, returning a nil response fails the prediction with the original error
+type FallbackHandler func(ctx context.Context, request *PredictionRequest, err error) (*PredictionResponse, error)
+
+// StaticFallback returns a FallbackHandler answering every feature vector of the request with a copy of row
+func StaticFallback(row map[string]interface{}) FallbackHandler {
+	return func(ctx context.Context, request *PredictionRequest, err error) (*PredictionResponse, error) {
+		response := &PredictionResponse{predictions: make([]*PredictionResult, len(request.featuresVector))}
+		for idx := range response.predictions {
+			values := make(map[string]interface{}, len(row))
+			for column, value := range row {
+				values[column] = value
+			}
+			response.predictions[idx] = &PredictionResult{valuesMap: values}
+		}
+		return response, nil
+	}
+}
+
+// predictFallback serves the request with the fallback handler when it is set, and returns cause otherwise
+func (c *RealTimeClient) predictFallback(ctx context.Context, predictionRequest *PredictionRequest, cause error, start time.Time) (*PredictionResponse, error) {
+	if c.fallback == nil {
+		return nil, cause
+	}
+
+	response, err := c.fallback(ctx, predictionRequest, cause)
+	if err != nil {
+		return nil, err
+	}
+	if response == nil {
+		return nil, cause
+	}
+
+	// the handler may return the same response to many callers, so releasing it is disabled
+	view := response.forRequest(predictionRequest)
+	view.shared = true
+	view.meta = PredictionMeta{Fallback: true}
+	return c.completePrediction(predictionRequest, view, start, false), nil
+}
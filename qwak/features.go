@@ -0,0 +1,476 @@
+package qwak
+
+import (
+	"encoding"
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"math/big"
+	"reflect"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+	"unicode"
+)
+
+// FeatureEncoding controls how feature values which have no single json representation are encoded.
+// The zero value encodes times as RFC 3339 strings in UTC, durations as seconds, and maps, slices and structs
+// as json values. Struct fields are named as in TypedClient, and objects keys are sorted
+type FeatureEncoding struct {
+	// TimeLayout the layout of time.Time values, default to time.RFC3339Nano. Ignored when TimeEpochUnit is set
+	TimeLayout string
+	// TimeLocation the location time.Time values are converted to before formatting, default to UTC
+	TimeLocation *time.Location
+	// TimeEpochUnit encodes time.Time values as the number of units since the unix epoch instead,
+	// e.g. time.Second or time.Millisecond
+	TimeEpochUnit time.Duration
+	// DurationUnit encodes time.Duration values as a number of the unit, default to time.Second
+	DurationUnit time.Duration
+	// DateLayout the layout of civil.Date-like values, having an In(*time.Location) time.Time method,
+	// default to "2006-01-02". Such values with a time of day, e.g. civil.DateTime, are encoded as time.Time
+	DateLayout string
+	// FlattenNested expands map and struct feature values into a column per nested value, named by joining
+	// the keys with FlattenSeparator, e.g. "address.city". Slices are not expanded
+	FlattenNested bool
+	// FlattenSeparator joins the keys of flattened columns, default to "."
+	FlattenSeparator string
+	// DecimalPlaces the number of digits after the decimal point of decimal values having no finite decimal
+	// representation, such as big.NewRat(1, 3), default to 18. Other decimal values are encoded exactly
+	DecimalPlaces int
+	// ColumnAliases renames features to the column names expected by the model, keyed by feature name.
+	// Flattened features are renamed by their flattened name
+	ColumnAliases map[string]string
+	// ColumnNamer converts the names of features without alias to column names, e.g. SnakeCase
+	ColumnNamer func(name string) string
+	// DuplicateFeatures what to do when a feature vector has several features of the same column,
+	// default to DuplicateFeaturesLastWins
+	DuplicateFeatures DuplicateFeaturePolicy
+}
+
+// DuplicateFeaturePolicy decides how features set more than once in a feature vector are encoded
+type DuplicateFeaturePolicy int
+
+const (
+	// DuplicateFeaturesLastWins encodes the value of the feature set last
+	DuplicateFeaturesLastWins DuplicateFeaturePolicy = iota
+	// DuplicateFeaturesReject fails requests having a feature set more than once, even to the same value
+	DuplicateFeaturesReject
+)
+
+// bytesValue is a binary feature value, sent as a base64 string
+type bytesValue []byte
+
+// decimalLike matches decimal types convertible to a big.Rat, such as github.com/shopspring/decimal Decimal
+type decimalLike interface {
+	Rat() *big.Rat
+}
+
+// dateLike matches calendar dates and date times such as cloud.google.com/go/civil Date and DateTime
+type dateLike interface {
+	In(location *time.Location) time.Time
+}
+
+var defaultFeatureEncoding = &FeatureEncoding{}
+
+// encodeValue returns the json friendly representation of a feature value, and false when the value
+// is encoded as is
+func (e *FeatureEncoding) encodeValue(value interface{}) (interface{}, bool) {
+	switch v := value.(type) {
+	case nil, string, bool, int, int64, float64, float32:
+		return nil, false
+	case bytesValue:
+		if v == nil {
+			return nil, true
+		}
+		return base64.StdEncoding.EncodeToString(v), true
+	case time.Time:
+		return e.encodeTime(v), true
+	case *time.Time:
+		if v == nil {
+			return nil, false
+		}
+		return e.encodeTime(*v), true
+	case time.Duration:
+		return float64(v) / float64(durationOrDefault(e.DurationUnit, time.Second)), true
+	case *big.Rat:
+		if v == nil {
+			return nil, false
+		}
+		return e.encodeDecimal(v), true
+	case big.Rat:
+		return e.encodeDecimal(&v), true
+	case *big.Float:
+		if v == nil || v.IsInf() {
+			return nil, false
+		}
+		return json.Number(v.Text('g', -1)), true
+	case big.Float:
+		if v.IsInf() {
+			return nil, false
+		}
+		return json.Number(v.Text('g', -1)), true
+	case decimalLike:
+		return e.encodeDecimal(v.Rat()), true
+	case dateLike:
+		date := v.In(time.UTC)
+		if !date.Equal(date.Truncate(24 * time.Hour)) {
+			return e.encodeTime(v.In(e.location())), true
+		}
+		layout := e.DateLayout
+		if layout == "" {
+			layout = "2006-01-02"
+		}
+		return date.Format(layout), true
+	case json.Marshaler, encoding.TextMarshaler:
+		return nil, false
+	}
+
+	switch reflected := reflect.ValueOf(value); reflected.Kind() {
+	case reflect.Map, reflect.Struct, reflect.Slice, reflect.Array, reflect.Ptr:
+		return e.encodeNested(reflected), true
+	}
+	return nil, false
+}
+
+// encodeNested converts a nested value to maps and slices of encoded values, which json encodes deterministically
+func (e *FeatureEncoding) encodeNested(value reflect.Value) interface{} {
+	switch value.Kind() {
+	case reflect.Ptr, reflect.Interface:
+		if value.IsNil() {
+			return nil
+		}
+		return e.encodeAny(value.Elem().Interface())
+	case reflect.Map:
+		if value.IsNil() {
+			return nil
+		}
+		encoded := make(map[string]interface{}, value.Len())
+		iter := value.MapRange()
+		for iter.Next() {
+			key, ok := mapKey(iter.Key())
+			if !ok {
+				return value.Interface()
+			}
+			encoded[key] = e.encodeAny(iter.Value().Interface())
+		}
+		return encoded
+	case reflect.Struct:
+		fields, _ := structFields(value.Type())
+		encoded := make(map[string]interface{}, len(fields))
+		for _, field := range fields {
+			fieldValue := value.FieldByIndex(field.index)
+			if field.omitEmpty && fieldValue.IsZero() {
+				continue
+			}
+			encoded[field.column] = e.encodeAny(fieldValue.Interface())
+		}
+		return encoded
+	case reflect.Slice, reflect.Array:
+		if value.Kind() == reflect.Slice && value.IsNil() {
+			return nil
+		}
+		if value.Type().Elem().Kind() == reflect.Uint8 {
+			// bytes are encoded by encoding/json
+			return value.Interface()
+		}
+		encoded := make([]interface{}, value.Len())
+		for idx := range encoded {
+			encoded[idx] = e.encodeAny(value.Index(idx).Interface())
+		}
+		return encoded
+	}
+	return value.Interface()
+}
+
+func (e *FeatureEncoding) encodeAny(value interface{}) interface{} {
+	if encoded, ok := e.encodeValue(value); ok {
+		return encoded
+	}
+	return value
+}
+
+// mapKey formats a map key as encoding/json does, and returns false for unsupported key types
+func mapKey(key reflect.Value) (string, bool) {
+	switch key.Kind() {
+	case reflect.String:
+		return key.String(), true
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		return strconv.FormatInt(key.Int(), 10), true
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		return strconv.FormatUint(key.Uint(), 10), true
+	}
+	return "", false
+}
+
+// prepare returns the request as it is encoded, with its default values applied and its nested values flattened
+func (e *FeatureEncoding) prepare(request *PredictionRequest) *PredictionRequest {
+	return e.rename(e.flatten(request.withDefaultsApplied()))
+}
+
+// duplicateFeatures returns a problem per feature set more than once in a vector of a prepared request.
+// Features set to different values are always reported, those set to the same value only when rejected
+func (e *FeatureEncoding) duplicateFeatures(idx int, vector *FeatureVector) []string {
+	if len(vector.features) < 2 {
+		return nil
+	}
+
+	var problems []string
+	values := make(map[string]interface{}, len(vector.features))
+	for _, feature := range vector.features {
+		previous, ok := values[feature.name]
+		values[feature.name] = feature.value
+		if !ok {
+			continue
+		}
+
+		if !reflect.DeepEqual(previous, feature.value) {
+			problems = append(problems, fmt.Sprintf("feature '%s' at index %d is set to conflicting values", feature.name, idx))
+		} else if e.DuplicateFeatures == DuplicateFeaturesReject {
+			problems = append(problems, fmt.Sprintf("feature '%s' at index %d is set more than once", feature.name, idx))
+		}
+	}
+	return problems
+}
+
+// checkDuplicateFeatures returns an error when the request has duplicate features and they are rejected
+func (e *FeatureEncoding) checkDuplicateFeatures(request *PredictionRequest) error {
+	if e.DuplicateFeatures != DuplicateFeaturesReject {
+		return nil
+	}
+
+	var problems []string
+	for idx, vector := range e.prepare(request).featuresVector {
+		if vector == nil {
+			continue
+		}
+		problems = append(problems, e.duplicateFeatures(idx, vector)...)
+	}
+
+	if len(problems) > 0 {
+		return &RequestValidationError{ModelId: request.modelId, Problems: problems}
+	}
+	return nil
+}
+
+// rename returns the request with its features renamed to their column names,
+// or the request itself when no renaming is configured
+func (e *FeatureEncoding) rename(request *PredictionRequest) *PredictionRequest {
+	if len(e.ColumnAliases) == 0 && e.ColumnNamer == nil {
+		return request
+	}
+
+	renamed := *request
+	renamed.featuresVector = make([]*FeatureVector, len(request.featuresVector))
+	for idx, vector := range request.featuresVector {
+		if vector == nil {
+			continue
+		}
+		renamedVector := &FeatureVector{vectorId: vector.vectorId, features: make([]*feature, len(vector.features))}
+		for featureIdx, feature := range vector.features {
+			renamedFeature := *feature
+			renamedFeature.name = e.columnName(feature.name)
+			renamedVector.features[featureIdx] = &renamedFeature
+		}
+		renamed.featuresVector[idx] = renamedVector
+	}
+	return &renamed
+}
+
+func (e *FeatureEncoding) columnName(name string) string {
+	if alias, ok := e.ColumnAliases[name]; ok {
+		return alias
+	}
+	if e.ColumnNamer != nil {
+		return e.ColumnNamer(name)
+	}
+	return name
+}
+
+// SnakeCase converts a camel case, pascal case or kebab case name to snake case, e.g. "userId" and
+// "HTTPStatus" to "user_id" and "http_status". Use it as FeatureEncoding.ColumnNamer
+func SnakeCase(name string) string {
+	runes := []rune(name)
+	var builder strings.Builder
+	builder.Grow(len(name) + 4)
+
+	for idx, r := range runes {
+		switch {
+		case r == '-' || r == ' ':
+			builder.WriteByte('_')
+		case unicode.IsUpper(r):
+			if idx > 0 {
+				previous := runes[idx-1]
+				nextIsLower := idx+1 < len(runes) && unicode.IsLower(runes[idx+1])
+				if unicode.IsLower(previous) || unicode.IsDigit(previous) || (unicode.IsUpper(previous) && nextIsLower) {
+					builder.WriteByte('_')
+				}
+			}
+			builder.WriteRune(unicode.ToLower(r))
+		default:
+			builder.WriteRune(r)
+		}
+	}
+	return builder.String()
+}
+
+// flatten returns the request with its map and struct feature values expanded into columns,
+// or the request itself when flattening is disabled
+func (e *FeatureEncoding) flatten(request *PredictionRequest) *PredictionRequest {
+	if !e.FlattenNested {
+		return request
+	}
+
+	separator := e.FlattenSeparator
+	if separator == "" {
+		separator = "."
+	}
+
+	flattened := *request
+	flattened.featuresVector = make([]*FeatureVector, len(request.featuresVector))
+	for idx, vector := range request.featuresVector {
+		if vector == nil {
+			flattened.featuresVector[idx] = nil
+			continue
+		}
+
+		flattenedVector := &FeatureVector{vectorId: vector.vectorId}
+		for _, feature := range vector.features {
+			flattenedVector.features = appendFlattened(flattenedVector.features, feature.name, reflect.ValueOf(feature.value), separator)
+		}
+		flattened.featuresVector[idx] = flattenedVector
+	}
+	return &flattened
+}
+
+func appendFlattened(features []*feature, name string, value reflect.Value, separator string) []*feature {
+	if !value.IsValid() || isLeafValue(value.Interface()) {
+		return appendLeaf(features, name, value)
+	}
+
+	switch value.Kind() {
+	case reflect.Ptr, reflect.Interface:
+		if value.IsNil() {
+			return appendLeaf(features, name, value)
+		}
+		return appendFlattened(features, name, value.Elem(), separator)
+	case reflect.Map:
+		keys := make([]string, 0, value.Len())
+		values := make(map[string]reflect.Value, value.Len())
+		iter := value.MapRange()
+		for iter.Next() {
+			key, ok := mapKey(iter.Key())
+			if !ok {
+				return appendLeaf(features, name, value)
+			}
+			keys = append(keys, key)
+			values[key] = iter.Value()
+		}
+		sort.Strings(keys)
+		for _, key := range keys {
+			features = appendFlattened(features, name+separator+key, values[key], separator)
+		}
+		return features
+	case reflect.Struct:
+		fields, _ := structFields(value.Type())
+		for _, field := range fields {
+			fieldValue := value.FieldByIndex(field.index)
+			if field.omitEmpty && fieldValue.IsZero() {
+				continue
+			}
+			features = appendFlattened(features, name+separator+field.column, fieldValue, separator)
+		}
+		return features
+	}
+	return appendLeaf(features, name, value)
+}
+
+func appendLeaf(features []*feature, name string, value reflect.Value) []*feature {
+	var leaf interface{}
+	if value.IsValid() {
+		leaf = value.Interface()
+	}
+	return append(features, &feature{name: name, value: leaf})
+}
+
+// isLeafValue reports whether a struct value has its own encoding, and is not flattened
+func isLeafValue(value interface{}) bool {
+	switch value.(type) {
+	case time.Time, dateLike, decimalLike, big.Rat, big.Float, json.Marshaler, encoding.TextMarshaler:
+		return true
+	}
+	return false
+}
+
+func (e *FeatureEncoding) encodeTime(value time.Time) interface{} {
+	if e.TimeEpochUnit > 0 {
+		return value.UnixNano() / int64(e.TimeEpochUnit)
+	}
+
+	layout := e.TimeLayout
+	if layout == "" {
+		layout = time.RFC3339Nano
+	}
+	return value.In(e.location()).Format(layout)
+}
+
+// encodeDecimal returns a rational number as a json number, exact when it has a finite decimal representation
+func (e *FeatureEncoding) encodeDecimal(value *big.Rat) json.Number {
+	if value.IsInt() {
+		return json.Number(value.Num().String())
+	}
+
+	places, exact := decimalPlaces(value.Denom())
+	if !exact {
+		places = e.DecimalPlaces
+		if places <= 0 {
+			places = 18
+		}
+	}
+	return json.Number(value.FloatString(places))
+}
+
+// decimalPlaces returns the number of digits after the decimal point needed to represent a fraction
+// with denominator exactly, and false when it has no finite decimal representation
+func decimalPlaces(denominator *big.Int) (int, bool) {
+	remaining := new(big.Int).Set(denominator)
+	twos, fives := 0, 0
+	modulus := new(big.Int)
+	for _, factor := range []int64{2, 5} {
+		divisor := big.NewInt(factor)
+		for {
+			quotient, _ := new(big.Int).QuoRem(remaining, divisor, modulus)
+			if modulus.Sign() != 0 {
+				break
+			}
+			remaining = quotient
+			if factor == 2 {
+				twos++
+			} else {
+				fives++
+			}
+		}
+	}
+
+	if remaining.Cmp(big.NewInt(1)) != 0 {
+		return 0, false
+	}
+	if twos > fives {
+		return twos, true
+	}
+	return fives, true
+}
+
+func (e *FeatureEncoding) location() *time.Location {
+	if e.TimeLocation == nil {
+		return time.UTC
+	}
+	return e.TimeLocation
+}
+
+func durationOrDefault(value time.Duration, defaultValue time.Duration) time.Duration {
+	if value == 0 {
+		return defaultValue
+	}
+	return value
+}
@@ -0,0 +1,68 @@
+package qwak
+
+import (
+	"context"
+	"fmt"
+	gohttp "net/http"
+	"strings"
+
+	"github.com/qwak-ai/go-sdk/qwak/http"
+)
+
+// ContextHeaderExtractor maps a value carried by the caller context, such as a tenant or trace id,
+// into a header of the outgoing prediction requests. The header is omitted when ok is false
+type ContextHeaderExtractor func(ctx context.Context) (header string, value string, ok bool)
+
+// HeaderFromContextKey returns a ContextHeaderExtractor setting header to the value of ctx.Value(key),
+// when it is a string or a fmt.Stringer
+func HeaderFromContextKey(header string, key interface{}) ContextHeaderExtractor {
+	return func(ctx context.Context) (string, string, bool) {
+		switch value := ctx.Value(key).(type) {
+		case string:
+			return header, value, value != ""
+		case fmt.Stringer:
+			return header, value.String(), true
+		default:
+			return "", "", false
+		}
+	}
+}
+
+// decorateRequest sets the headers derived from the caller context and the prediction request
+func (c *RealTimeClient) decorateRequest(ctx context.Context, request *gohttp.Request, predictionRequest *PredictionRequest) error {
+	for _, extractor := range c.contextHeaders {
+		if header, value, ok := extractor(ctx); ok {
+			request.Header.Set(header, value)
+		}
+	}
+
+	c.setModelHeaders(request, predictionRequest.modelId)
+	c.setTags(request, predictionRequest)
+	setBinaryFeatures(request, c.featureEncoding.prepare(predictionRequest))
+	return c.setIdempotencyKey(request, predictionRequest)
+}
+
+// setBinaryFeatures lists the columns of binary features in a header, hinting the model to decode them from base64
+func setBinaryFeatures(request *gohttp.Request, predictionRequest *PredictionRequest) {
+	var columns []string
+	for _, vector := range predictionRequest.featuresVector {
+		for _, feature := range vector.features {
+			if _, ok := feature.value.(bytesValue); ok && !containsString(columns, feature.name) {
+				columns = append(columns, feature.name)
+			}
+		}
+	}
+
+	if len(columns) > 0 {
+		request.Header.Set(http.BinaryFeaturesHeader, strings.Join(columns, ","))
+	}
+}
+
+func containsString(values []string, value string) bool {
+	for _, existing := range values {
+		if existing == value {
+			return true
+		}
+	}
+	return false
+}
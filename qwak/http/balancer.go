@@ -0,0 +1,277 @@
+package http
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// BalancingStrategy selects the replica address of each request
+type BalancingStrategy int
+
+const (
+	// RoundRobin sends requests to the replica addresses in turn
+	RoundRobin BalancingStrategy = iota
+	// LeastPending sends requests to the replica address with the fewest requests in flight
+	LeastPending
+)
+
+// DefaultResolveInterval is how often the endpoint host is resolved again when balancing over dns
+const DefaultResolveInterval = 30 * time.Second
+
+// LoadBalancingOptions spreads the requests of the http client created by the SDK across several addresses
+// of the model endpoint, instead of sending them all through a single gateway address.
+// Requests are sent directly to the replicas, bypassing the configured proxies
+type LoadBalancingOptions struct {
+	// Addresses a static list of replica addresses as "host:port", default to all the addresses the endpoint host
+	// resolves to
+	Addresses []string
+	// Strategy how a replica address is selected, default to RoundRobin
+	Strategy BalancingStrategy
+	// ResolveInterval how often the endpoint host is resolved again, default to 30 seconds
+	ResolveInterval time.Duration
+}
+
+// Validate checks the static addresses include a port
+func (o *LoadBalancingOptions) Validate() error {
+	for _, address := range o.Addresses {
+		if _, _, err := net.SplitHostPort(address); err != nil {
+			return fmt.Errorf("invalid replica address '%s': %w", address, err)
+		}
+	}
+	return nil
+}
+
+// BalancingTransport is a round tripper spreading requests across the replica addresses of their host.
+// Each address has its own connection pool, so the host name is kept for tls verification
+type BalancingTransport struct {
+	options  TransportOptions
+	balancer LoadBalancingOptions
+	resolver *net.Resolver
+	now      func() time.Time
+
+	lock     sync.Mutex
+	hosts    map[string]*replicaSet
+	replicas map[string]*replica
+}
+
+type replicaSet struct {
+	lock       sync.Mutex
+	addresses  []string
+	resolvedAt time.Time
+	// next is guarded by the lock of the transport
+	next uint64
+}
+
+type replica struct {
+	// pending is first to be 64-bit aligned for atomic operations
+	pending   int64
+	transport *http.Transport
+}
+
+// NewBalancingTransport returns a transport balancing requests according to options.LoadBalancing,
+// with the SDK defaults overridden by options for the connections to every replica
+func NewBalancingTransport(options TransportOptions) *BalancingTransport {
+	balancer := LoadBalancingOptions{}
+	if options.LoadBalancing != nil {
+		balancer = *options.LoadBalancing
+	}
+
+	resolver := options.Resolver
+	if resolver == nil {
+		resolver = net.DefaultResolver
+	}
+
+	return &BalancingTransport{
+		options:  options,
+		balancer: balancer,
+		resolver: resolver,
+		now:      time.Now,
+		hosts:    map[string]*replicaSet{},
+		replicas: map[string]*replica{},
+	}
+}
+
+// RoundTrip sends the request to one of the replica addresses of its host
+func (t *BalancingTransport) RoundTrip(request *http.Request) (*http.Response, error) {
+	endpoint := endpointAddress(request)
+	addresses, err := t.addressesOf(request.Context(), endpoint)
+	if err != nil {
+		return nil, err
+	}
+
+	selected := t.selectReplica(endpoint, addresses)
+	atomic.AddInt64(&selected.pending, 1)
+
+	response, err := selected.transport.RoundTrip(request)
+	if err != nil {
+		atomic.AddInt64(&selected.pending, -1)
+		return nil, err
+	}
+
+	response.Body = &pendingBody{ReadCloser: response.Body, replica: selected}
+	return response, nil
+}
+
+// CloseIdleConnections closes the idle connections to every replica
+func (t *BalancingTransport) CloseIdleConnections() {
+	t.lock.Lock()
+	defer t.lock.Unlock()
+
+	for _, replica := range t.replicas {
+		replica.transport.CloseIdleConnections()
+	}
+}
+
+// Pending returns the number of requests in flight per replica address
+func (t *BalancingTransport) Pending() map[string]int64 {
+	t.lock.Lock()
+	defer t.lock.Unlock()
+
+	pending := make(map[string]int64, len(t.replicas))
+	for address, replica := range t.replicas {
+		pending[address] = atomic.LoadInt64(&replica.pending)
+	}
+	return pending
+}
+
+func endpointAddress(request *http.Request) string {
+	port := request.URL.Port()
+	if port == "" {
+		port = "80"
+		if request.URL.Scheme == "https" {
+			port = "443"
+		}
+	}
+	return net.JoinHostPort(request.URL.Hostname(), port)
+}
+
+// addressesOf returns the replica addresses of the endpoint, resolving its host once the addresses are stale.
+// The previous addresses are kept when resolving fails
+func (t *BalancingTransport) addressesOf(ctx context.Context, endpoint string) ([]string, error) {
+	if len(t.balancer.Addresses) > 0 {
+		return t.balancer.Addresses, nil
+	}
+
+	t.lock.Lock()
+	set, exists := t.hosts[endpoint]
+	if !exists {
+		set = &replicaSet{}
+		t.hosts[endpoint] = set
+	}
+	t.lock.Unlock()
+
+	set.lock.Lock()
+	defer set.lock.Unlock()
+
+	if len(set.addresses) > 0 && t.now().Sub(set.resolvedAt) < durationOrDefault(t.balancer.ResolveInterval, DefaultResolveInterval) {
+		return set.addresses, nil
+	}
+
+	host, port, _ := net.SplitHostPort(endpoint)
+	ips, err := t.resolver.LookupIPAddr(ctx, host)
+	if err != nil || len(ips) == 0 {
+		if len(set.addresses) > 0 {
+			return set.addresses, nil
+		}
+		return nil, fmt.Errorf("failed to resolve replicas of '%s': %w", host, err)
+	}
+
+	addresses := make([]string, len(ips))
+	for idx, ip := range ips {
+		addresses[idx] = net.JoinHostPort(ip.String(), port)
+	}
+
+	t.releaseRemovedReplicas(set.addresses, addresses)
+	set.addresses = addresses
+	set.resolvedAt = t.now()
+	return addresses, nil
+}
+
+// releaseRemovedReplicas closes the connections to addresses no longer resolved
+func (t *BalancingTransport) releaseRemovedReplicas(previous []string, current []string) {
+	kept := make(map[string]bool, len(current))
+	for _, address := range current {
+		kept[address] = true
+	}
+
+	t.lock.Lock()
+	defer t.lock.Unlock()
+
+	for _, address := range previous {
+		if replica, exists := t.replicas[address]; exists && !kept[address] {
+			replica.transport.CloseIdleConnections()
+			delete(t.replicas, address)
+		}
+	}
+}
+
+func (t *BalancingTransport) selectReplica(endpoint string, addresses []string) *replica {
+	t.lock.Lock()
+	defer t.lock.Unlock()
+
+	set, exists := t.hosts[endpoint]
+	if !exists {
+		set = &replicaSet{}
+		t.hosts[endpoint] = set
+	}
+
+	start := int(set.next % uint64(len(addresses)))
+	set.next++
+	selected := t.replicaOf(addresses[start])
+
+	if t.balancer.Strategy == LeastPending {
+		for i := 1; i < len(addresses); i++ {
+			candidate := t.replicaOf(addresses[(start+i)%len(addresses)])
+			if atomic.LoadInt64(&candidate.pending) < atomic.LoadInt64(&selected.pending) {
+				selected = candidate
+			}
+		}
+	}
+
+	return selected
+}
+
+// replicaOf must be called with the lock held
+func (t *BalancingTransport) replicaOf(address string) *replica {
+	if existing, exists := t.replicas[address]; exists {
+		return existing
+	}
+
+	options := t.options
+	wrapDialContext := options.WrapDialContext
+	options.WrapDialContext = func(dial DialContextFunc) DialContextFunc {
+		if wrapDialContext != nil {
+			dial = wrapDialContext(dial)
+		}
+		return func(ctx context.Context, network, _ string) (net.Conn, error) {
+			return dial(ctx, network, address)
+		}
+	}
+
+	transport := NewTransport(options)
+	transport.Proxy = nil
+
+	created := &replica{transport: transport}
+	t.replicas[address] = created
+	return created
+}
+
+// pendingBody marks the request of its replica as completed once closed
+type pendingBody struct {
+	io.ReadCloser
+	replica *replica
+	once    sync.Once
+}
+
+func (b *pendingBody) Close() error {
+	b.once.Do(func() {
+		atomic.AddInt64(&b.replica.pending, -1)
+	})
+	return b.ReadCloser.Close()
+}
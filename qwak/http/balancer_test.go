@@ -0,0 +1,63 @@
+package http
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func newReplica(t *testing.T, name string) *httptest.Server {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		_, _ = w.Write([]byte(name))
+	}))
+	t.Cleanup(server.Close)
+	return server
+}
+
+func getReplicaName(t *testing.T, client *http.Client, keepOpen bool) string {
+	response, err := client.Get("http://replicas.qwak.test/v1/otf/predict")
+	require.NoError(t, err)
+
+	body, err := readAll(response.Body)
+	require.NoError(t, err)
+	if !keepOpen {
+		require.NoError(t, response.Body.Close())
+	}
+	return string(body)
+}
+
+func TestBalancingTransportRoundRobin(t *testing.T) {
+	first, second := newReplica(t, "a"), newReplica(t, "b")
+	client := GetHttpClient(TransportOptions{LoadBalancing: &LoadBalancingOptions{
+		Addresses: []string{strings.TrimPrefix(first.URL, "http://"), strings.TrimPrefix(second.URL, "http://")},
+	}})
+
+	var names []string
+	for i := 0; i < 4; i++ {
+		names = append(names, getReplicaName(t, client, false))
+	}
+
+	require.Equal(t, []string{"a", "b", "a", "b"}, names)
+}
+
+func TestBalancingTransportLeastPending(t *testing.T) {
+	first, second := newReplica(t, "a"), newReplica(t, "b")
+	transport := NewBalancingTransport(TransportOptions{LoadBalancing: &LoadBalancingOptions{
+		Addresses: []string{strings.TrimPrefix(first.URL, "http://"), strings.TrimPrefix(second.URL, "http://")},
+		Strategy:  LeastPending,
+	}})
+	client := &http.Client{Transport: transport}
+
+	require.Equal(t, "a", getReplicaName(t, client, true))
+	require.Equal(t, "b", getReplicaName(t, client, false))
+	require.Equal(t, "b", getReplicaName(t, client, false))
+	require.Equal(t, int64(1), transport.Pending()[strings.TrimPrefix(first.URL, "http://")])
+}
+
+func TestLoadBalancingOptionsValidate(t *testing.T) {
+	require.NoError(t, (&LoadBalancingOptions{Addresses: []string{"10.0.0.1:443"}}).Validate())
+	require.Error(t, (&LoadBalancingOptions{Addresses: []string{"10.0.0.1"}}).Validate())
+}
@@ -1,11 +1,15 @@
 package http
 
 import (
+	"context"
+	"crypto/tls"
+	"errors"
 	"fmt"
-	"io/ioutil"
+	"io"
 	"math"
 	"net"
 	"net/http"
+	"strconv"
 	"strings"
 	"time"
 )
@@ -13,126 +17,450 @@ import (
 const (
 	MaximumRetryAttempts = 5
 	RetryDelay           = 500 * time.Millisecond
+	DefaultMaxRetryAfter = 30 * time.Second
+	// MaximumBackoffFactor the default upper bound of RetryPolicy.ExponentialBackoffFactor
+	MaximumBackoffFactor = 3
 )
 
+// ErrDeadlineBudgetExhausted is returned when the remaining context deadline cannot
+// accommodate the backoff and another attempt, so retrying was stopped early
+var ErrDeadlineBudgetExhausted = errors.New("deadline budget exhausted")
+
+// ErrRetryAfterExceedsLimit is returned when the server asked to retry after a longer delay than
+// RetryPolicy.MaxRetryAfter, so retrying was stopped early
+var ErrRetryAfterExceedsLimit = errors.New("server suggested retry delay exceeds the limit")
+
+// ErrBodyNotReplayable is returned when a failed request cannot be retried because its body was consumed
+// and cannot be rebuilt, i.e. the request has no GetBody function. See SetBodyFactory
+var ErrBodyNotReplayable = errors.New("request body cannot be replayed")
+
 type Client interface {
 	Do(request *http.Request) (*http.Response, error)
 }
 
+// TransportOptions tunes the transport of the http client created by the SDK.
+// Zero values keep the SDK defaults
+type TransportOptions struct {
+	// MaxIdleConns the maximum number of idle connections across all hosts, default to 100
+	MaxIdleConns int
+	// MaxIdleConnsPerHost the maximum number of idle connections kept per host, default to 30
+	MaxIdleConnsPerHost int
+	// MaxConnsPerHost the maximum number of connections per host, including active ones, default to 30
+	MaxConnsPerHost int
+	// IdleConnTimeout how long an idle connection is kept open, default to 20 seconds
+	IdleConnTimeout time.Duration
+	// DialTimeout the timeout of establishing a tcp connection, default to 30 seconds
+	DialTimeout time.Duration
+	// DialKeepAlive the interval of tcp keep-alive probes, default to 30 seconds
+	DialKeepAlive time.Duration
+	// TLSHandshakeTimeout the timeout of the tls handshake, default to 10 seconds
+	TLSHandshakeTimeout time.Duration
+	// ResponseHeaderTimeout the time to wait for the response headers once the request is written,
+	// which for predictions is mostly the model inference time. Not limited by default
+	ResponseHeaderTimeout time.Duration
+	// DisableHTTP2 use HTTP/1.1 only, HTTP/2 is attempted by default
+	DisableHTTP2 bool
+	// Proxy configures the proxies explicitly, default to the proxies set in the environment
+	Proxy *ProxyOptions
+	// Resolver the dns resolver of the dialer, default to net.DefaultResolver
+	Resolver *net.Resolver
+	// WrapDialContext wraps the dialer configured by the options, e.g. to pin an address to a specific endpoint
+	// by rewriting addr before calling dial, or to replace dialing entirely by not calling it
+	WrapDialContext func(dial DialContextFunc) DialContextFunc
+	// LoadBalancing spreads requests across the replica addresses of the endpoint, disabled by default
+	LoadBalancing *LoadBalancingOptions
+	// WrapRoundTripper wraps the transport configured by the options with middleware, e.g. otelhttp.NewTransport
+	WrapRoundTripper func(transport http.RoundTripper) http.RoundTripper
+}
+
+// DialContextFunc establishes a connection, as net.Dialer.DialContext
+type DialContextFunc func(ctx context.Context, network, addr string) (net.Conn, error)
+
 func GetDefaultHttpClient() *http.Client {
+	return GetHttpClient(TransportOptions{})
+}
+
+// GetHttpClient returns an http client with the SDK transport defaults, overridden by options
+func GetHttpClient(options TransportOptions) *http.Client {
+	var transport http.RoundTripper = NewTransport(options)
+	if options.LoadBalancing != nil {
+		transport = NewBalancingTransport(options)
+	}
+
+	if options.WrapRoundTripper != nil {
+		transport = options.WrapRoundTripper(transport)
+	}
+
 	return &http.Client{
-		Transport: &http.Transport{
-			Proxy: http.ProxyFromEnvironment,
-			DialContext: (&net.Dialer{
-				Timeout:   30 * time.Second,
-				KeepAlive: 30 * time.Second,
-			}).DialContext,
-			TLSHandshakeTimeout:   10 * time.Second,
-			MaxIdleConns:          100,
-			MaxIdleConnsPerHost:   30,
-			MaxConnsPerHost:       30,
-			IdleConnTimeout:       20 * time.Second,
-			ExpectContinueTimeout: 1 * time.Second,
-			ForceAttemptHTTP2:     true,
-		},
-		Timeout: 3 * time.Second,
-	}
-}
-
-func executeRequest(client Client, request *http.Request) (responseBody []byte, httpCode int, err error) {
+		Transport: transport,
+		Timeout:   3 * time.Second,
+	}
+}
+
+// NewTransport returns a transport with the SDK defaults, overridden by options
+func NewTransport(options TransportOptions) *http.Transport {
+	var dialContext DialContextFunc = (&net.Dialer{
+		Timeout:   durationOrDefault(options.DialTimeout, 30*time.Second),
+		KeepAlive: durationOrDefault(options.DialKeepAlive, 30*time.Second),
+		Resolver:  options.Resolver,
+	}).DialContext
+
+	if options.WrapDialContext != nil {
+		dialContext = options.WrapDialContext(dialContext)
+	}
+
+	transport := &http.Transport{
+		Proxy:                 http.ProxyFromEnvironment,
+		DialContext:           dialContext,
+		TLSHandshakeTimeout:   durationOrDefault(options.TLSHandshakeTimeout, 10*time.Second),
+		ResponseHeaderTimeout: options.ResponseHeaderTimeout,
+		MaxIdleConns:          intOrDefault(options.MaxIdleConns, 100),
+		MaxIdleConnsPerHost:   intOrDefault(options.MaxIdleConnsPerHost, 30),
+		MaxConnsPerHost:       intOrDefault(options.MaxConnsPerHost, 30),
+		IdleConnTimeout:       durationOrDefault(options.IdleConnTimeout, 20*time.Second),
+		ExpectContinueTimeout: 1 * time.Second,
+		ForceAttemptHTTP2:     !options.DisableHTTP2,
+	}
+
+	if options.Proxy != nil {
+		transport.Proxy = options.Proxy.proxyFunc()
+	}
+
+	if options.DisableHTTP2 {
+		// a non nil empty map disables the automatic HTTP/2 upgrade
+		transport.TLSNextProto = map[string]func(string, *tls.Conn) http.RoundTripper{}
+	}
+
+	return transport
+}
+
+func durationOrDefault(value time.Duration, defaultValue time.Duration) time.Duration {
+	if value == 0 {
+		return defaultValue
+	}
+	return value
+}
+
+func intOrDefault(value int, defaultValue int) int {
+	if value == 0 {
+		return defaultValue
+	}
+	return value
+}
+
+func executeRequest(client Client, request *http.Request) (responseBody []byte, httpCode int, header http.Header, err error) {
 
 	response, err := client.Do(request)
 
 	if err != nil {
-		return nil, 0, fmt.Errorf("an error occured when http request performed: %w", err)
+		return nil, 0, nil, fmt.Errorf("an error occured when http request performed: %w", err)
 	}
 	defer response.Body.Close()
 
-	body, err := ioutil.ReadAll(response.Body)
+	body, err := readAll(response.Body)
 
 	if err != nil {
-		return nil, response.StatusCode, fmt.Errorf("failed to parse request body: %w", err)
+		return nil, response.StatusCode, response.Header, fmt.Errorf("failed to parse request body: %w", err)
 	}
 
-	return body, response.StatusCode, nil
+	return body, response.StatusCode, response.Header, nil
 
 }
 
 func DoRequestWithRetry(client Client, request *http.Request, policy RetryPolicy) (responseBody []byte, statusCode int, err error) {
-	var lastHttpCode int
-	var errs []string
+	result, err := DoRequest(client, request, policy)
+	return result.Body, result.StatusCode, err
+}
+
+// RequestResult is the outcome of a request performed by DoRequest
+type RequestResult struct {
+	// Body the body of the last response
+	Body []byte
+	// StatusCode the status code of the last response, 0 if no response was received
+	StatusCode int
+	// Header the headers of the last response
+	Header http.Header
+	// Attempts the number of attempts performed
+	Attempts int
+	// Duration the total duration of the request, including the backoffs between attempts
+	Duration time.Duration
+}
+
+// DoRequest performs the request, retrying it on failures according to policy.
+// The result is returned even when the request failed, describing the last attempt
+func DoRequest(client Client, request *http.Request, policy RetryPolicy) (*RequestResult, error) {
 	var lastErr error
-	var body []byte
+	result := &RequestResult{}
+	retryErr := &RetryError{}
+	clock := clockOrSystem(policy.Clock)
+	start := clock.Now()
+	hooks := requestHooksFrom(request.Context())
+	classAttempts := map[FailureClass]int{}
 
 	for retryAttempt := 0; retryAttempt < policy.getMaxAttempts() && (retryAttempt == 0 || lastErr != nil); retryAttempt++ {
 
+		attemptStart := clock.Now()
+
 		if request.Context().Err() != nil {
 			lastErr = request.Context().Err()
-			errs = append(errs, fmt.Sprintf("Attempt #%d discarded: %v", retryAttempt, lastErr.Error()))
+			retryErr.Attempts = append(retryErr.Attempts, AttemptFailure{Attempt: retryAttempt, Err: lastErr, Discarded: true})
 			break
 		} else {
-			body, lastHttpCode, lastErr = executeRequest(client, request)
+			attemptRequest, cancelAttempt, err := policy.attemptRequest(request, retryAttempt)
+			if err != nil {
+				lastErr = err
+				retryErr.Attempts = append(retryErr.Attempts, AttemptFailure{Attempt: retryAttempt, Err: lastErr, Discarded: true})
+				break
+			}
+			hooks.onRequest(RequestEvent{Request: attemptRequest, Attempt: retryAttempt})
+			result.Body, result.StatusCode, result.Header, lastErr = executeRequest(client, attemptRequest)
+			result.Attempts++
+			cancelAttempt()
 		}
 
-		if lastErr == nil && lastHttpCode >= 500 {
-			lastErr = fmt.Errorf("request failed with status code '%d'", lastHttpCode)
+		if lastErr == nil && isRetryableStatusCode(result.StatusCode) {
+			lastErr = fmt.Errorf("request failed with status code '%d'", result.StatusCode)
 		}
 
 		if lastErr != nil {
-			if lastErr != nil {
-				errs = append(errs, fmt.Sprintf("Attempt #%d: %v", retryAttempt, lastErr.Error()))
+			class := classifyFailure(result.StatusCode, lastErr)
+			retryErr.Attempts = append(retryErr.Attempts, AttemptFailure{Attempt: retryAttempt, StatusCode: result.StatusCode, Err: lastErr, Class: class})
+
+			if retryAttempt+1 >= policy.getMaxAttempts() || errors.Is(lastErr, ErrResponseTooLarge) {
+				break
+			}
+
+			classPolicy := policy.classPolicy(class)
+			classAttempts[class]++
+			if classPolicy.Disabled || (classPolicy.MaxAttempts > 0 && classAttempts[class] >= classPolicy.MaxAttempts) {
+				break
 			}
-			duration := time.Duration(policy.getBackoffForAttempt(retryAttempt+1)) * time.Millisecond
+
+			duration := policy.getBackoff(retryAttempt + 1)
+			if classPolicy.NoBackoff {
+				duration = 0
+			}
+			if duration < classPolicy.MinBackoff {
+				duration = classPolicy.MinBackoff
+			}
+
+			if retryAfter, ok := parseRetryAfter(result.Header, clock.Now()); ok {
+				if retryAfter > policy.getMaxRetryAfter() {
+					retryErr.Cause = ErrRetryAfterExceedsLimit
+					break
+				}
+				duration = retryAfter
+			}
+
+			if !hasBudgetForAttempt(request.Context(), duration, clock.Now().Sub(attemptStart)) {
+				retryErr.Cause = ErrDeadlineBudgetExhausted
+				break
+			}
+
+			retryErr.Attempts[len(retryErr.Attempts)-1].Backoff = duration
+			hooks.onRetry(RequestEvent{
+				Request:    request,
+				Attempt:    retryAttempt,
+				StatusCode: result.StatusCode,
+				Err:        lastErr,
+				Backoff:    duration,
+				Duration:   clock.Now().Sub(attemptStart),
+			})
 
 			select {
 			case <-request.Context().Done():
-			case <-time.After(duration):
+			case <-clock.After(duration):
 			}
 		}
 	}
+	result.Duration = clock.Now().Sub(start)
 	if lastErr != nil {
-		return body, lastHttpCode, fmt.Errorf("failed to perform reqesut: %w", joinErrors(errs))
+		lastErr = fmt.Errorf("failed to perform reqesut: %w", retryErr)
+	}
+
+	hooks.onResponse(RequestEvent{
+		Request:    request,
+		Attempt:    result.Attempts,
+		StatusCode: result.StatusCode,
+		Err:        lastErr,
+		Duration:   result.Duration,
+	})
+	return result, lastErr
+
+}
+
+// isRetryableStatusCode reports whether a response status code is a transient failure worth retrying
+func isRetryableStatusCode(statusCode int) bool {
+	return statusCode >= 500 || statusCode == http.StatusTooManyRequests || statusCode == http.StatusRequestTimeout
+}
+
+// parseRetryAfter parses the Retry-After header, holding either delay seconds or an http date
+func parseRetryAfter(header http.Header, now time.Time) (time.Duration, bool) {
+	value := strings.TrimSpace(header.Get(RetryAfterHeader))
+	if value == "" {
+		return 0, false
+	}
+
+	if seconds, err := strconv.Atoi(value); err == nil {
+		if seconds < 0 {
+			return 0, false
+		}
+		return time.Duration(seconds) * time.Second, true
 	}
-	return body, lastHttpCode, nil
 
+	if date, err := http.ParseTime(value); err == nil {
+		delay := date.Sub(now)
+		if delay < 0 {
+			delay = 0
+		}
+		return delay, true
+	}
+
+	return 0, false
 }
 
-func joinErrors(errs []string) error {
-	return fmt.Errorf("all attemptes has been failed:[%s]", strings.Join(errs, ";"))
+// hasBudgetForAttempt reports whether the context deadline, if any, leaves enough time
+// to wait for the backoff and perform another attempt. The duration of the previous
+// attempt is used as an estimate of how long the next one will take.
+func hasBudgetForAttempt(ctx context.Context, backoff time.Duration, attemptDuration time.Duration) bool {
+	deadline, ok := ctx.Deadline()
+	if !ok {
+		return true
+	}
+
+	return time.Until(deadline) > backoff+attemptDuration
 }
 
 type RetryPolicy struct {
-	// MaxAttempts number of attempts on failure
+	// MaxAttempts number of attempts on failure, at most MaxAttemptsLimit
 	MaxAttempts int
-	// IntervalMs the duration to wait before retry
-	// wait time = IntervalMs * (ExponentialBackoffFactor ^ attempt no.)
+	// MaxAttemptsLimit overrides the safety limit of MaxAttempts, default to MaximumRetryAttempts.
+	// Raise it explicitly for long, patient retry loops, e.g. in batch jobs
+	MaxAttemptsLimit int
+	// IntervalMs the duration to wait before the first retry, in milliseconds, up to MaxBackoff.
+	// wait time = IntervalMs * (ExponentialBackoffFactor ^ attempt no.), the failed attempt no. being zero based.
+	// When not set, the wait is (ExponentialBackoffFactor ^ (attempt no. + 1))^2 milliseconds
 	IntervalMs int
 	// ExponentialBackoffFactor == 1 - Linear; ExponentialBackoffFactor > 1 - Exponential
 	// wait time = IntervalMs * (ExponentialBackoffFactor ^ attempt no.)
 	ExponentialBackoffFactor float64
+	// MaxBackoffFactor overrides the safety limit of ExponentialBackoffFactor, default to MaximumBackoffFactor
+	MaxBackoffFactor float64
+	// MaxBackoff the longest wait between attempts, not limited when 0
+	MaxBackoff time.Duration
+	// MaxRetryAfter the longest server suggested delay, from a Retry-After header, to wait before a retry.
+	// A longer suggested delay stops retrying. Default to 30 seconds
+	MaxRetryAfter time.Duration
+	// PerTryTimeout bounds the duration of each attempt, while the overall call obeys the request context deadline.
+	// An attempt timing out is retried. Not limited by default
+	PerTryTimeout time.Duration
+	// Clock measures attempts and waits for backoffs, default to SystemClock
+	Clock Clock
+	// Throttled overrides how 429 responses are retried
+	Throttled RetryClassPolicy
+	// Unavailable overrides how 503 responses are retried
+	Unavailable RetryClassPolicy
+	// Timeout overrides how 408 and 504 responses, and attempts timing out, are retried
+	Timeout RetryClassPolicy
+}
+
+// attemptRequest returns a copy of the request to perform an attempt with, bound to PerTryTimeout if set.
+// Retries get a fresh body from GetBody, as the previous attempt may have consumed it.
+// The returned cancel function must be called once the attempt completed
+func (r *RetryPolicy) attemptRequest(request *http.Request, attempt int) (*http.Request, context.CancelFunc, error) {
+	ctx, cancel := request.Context(), context.CancelFunc(func() {})
+	if r.PerTryTimeout > 0 {
+		ctx, cancel = context.WithTimeout(ctx, r.PerTryTimeout)
+	}
+
+	attemptRequest := request.Clone(ctx)
+	if attempt == 0 || request.Body == nil || request.Body == http.NoBody {
+		return attemptRequest, cancel, nil
+	}
+
+	if request.GetBody == nil {
+		cancel()
+		return nil, nil, ErrBodyNotReplayable
+	}
+
+	body, err := request.GetBody()
+	if err != nil {
+		cancel()
+		return nil, nil, fmt.Errorf("failed to rebuild the request body: %w", err)
+	}
+	attemptRequest.Body = body
+	return attemptRequest, cancel, nil
+}
+
+// SetBodyFactory sets the body of the request from factory, which is called again to rebuild the body
+// of each retry. Requests created with a *bytes.Buffer, *bytes.Reader or *strings.Reader body are already replayable
+func SetBodyFactory(request *http.Request, factory func() (io.ReadCloser, error)) error {
+	body, err := factory()
+	if err != nil {
+		return err
+	}
+
+	request.Body = body
+	request.GetBody = factory
+	return nil
 }
 
 func (r *RetryPolicy) hasRetryPolicy() bool {
 	return r.MaxAttempts > 1
 }
 
-func (r *RetryPolicy) getBackoffForAttempt(attempt int) int {
+// getBackoff returns the wait before the attempt, the first retry being attempt 1
+func (r *RetryPolicy) getBackoff(attempt int) time.Duration {
+	var backoff float64
+	if r.IntervalMs <= 0 {
+		backoffMultiplier := math.Floor(math.Pow(r.getBackoffFactor(), float64(attempt)))
+		backoff = backoffMultiplier * backoffMultiplier * float64(time.Millisecond)
+	} else {
+		backoff = float64(r.IntervalMs) * float64(time.Millisecond) * math.Pow(r.getBackoffFactor(), float64(attempt-1))
+	}
+
+	if r.MaxBackoff > 0 && backoff > float64(r.MaxBackoff) {
+		return r.MaxBackoff
+	}
+	if backoff >= math.MaxInt64 {
+		return time.Duration(math.MaxInt64)
+	}
+	return time.Duration(backoff)
+}
+
+func (r *RetryPolicy) getBackoffFactor() float64 {
 	factor := r.ExponentialBackoffFactor
 	if factor < 1 {
 		factor = 1
 	}
 
-	if factor > 3 {
-		factor = 3
+	maxFactor := r.MaxBackoffFactor
+	if maxFactor < 1 {
+		maxFactor = MaximumBackoffFactor
 	}
 
-	backoffMultiplier := int(math.Floor(math.Pow(factor, float64(attempt))))
-	return backoffMultiplier * backoffMultiplier
+	if factor > maxFactor {
+		factor = maxFactor
+	}
+
+	return factor
+}
+
+func (r *RetryPolicy) getMaxRetryAfter() time.Duration {
+	if r.MaxRetryAfter <= 0 {
+		return DefaultMaxRetryAfter
+	}
+	return r.MaxRetryAfter
 }
 
 func (r *RetryPolicy) getMaxAttempts() int {
-	if r.MaxAttempts > 5 {
-		return 5
+	limit := r.MaxAttemptsLimit
+	if limit < 1 {
+		limit = MaximumRetryAttempts
+	}
+
+	if r.MaxAttempts > limit {
+		return limit
 	}
 
 	if r.MaxAttempts < 1 {
@@ -149,3 +477,80 @@ func BasicExponentialBackoffRetryPolicy() RetryPolicy {
 		ExponentialBackoffFactor: 2,
 	}
 }
+
+// AggressiveRetryPolicy retries quickly and up to 5 attempts, waiting 50ms then 1.5 times longer before
+// each retry, at most 500ms. Suited to interactive calls on flaky networks, at the cost of extra load
+// on a struggling model
+func AggressiveRetryPolicy() RetryPolicy {
+	return RetryPolicy{
+		MaxAttempts:              5,
+		IntervalMs:               50,
+		ExponentialBackoffFactor: 1.5,
+		MaxBackoff:               500 * time.Millisecond,
+		MaxRetryAfter:            time.Second,
+	}
+}
+
+// LatencySensitiveRetryPolicy makes at most 2 attempts, retrying after 10ms. Throttled requests are not retried,
+// and a Retry-After longer than 100ms stops retrying. Suited to calls within a tight latency budget,
+// which prefer failing fast, e.g. to a fallback, over waiting
+func LatencySensitiveRetryPolicy() RetryPolicy {
+	return RetryPolicy{
+		MaxAttempts:              2,
+		IntervalMs:               10,
+		ExponentialBackoffFactor: 1,
+		MaxRetryAfter:            100 * time.Millisecond,
+		Throttled:                RetryClassPolicy{Disabled: true},
+		Timeout:                  RetryClassPolicy{NoBackoff: true},
+	}
+}
+
+// BatchRetryPolicy makes up to 10 attempts with long waits: 1s then twice longer before each retry, at most 30s,
+// and at least 5s after a throttled attempt. Retry-After delays up to 2 minutes are honored. Suited to batch
+// and offline jobs, which prefer completing slowly over failing
+func BatchRetryPolicy() RetryPolicy {
+	return RetryPolicy{
+		MaxAttempts:              10,
+		MaxAttemptsLimit:         10,
+		IntervalMs:               1000,
+		ExponentialBackoffFactor: 2,
+		MaxBackoff:               30 * time.Second,
+		MaxRetryAfter:            2 * time.Minute,
+		Throttled:                RetryClassPolicy{MinBackoff: 5 * time.Second},
+	}
+}
+
+// DoStreamingRequest performs a request whose response body is consumed incrementally by the caller.
+// Streaming requests are not retried, since a partially consumed response cannot be replayed, and failures
+// are returned as a RetryError of a single attempt. The request hooks are invoked once the response headers
+// are received. On success the caller must close the response body
+func DoStreamingRequest(client Client, request *http.Request) (*http.Response, error) {
+	start := time.Now()
+	hooks := requestHooksFrom(request.Context())
+	hooks.onRequest(RequestEvent{Request: request})
+
+	statusCode := 0
+	response, err := client.Do(request)
+
+	if err != nil {
+		err = fmt.Errorf("an error occured when http request performed: %w", err)
+	} else if statusCode = response.StatusCode; statusCode != http.StatusOK {
+		body, _ := readAll(io.LimitReader(response.Body, 4096))
+		response.Body.Close()
+		response = nil
+		err = fmt.Errorf("request failed with status code '%d'. response: %s", statusCode, body)
+	}
+
+	if err != nil {
+		err = &RetryError{Attempts: []AttemptFailure{{StatusCode: statusCode, Err: err, Class: classifyFailure(statusCode, err)}}}
+	}
+
+	hooks.onResponse(RequestEvent{
+		Request:    request,
+		Attempt:    1,
+		StatusCode: statusCode,
+		Err:        err,
+		Duration:   time.Since(start),
+	})
+	return response, err
+}
@@ -0,0 +1,128 @@
+package http
+
+import (
+	"context"
+	"io"
+	"math"
+	"net/http"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestParseRetryAfter(t *testing.T) {
+	now := time.Date(2023, 1, 1, 10, 0, 0, 0, time.UTC)
+
+	delay, ok := parseRetryAfter(http.Header{"Retry-After": []string{"3"}}, now)
+	require.True(t, ok)
+	require.Equal(t, 3*time.Second, delay)
+
+	delay, ok = parseRetryAfter(http.Header{"Retry-After": []string{now.Add(90 * time.Second).Format(http.TimeFormat)}}, now)
+	require.True(t, ok)
+	require.Equal(t, 90*time.Second, delay)
+
+	_, ok = parseRetryAfter(http.Header{"Retry-After": []string{"soon"}}, now)
+	require.False(t, ok)
+
+	_, ok = parseRetryAfter(nil, now)
+	require.False(t, ok)
+}
+
+func TestNewTransportAppliesTimeouts(t *testing.T) {
+	transport := NewTransport(TransportOptions{
+		TLSHandshakeTimeout:   time.Second,
+		ResponseHeaderTimeout: 30 * time.Second,
+	})
+
+	require.Equal(t, time.Second, transport.TLSHandshakeTimeout)
+	require.Equal(t, 30*time.Second, transport.ResponseHeaderTimeout)
+	require.Equal(t, 20*time.Second, transport.IdleConnTimeout)
+}
+
+func TestRetryPolicyPresets(t *testing.T) {
+	batch := BatchRetryPolicy()
+	require.Equal(t, time.Second, batch.getBackoff(1))
+	require.Equal(t, 2*time.Second, batch.getBackoff(2))
+	require.Equal(t, 30*time.Second, batch.getBackoff(10))
+
+	aggressive := AggressiveRetryPolicy()
+	require.Equal(t, 50*time.Millisecond, aggressive.getBackoff(1))
+	require.Equal(t, 75*time.Millisecond, aggressive.getBackoff(2))
+	require.Equal(t, 500*time.Millisecond, aggressive.getBackoff(8))
+
+	latency := LatencySensitiveRetryPolicy()
+	require.Equal(t, 2, latency.getMaxAttempts())
+	require.Equal(t, 10*time.Millisecond, latency.getBackoff(1))
+	require.True(t, latency.classPolicy(FailureThrottled).Disabled)
+
+	basic := BasicExponentialBackoffRetryPolicy()
+	require.Equal(t, 200*time.Millisecond, basic.getBackoff(1))
+	require.Equal(t, 400*time.Millisecond, basic.getBackoff(2))
+
+	legacy := RetryPolicy{MaxAttempts: 3, ExponentialBackoffFactor: 2}
+	require.Equal(t, 4*time.Millisecond, legacy.getBackoff(1))
+}
+
+func TestRetryPolicySafetyLimits(t *testing.T) {
+	policy := RetryPolicy{MaxAttempts: 12, ExponentialBackoffFactor: 5, IntervalMs: 1000}
+	require.Equal(t, MaximumRetryAttempts, policy.getMaxAttempts())
+	require.Equal(t, 9*time.Second, policy.getBackoff(3))
+
+	policy.MaxAttemptsLimit = 20
+	policy.MaxBackoffFactor = 10
+	require.Equal(t, 12, policy.getMaxAttempts())
+	require.Equal(t, 25*time.Second, policy.getBackoff(3))
+	require.Equal(t, time.Duration(math.MaxInt64), policy.getBackoff(1000))
+}
+
+// bodyRecordingClient consumes and records the request bodies, responding with 503 to the first request
+type bodyRecordingClient struct {
+	bodies []string
+}
+
+func (c *bodyRecordingClient) Do(request *http.Request) (*http.Response, error) {
+	body, err := io.ReadAll(request.Body)
+	if err != nil {
+		return nil, err
+	}
+	c.bodies = append(c.bodies, string(body))
+
+	status := http.StatusOK
+	if len(c.bodies) == 1 {
+		status = http.StatusServiceUnavailable
+	}
+	return &http.Response{StatusCode: status, Header: http.Header{}, Body: io.NopCloser(strings.NewReader("{}"))}, nil
+}
+
+func TestRetryRebuildsRequestBody(t *testing.T) {
+	policy := RetryPolicy{MaxAttempts: 2, Clock: instantClock{}}
+
+	request, err := http.NewRequestWithContext(context.Background(), "POST", "http://model/predict", strings.NewReader(`{"a":1}`))
+	require.NoError(t, err)
+	client := &bodyRecordingClient{}
+	_, err = DoRequest(client, request, policy)
+	require.NoError(t, err)
+	require.Equal(t, []string{`{"a":1}`, `{"a":1}`}, client.bodies)
+
+	request, err = http.NewRequestWithContext(context.Background(), "POST", "http://model/predict", nil)
+	require.NoError(t, err)
+	factoryCalls := 0
+	require.NoError(t, SetBodyFactory(request, func() (io.ReadCloser, error) {
+		factoryCalls++
+		return io.NopCloser(strings.NewReader(`{"b":2}`)), nil
+	}))
+	client = &bodyRecordingClient{}
+	_, err = DoRequest(client, request, policy)
+	require.NoError(t, err)
+	require.Equal(t, []string{`{"b":2}`, `{"b":2}`}, client.bodies)
+	require.Equal(t, 2, factoryCalls)
+
+	request, err = http.NewRequestWithContext(context.Background(), "POST", "http://model/predict", io.NopCloser(strings.NewReader(`{"c":3}`)))
+	require.NoError(t, err)
+	client = &bodyRecordingClient{}
+	_, err = DoRequest(client, request, policy)
+	require.ErrorIs(t, err, ErrBodyNotReplayable)
+	require.Equal(t, []string{`{"c":3}`}, client.bodies)
+}
@@ -0,0 +1,64 @@
+package http
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"regexp"
+	"unicode/utf8"
+)
+
+// maxBodySnippet the length of the response body excerpt included in decoding errors
+const maxBodySnippet = 120
+
+var htmlTitle = regexp.MustCompile(`(?is)<title[^>]*>(.*?)</title>`)
+
+// DescribeJSONError explains why body could not be decoded as json, e.g. because it is an html error
+// page of a proxy or a truncated response. The returned error wraps err. Only the beginning of markup
+// bodies is quoted, json bodies may hold secrets such as tokens
+func DescribeJSONError(body []byte, err error) error {
+	trimmed := bytes.TrimSpace(body)
+
+	if len(trimmed) == 0 {
+		return fmt.Errorf("response body is empty: %w", err)
+	}
+
+	if trimmed[0] == '<' {
+		if title := htmlTitle.FindSubmatch(trimmed); title != nil {
+			return fmt.Errorf("response is an html page titled '%s', not json: %w", bytes.TrimSpace(title[1]), err)
+		}
+		return fmt.Errorf("response is markup, not json: '%s': %w", snippet(trimmed), err)
+	}
+
+	var syntaxErr *json.SyntaxError
+	var typeErr *json.UnmarshalTypeError
+	switch {
+	case errors.Is(err, io.ErrUnexpectedEOF) || (err != nil && err.Error() == "unexpected end of JSON input"):
+		return fmt.Errorf("response body is truncated after %d bytes: %w", len(body), err)
+	case errors.As(err, &syntaxErr):
+		return fmt.Errorf("response is not valid json at offset %d: %w", syntaxErr.Offset, err)
+	case errors.As(err, &typeErr):
+		field := ""
+		if typeErr.Field != "" {
+			field = fmt.Sprintf(" in field '%s'", typeErr.Field)
+		}
+		return fmt.Errorf("response has a json %s where %s is expected%s: %w", typeErr.Value, typeErr.Type, field, err)
+	default:
+		return fmt.Errorf("response is malformed: %w", err)
+	}
+}
+
+// snippet returns the beginning of body, cut on a rune boundary
+func snippet(body []byte) string {
+	if len(body) <= maxBodySnippet {
+		return string(body)
+	}
+
+	cut := maxBodySnippet
+	for cut > 0 && !utf8.RuneStart(body[cut]) {
+		cut--
+	}
+	return string(body[:cut]) + "..."
+}
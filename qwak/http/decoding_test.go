@@ -0,0 +1,26 @@
+package http
+
+import (
+	"encoding/json"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestDescribeJSONError(t *testing.T) {
+	describe := func(body string) string {
+		var rows []map[string]interface{}
+		err := json.Unmarshal([]byte(body), &rows)
+		require.Error(t, err)
+		described := DescribeJSONError([]byte(body), err)
+		require.ErrorIs(t, described, err)
+		return described.Error()
+	}
+
+	require.Contains(t, describe("<html><head><title>502 Bad Gateway</title></head></html>"),
+		"response is an html page titled '502 Bad Gateway', not json")
+	require.Contains(t, describe(`[{"churn": 1}, {"chu`), "response body is truncated after 20 bytes")
+	require.Contains(t, describe(`{"churn": 1}`), "response has a json object where []map[string]interface {} is expected")
+	require.Contains(t, describe(`[{"churn": 1}] trailing`), "response is not valid json at offset")
+	require.Contains(t, describe("  "), "response body is empty")
+}
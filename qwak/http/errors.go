@@ -0,0 +1,104 @@
+package http
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+)
+
+// AttemptFailure describes a single failed attempt performed by DoRequestWithRetry
+type AttemptFailure struct {
+	// Attempt the zero based attempt number
+	Attempt int
+	// StatusCode the http status code returned by the attempt, 0 if no response was received
+	StatusCode int
+	// Err the error of the attempt
+	Err error
+	// Backoff the duration waited after the attempt before the next one, 0 if there was no retry
+	Backoff time.Duration
+	// Discarded is true when the attempt was not performed because the request context was done,
+	// or the request body could not be rebuilt
+	Discarded bool
+	// Class the class of the failure, deciding how it was retried
+	Class FailureClass
+}
+
+func (a AttemptFailure) String() string {
+	if a.Discarded {
+		return fmt.Sprintf("Attempt #%d discarded: %v", a.Attempt, a.Err)
+	}
+	return fmt.Sprintf("Attempt #%d: %v", a.Attempt, a.Err)
+}
+
+// RetryError is returned by DoRequestWithRetry when all attempts has been failed.
+// It holds the history of the attempts and unwraps to the last attempt error,
+// so errors.Is and errors.As can be used on the underlying causes
+type RetryError struct {
+	// Attempts the failed attempts, in the order they were performed
+	Attempts []AttemptFailure
+	// Cause is set when retrying stopped before the policy was exhausted, e.g. ErrDeadlineBudgetExhausted
+	Cause error
+}
+
+func (e *RetryError) Error() string {
+	attempts := make([]string, len(e.Attempts))
+	for idx, attempt := range e.Attempts {
+		attempts[idx] = attempt.String()
+	}
+
+	message := fmt.Sprintf("all attemptes has been failed:[%s]", strings.Join(attempts, ";"))
+	if e.Cause != nil {
+		return fmt.Sprintf("%v. %s", e.Cause, message)
+	}
+	return message
+}
+
+// Unwrap returns the error of the last attempt. The errors of the previous attempts are in Attempts.
+// A single error is unwrapped, as errors.Is and errors.As follow multiple errors from go 1.20 only
+func (e *RetryError) Unwrap() error {
+	if len(e.Attempts) == 0 {
+		return nil
+	}
+	return e.Attempts[len(e.Attempts)-1].Err
+}
+
+// Is reports whether the stop cause is target, e.g. errors.Is(err, http.ErrDeadlineBudgetExhausted),
+// or whether the last performed attempt failed with the class of target, one of ErrThrottled,
+// ErrUnavailable and ErrTimeout, e.g. errors.Is(err, http.ErrThrottled)
+func (e *RetryError) Is(target error) bool {
+	if e.Cause != nil && errors.Is(e.Cause, target) {
+		return true
+	}
+
+	for idx := len(e.Attempts) - 1; idx >= 0; idx-- {
+		if e.Attempts[idx].Discarded {
+			continue
+		}
+		switch e.Attempts[idx].Class {
+		case FailureThrottled:
+			return target == ErrThrottled
+		case FailureUnavailable:
+			return target == ErrUnavailable
+		case FailureTimeout:
+			return target == ErrTimeout
+		}
+		return false
+	}
+	return false
+}
+
+// As finds the first error in the stop cause chain matching target
+func (e *RetryError) As(target interface{}) bool {
+	return e.Cause != nil && errors.As(e.Cause, target)
+}
+
+// LastStatusCode returns the status code of the last attempt which received a response
+func (e *RetryError) LastStatusCode() int {
+	for idx := len(e.Attempts) - 1; idx >= 0; idx-- {
+		if e.Attempts[idx].StatusCode != 0 {
+			return e.Attempts[idx].StatusCode
+		}
+	}
+	return 0
+}
@@ -0,0 +1,93 @@
+package http
+
+import (
+	"context"
+	"errors"
+	"net"
+	"net/http"
+	"time"
+)
+
+// FailureClass classifies failed attempts, which are retried according to the RetryClassPolicy of their class
+type FailureClass int
+
+const (
+	// FailureOther connection errors and server errors other than 503 and 504
+	FailureOther FailureClass = iota
+	// FailureThrottled 429 responses, the model deployment rejects requests above its rate limit
+	FailureThrottled
+	// FailureUnavailable 503 responses, the model deployment is down or not ready
+	FailureUnavailable
+	// FailureTimeout 408 and 504 responses, and attempts timing out
+	FailureTimeout
+)
+
+func (c FailureClass) String() string {
+	switch c {
+	case FailureThrottled:
+		return "throttled"
+	case FailureUnavailable:
+		return "unavailable"
+	case FailureTimeout:
+		return "timeout"
+	default:
+		return "other"
+	}
+}
+
+var (
+	// ErrThrottled matches a RetryError whose last attempt was throttled
+	ErrThrottled = errors.New("request was throttled")
+	// ErrUnavailable matches a RetryError whose last attempt found the service unavailable
+	ErrUnavailable = errors.New("service is unavailable")
+	// ErrTimeout matches a RetryError whose last attempt timed out
+	ErrTimeout = errors.New("request timed out")
+)
+
+// RetryClassPolicy overrides how failures of a class are retried. The zero value retries them
+// as the other failures
+type RetryClassPolicy struct {
+	// Disabled fails on the first failure of the class
+	Disabled bool
+	// MaxAttempts stops retrying once this number of attempts failed with the class, the policy
+	// MaxAttempts applies when 0
+	MaxAttempts int
+	// MinBackoff the shortest wait before retrying a failure of the class, e.g. to back off longer
+	// from a throttling model. A Retry-After header takes precedence
+	MinBackoff time.Duration
+	// NoBackoff retries a failure of the class immediately, e.g. an attempt which already waited for
+	// its timeout. A Retry-After header takes precedence
+	NoBackoff bool
+}
+
+// classifyFailure returns the class of a failed attempt from its status code and error
+func classifyFailure(statusCode int, err error) FailureClass {
+	switch statusCode {
+	case http.StatusTooManyRequests:
+		return FailureThrottled
+	case http.StatusServiceUnavailable:
+		return FailureUnavailable
+	case http.StatusRequestTimeout, http.StatusGatewayTimeout:
+		return FailureTimeout
+	}
+
+	var netErr net.Error
+	if statusCode == 0 && (errors.Is(err, context.DeadlineExceeded) || errors.As(err, &netErr) && netErr.Timeout()) {
+		return FailureTimeout
+	}
+	return FailureOther
+}
+
+// classPolicy returns the retry policy of a failure class
+func (r *RetryPolicy) classPolicy(class FailureClass) RetryClassPolicy {
+	switch class {
+	case FailureThrottled:
+		return r.Throttled
+	case FailureUnavailable:
+		return r.Unavailable
+	case FailureTimeout:
+		return r.Timeout
+	default:
+		return RetryClassPolicy{}
+	}
+}
@@ -0,0 +1,96 @@
+package http
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+)
+
+// statusSequenceClient responds with the status codes in order, then with 200
+type statusSequenceClient struct {
+	statuses []int
+	calls    int
+}
+
+func (c *statusSequenceClient) Do(*http.Request) (*http.Response, error) {
+	status := http.StatusOK
+	if c.calls < len(c.statuses) {
+		status = c.statuses[c.calls]
+	}
+	c.calls++
+	return &http.Response{StatusCode: status, Header: http.Header{}, Body: io.NopCloser(strings.NewReader("{}"))}, nil
+}
+
+// instantClock does not wait for backoffs
+type instantClock struct{}
+
+func (instantClock) Now() time.Time { return time.Now() }
+
+func (instantClock) After(time.Duration) <-chan time.Time {
+	fired := make(chan time.Time, 1)
+	fired <- time.Now()
+	return fired
+}
+
+func TestRetryByFailureClass(t *testing.T) {
+	request, err := http.NewRequestWithContext(context.Background(), "POST", "http://model/predict", nil)
+	require.NoError(t, err)
+
+	policy := RetryPolicy{
+		MaxAttempts: 5,
+		IntervalMs:  100,
+		Clock:       instantClock{},
+		Throttled:   RetryClassPolicy{MinBackoff: 2 * time.Second},
+		Unavailable: RetryClassPolicy{MaxAttempts: 2},
+		Timeout:     RetryClassPolicy{NoBackoff: true},
+	}
+
+	// 408 responses are retried, immediately
+	client := &statusSequenceClient{statuses: []int{408, 429}}
+	result, err := DoRequest(client, request, policy)
+	require.NoError(t, err)
+	require.Equal(t, 200, result.StatusCode)
+	require.Equal(t, 3, client.calls)
+
+	// unavailable responses stop after 2 attempts, and the error tells the class
+	client = &statusSequenceClient{statuses: []int{429, 503, 503, 503}}
+	_, err = DoRequest(client, request, policy)
+	require.Error(t, err)
+	require.Equal(t, 3, client.calls)
+	require.True(t, errors.Is(err, ErrUnavailable))
+	require.False(t, errors.Is(err, ErrThrottled))
+
+	var retryErr *RetryError
+	require.True(t, errors.As(err, &retryErr))
+	require.Equal(t, FailureThrottled, retryErr.Attempts[0].Class)
+	require.Equal(t, 2*time.Second, retryErr.Attempts[0].Backoff)
+
+	policy.Throttled.Disabled = true
+	client = &statusSequenceClient{statuses: []int{429}}
+	_, err = DoRequest(client, request, policy)
+	require.True(t, errors.Is(err, ErrThrottled))
+	require.Equal(t, 1, client.calls)
+}
+
+func TestRetryErrorUnwrap(t *testing.T) {
+	first := errors.New("first attempt")
+	last := context.DeadlineExceeded
+	err := fmt.Errorf("failed: %w", &RetryError{
+		Attempts: []AttemptFailure{{Attempt: 0, Err: first}, {Attempt: 1, Err: last}},
+		Cause:    ErrDeadlineBudgetExhausted,
+	})
+
+	require.True(t, errors.Is(err, context.DeadlineExceeded))
+	require.True(t, errors.Is(err, ErrDeadlineBudgetExhausted))
+	require.False(t, errors.Is(err, first), "previous attempts are read from Attempts")
+	var retryErr *RetryError
+	require.True(t, errors.As(err, &retryErr))
+	require.Equal(t, first, retryErr.Attempts[0].Err)
+}
@@ -0,0 +1,64 @@
+package http
+
+import (
+	"context"
+	"net/http"
+	"time"
+)
+
+// RequestEvent describes a step of a request performed by DoRequest
+type RequestEvent struct {
+	// Request the request of the attempt
+	Request *http.Request
+	// Attempt the zero based attempt number, the number of attempts performed in OnResponse events
+	Attempt int
+	// StatusCode the status code of the response, 0 if no response was received or before the attempt
+	StatusCode int
+	// Err the error of the attempt, or of the request in OnResponse events
+	Err error
+	// Backoff the duration waited before the next attempt, set in OnRetry events
+	Backoff time.Duration
+	// Duration the duration of the attempt, or of the whole request including backoffs in OnResponse events
+	Duration time.Duration
+}
+
+// RequestHooks are invoked synchronously by DoRequest, e.g. for custom logging and alerting.
+// Set them on the request context with WithRequestHooks. Nil hooks are skipped
+type RequestHooks struct {
+	// OnRequest is invoked before each attempt
+	OnRequest func(event RequestEvent)
+	// OnRetry is invoked when a failed attempt is retried, before waiting for the backoff
+	OnRetry func(event RequestEvent)
+	// OnResponse is invoked once with the final outcome of the request
+	OnResponse func(event RequestEvent)
+}
+
+type requestHooksKey struct{}
+
+// WithRequestHooks returns a context invoking hooks during the requests performed with it
+func WithRequestHooks(ctx context.Context, hooks RequestHooks) context.Context {
+	return context.WithValue(ctx, requestHooksKey{}, &hooks)
+}
+
+func requestHooksFrom(ctx context.Context) *RequestHooks {
+	hooks, _ := ctx.Value(requestHooksKey{}).(*RequestHooks)
+	return hooks
+}
+
+func (h *RequestHooks) onRequest(event RequestEvent) {
+	if h != nil && h.OnRequest != nil {
+		h.OnRequest(event)
+	}
+}
+
+func (h *RequestHooks) onRetry(event RequestEvent) {
+	if h != nil && h.OnRetry != nil {
+		h.OnRetry(event)
+	}
+}
+
+func (h *RequestHooks) onResponse(event RequestEvent) {
+	if h != nil && h.OnResponse != nil {
+		h.OnResponse(event)
+	}
+}
@@ -0,0 +1,78 @@
+package http
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+)
+
+// DefaultMaxResponseBytes the largest response body read by default, 100 MiB
+const DefaultMaxResponseBytes = 100 << 20
+
+// ErrResponseTooLarge is returned when a response body exceeds the limit set by LimitResponseSize.
+// Such responses are not retried
+var ErrResponseTooLarge = errors.New("response body is too large")
+
+// LimitResponseSize returns a client failing with ErrResponseTooLarge when a response body exceeds maxBytes,
+// so a misbehaving server can't exhaust the memory of the caller. The client is returned as is when
+// maxBytes is not positive
+func LimitResponseSize(client Client, maxBytes int64) Client {
+	if maxBytes <= 0 {
+		return client
+	}
+	return &limitedClient{client: client, maxBytes: maxBytes}
+}
+
+type limitedClient struct {
+	client   Client
+	maxBytes int64
+}
+
+func (c *limitedClient) Do(request *http.Request) (*http.Response, error) {
+	response, err := c.client.Do(request)
+	if err != nil {
+		return response, err
+	}
+
+	if response.ContentLength > c.maxBytes {
+		response.Body.Close()
+		return nil, fmt.Errorf("%w: %d bytes, the limit is %d bytes", ErrResponseTooLarge, response.ContentLength, c.maxBytes)
+	}
+
+	response.Body = &limitedBody{body: response.Body, remaining: c.maxBytes, maxBytes: c.maxBytes}
+	return response, nil
+}
+
+// limitedBody fails reads past maxBytes
+type limitedBody struct {
+	body      io.ReadCloser
+	remaining int64
+	maxBytes  int64
+}
+
+func (b *limitedBody) Read(p []byte) (int, error) {
+	if b.remaining < 0 {
+		return 0, b.tooLarge()
+	}
+
+	// reading one byte past the limit tells a body of exactly maxBytes from a larger one
+	if int64(len(p)) > b.remaining+1 {
+		p = p[:b.remaining+1]
+	}
+
+	n, err := b.body.Read(p)
+	b.remaining -= int64(n)
+	if b.remaining < 0 {
+		return n + int(b.remaining), b.tooLarge()
+	}
+	return n, err
+}
+
+func (b *limitedBody) tooLarge() error {
+	return fmt.Errorf("%w: the limit is %d bytes", ErrResponseTooLarge, b.maxBytes)
+}
+
+func (b *limitedBody) Close() error {
+	return b.body.Close()
+}
@@ -0,0 +1,48 @@
+package http
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync/atomic"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestLimitResponseSize(t *testing.T) {
+	var calls int32
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		atomic.AddInt32(&calls, 1)
+		body := strings.Repeat("x", 10)
+		if r.URL.Path == "/large" {
+			body = strings.Repeat("x", 11)
+		}
+		if r.URL.Query().Get("chunked") != "" {
+			w.(http.Flusher).Flush()
+		}
+		_, _ = w.Write([]byte(body))
+	}))
+	defer server.Close()
+
+	client := LimitResponseSize(server.Client(), 10)
+	policy := RetryPolicy{MaxAttempts: 3, IntervalMs: 1}
+
+	for _, query := range []string{"", "?chunked=1"} {
+		request, err := http.NewRequestWithContext(context.Background(), http.MethodGet, server.URL+"/exact"+query, nil)
+		require.NoError(t, err)
+		result, err := DoRequest(client, request, policy)
+		require.NoError(t, err)
+		require.Len(t, result.Body, 10)
+
+		atomic.StoreInt32(&calls, 0)
+		request, err = http.NewRequestWithContext(context.Background(), http.MethodGet, server.URL+"/large"+query, nil)
+		require.NoError(t, err)
+		_, err = DoRequest(client, request, policy)
+		require.ErrorIs(t, err, ErrResponseTooLarge)
+		require.Equal(t, int32(1), atomic.LoadInt32(&calls), "too large responses must not be retried")
+	}
+
+	require.Equal(t, Client(server.Client()), LimitResponseSize(server.Client(), 0))
+}
@@ -0,0 +1,125 @@
+package http
+
+import (
+	"fmt"
+	"net"
+	"net/http"
+	"net/url"
+	"strings"
+)
+
+// ProxyOptions configures the proxies of the http client created by the SDK,
+// instead of reading them from the HTTP_PROXY, HTTPS_PROXY and NO_PROXY environment variables
+type ProxyOptions struct {
+	// HTTPProxy the proxy url of http requests, no proxy when empty
+	HTTPProxy string
+	// HTTPSProxy the proxy url of https requests, no proxy when empty
+	HTTPSProxy string
+	// NoProxy destinations reached without proxy. An entry is a host name matching the host and its
+	// subdomains ("qwak.ai"), a subdomains suffix (".qwak.ai"), an ip address, a cidr range ("10.0.0.0/8"),
+	// any of the previous with a port ("qwak.ai:443"), or "*" to disable proxying
+	NoProxy []string
+	// Username and Password authenticate against the proxy, overriding credentials set in the proxy urls
+	Username string
+	Password string
+	// ProxyFunc selects the proxy of each request, overriding all the other options when set
+	ProxyFunc func(request *http.Request) (*url.URL, error)
+}
+
+// Validate checks the proxy urls can be parsed
+func (p *ProxyOptions) Validate() error {
+	if _, err := p.parseProxyUrl(p.HTTPProxy); err != nil {
+		return err
+	}
+	_, err := p.parseProxyUrl(p.HTTPSProxy)
+	return err
+}
+
+func (p *ProxyOptions) proxyFunc() func(request *http.Request) (*url.URL, error) {
+	if p.ProxyFunc != nil {
+		return p.ProxyFunc
+	}
+
+	return func(request *http.Request) (*url.URL, error) {
+		rawProxyUrl := p.HTTPProxy
+		if request.URL.Scheme == "https" {
+			rawProxyUrl = p.HTTPSProxy
+		}
+
+		if rawProxyUrl == "" || p.bypassProxy(request.URL) {
+			return nil, nil
+		}
+
+		return p.parseProxyUrl(rawProxyUrl)
+	}
+}
+
+func (p *ProxyOptions) parseProxyUrl(rawProxyUrl string) (*url.URL, error) {
+	if rawProxyUrl == "" {
+		return nil, nil
+	}
+
+	if !strings.Contains(rawProxyUrl, "://") {
+		rawProxyUrl = "http://" + rawProxyUrl
+	}
+
+	proxyUrl, err := url.Parse(rawProxyUrl)
+
+	if err != nil || proxyUrl.Host == "" {
+		return nil, fmt.Errorf("invalid proxy url '%s'", rawProxyUrl)
+	}
+
+	if p.Username != "" {
+		proxyUrl.User = url.UserPassword(p.Username, p.Password)
+	}
+
+	return proxyUrl, nil
+}
+
+func (p *ProxyOptions) bypassProxy(destination *url.URL) bool {
+	host := strings.ToLower(destination.Hostname())
+	port := destination.Port()
+	if port == "" {
+		port = "80"
+		if destination.Scheme == "https" {
+			port = "443"
+		}
+	}
+
+	for _, entry := range p.NoProxy {
+		entry = strings.ToLower(strings.TrimSpace(entry))
+
+		if entry == "*" {
+			return true
+		}
+
+		if _, network, err := net.ParseCIDR(entry); err == nil {
+			if ip := net.ParseIP(host); ip != nil && network.Contains(ip) {
+				return true
+			}
+			continue
+		}
+
+		entryHost, entryPort := entry, ""
+		if splitHost, splitPort, err := net.SplitHostPort(entry); err == nil {
+			entryHost, entryPort = splitHost, splitPort
+		}
+
+		if entryPort != "" && entryPort != port {
+			continue
+		}
+
+		if strings.HasPrefix(entryHost, ".") {
+			if strings.HasSuffix(host, entryHost) {
+				return true
+			}
+			continue
+		}
+
+		if host == entryHost || strings.HasSuffix(host, "."+entryHost) {
+			return true
+		}
+	}
+
+	return false
+}
@@ -0,0 +1,79 @@
+package http
+
+import (
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+)
+
+const (
+	RateLimitLimitHeader     = "X-RateLimit-Limit"
+	RateLimitRemainingHeader = "X-RateLimit-Remaining"
+	RateLimitResetHeader     = "X-RateLimit-Reset"
+	RetryAfterHeader         = "Retry-After"
+)
+
+// RateLimit is the rate limit advertised by a response, through the X-RateLimit-* headers or their
+// RateLimit-* standard draft counterparts, and the Retry-After header
+type RateLimit struct {
+	// Limit the number of requests allowed in the current window, -1 when not advertised
+	Limit int
+	// Remaining the number of requests left in the current window, -1 when not advertised
+	Remaining int
+	// Reset when the current window ends, zero when not advertised
+	Reset time.Time
+	// RetryAfter how long to wait before sending another request, 0 when not advertised
+	RetryAfter time.Duration
+}
+
+// resetEpochThreshold tells reset values holding unix timestamps from those holding a number of seconds
+const resetEpochThreshold = 1_000_000_000
+
+// ParseRateLimit parses the rate limit headers of a response received at now,
+// and returns false when the response has none
+func ParseRateLimit(header http.Header, now time.Time) (RateLimit, bool) {
+	rateLimit := RateLimit{Limit: -1, Remaining: -1}
+	found := false
+
+	if limit, ok := headerInt(header, RateLimitLimitHeader, "RateLimit-Limit"); ok {
+		rateLimit.Limit = limit
+		found = true
+	}
+
+	if remaining, ok := headerInt(header, RateLimitRemainingHeader, "RateLimit-Remaining"); ok {
+		rateLimit.Remaining = remaining
+		found = true
+	}
+
+	if reset, ok := headerInt(header, RateLimitResetHeader, "RateLimit-Reset"); ok {
+		if reset >= resetEpochThreshold {
+			rateLimit.Reset = time.Unix(int64(reset), 0)
+		} else {
+			rateLimit.Reset = now.Add(time.Duration(reset) * time.Second)
+		}
+		found = true
+	}
+
+	if retryAfter, ok := parseRetryAfter(header, now); ok {
+		rateLimit.RetryAfter = retryAfter
+		found = true
+	}
+
+	return rateLimit, found
+}
+
+// headerInt returns the first of the headers holding a non negative integer. Lists, as sent by some
+// gateways for several policies, are reduced to their first value
+func headerInt(header http.Header, names ...string) (int, bool) {
+	for _, name := range names {
+		value := strings.TrimSpace(header.Get(name))
+		if idx := strings.IndexAny(value, ",;"); idx >= 0 {
+			value = strings.TrimSpace(value[:idx])
+		}
+		if parsed, err := strconv.Atoi(value); err == nil && parsed >= 0 {
+			return parsed, true
+		}
+	}
+	return 0, false
+}
@@ -0,0 +1,35 @@
+package http
+
+import (
+	"net/http"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestParseRateLimit(t *testing.T) {
+	now := time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+
+	_, ok := ParseRateLimit(http.Header{}, now)
+	require.False(t, ok)
+
+	rateLimit, ok := ParseRateLimit(http.Header{
+		"X-Ratelimit-Limit":     {"100"},
+		"X-Ratelimit-Remaining": {"7"},
+		"X-Ratelimit-Reset":     {"30"},
+	}, now)
+	require.True(t, ok)
+	require.Equal(t, RateLimit{Limit: 100, Remaining: 7, Reset: now.Add(30 * time.Second)}, rateLimit)
+
+	rateLimit, ok = ParseRateLimit(http.Header{
+		"Ratelimit-Remaining": {"0, 10;w=1"},
+		"X-Ratelimit-Reset":   {"1704067260"},
+		"Retry-After":         {"5"},
+	}, now)
+	require.True(t, ok)
+	require.Equal(t, -1, rateLimit.Limit)
+	require.Equal(t, 0, rateLimit.Remaining)
+	require.True(t, rateLimit.Reset.Equal(now.Add(time.Minute)))
+	require.Equal(t, 5*time.Second, rateLimit.RetryAfter)
+}
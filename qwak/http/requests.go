@@ -3,6 +3,7 @@ package http
 import (
 	"bytes"
 	"context"
+	"crypto/rand"
 	"encoding/json"
 	"fmt"
 	"net/http"
@@ -12,6 +13,13 @@ const (
 	AuthRequestContentType    = "application/json"
 	BearerTokenTemplate    = "Bearer %s"
 	DefaultAuthEndpointUri = "https://grpc.qwak.ai/api/v1/authentication/qwak-api-key"
+	StreamingAcceptHeader  = "text/event-stream, application/x-ndjson"
+	IdempotencyKeyHeader   = "Idempotency-Key"
+	TagsHeader             = "X-Qwak-Tags"
+	BinaryFeaturesHeader   = "X-Qwak-Binary-Features"
+	VariationHeader        = "X-Qwak-Variation"
+	RequestIdHeader        = "X-Request-Id"
+	ServerTimingHeader     = "Server-Timing"
 )
 
 type AuthenticationBody struct {
@@ -41,9 +49,7 @@ func NewPandaOrientedDf(columns []string, index []int, data [][]interface{}) Pan
 }
 
 func getPostRequest(ctx context.Context, url string, requestBody []byte) (*http.Request, error) {
-	bodyBuffer := bytes.NewBuffer(requestBody)
-
-	request, err := http.NewRequestWithContext(ctx, "POST", url, bodyBuffer)
+	request, err := http.NewRequestWithContext(ctx, "POST", url, bytes.NewReader(requestBody))
 
 	if err != nil {
 		return nil, err
@@ -55,24 +61,78 @@ func getPostRequest(ctx context.Context, url string, requestBody []byte) (*http.
 }
 
 func GetAuthenticationRequest(ctx context.Context, apiKey string) (*http.Request, error) {
-	postBody, _ := json.Marshal(&AuthenticationBody{
+	return GetAuthenticationRequestWithUrl(ctx, DefaultAuthEndpointUri, apiKey)
+}
+
+func GetAuthenticationRequestWithUrl(ctx context.Context, url string, apiKey string) (*http.Request, error) {
+	postBody, _ := marshal(&AuthenticationBody{
 		ApiKey: apiKey,
 	})
 
-	return getPostRequest(ctx, DefaultAuthEndpointUri, postBody)
+	return getPostRequest(ctx, url, postBody)
 
 }
 
 func GetPredictionRequest(ctx context.Context, url string,  token string, dataFrame PandaOrientedDf) (*http.Request, error) {
-	postBody, _ := json.Marshal(dataFrame)
+	postBody, _ := marshal(dataFrame)
+	return GetPredictionRequestWithBody(ctx, url, token, postBody)
+}
+
+// GetPredictionRequestWithBody builds a prediction request from an already encoded data frame
+func GetPredictionRequestWithBody(ctx context.Context, url string, token string, postBody []byte) (*http.Request, error) {
 	request, err := getPostRequest(ctx, url, postBody)
 
 	if err != nil {
 		return nil, err
 	}
 
-	request.Header.Set("authorization", fmt.Sprintf(BearerTokenTemplate, token))
+	// an empty token is sent to models which do not require authentication
+	if token != "" {
+		request.Header.Set("authorization", fmt.Sprintf(BearerTokenTemplate, token))
+	}
 
 	return request, nil
 
 }
+
+// marshal encodes value as json using a pooled buffer
+func marshal(value interface{}) ([]byte, error) {
+	buf := getBuffer()
+	defer putBuffer(buf)
+
+	if err := json.NewEncoder(buf).Encode(value); err != nil {
+		return nil, err
+	}
+
+	// Encode terminates the value with a newline, json.Marshal does not
+	content := make([]byte, buf.Len()-1)
+	copy(content, buf.Bytes())
+	return content, nil
+}
+
+// GetStreamingPredictionRequest builds a prediction request accepting server-sent events or chunked responses
+func GetStreamingPredictionRequest(ctx context.Context, url string, token string, postBody []byte) (*http.Request, error) {
+	request, err := GetPredictionRequestWithBody(ctx, url, token, postBody)
+
+	if err != nil {
+		return nil, err
+	}
+
+	request.Header.Set("accept", StreamingAcceptHeader)
+
+	return request, nil
+}
+
+// NewIdempotencyKey generates a random version 4 uuid to use as an idempotency key
+func NewIdempotencyKey() (string, error) {
+	var key [16]byte
+
+	if _, err := rand.Read(key[:]); err != nil {
+		return "", err
+	}
+
+	key[6] = (key[6] & 0x0f) | 0x40
+	key[8] = (key[8] & 0x3f) | 0x80
+
+	return fmt.Sprintf("%x-%x-%x-%x-%x", key[0:4], key[4:6], key[6:8], key[8:10], key[10:16]), nil
+}
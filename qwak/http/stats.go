@@ -0,0 +1,159 @@
+package http
+
+import (
+	"context"
+	"crypto/tls"
+	"net/http/httptrace"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// ConnectionStats is a snapshot of the connection usage of a client
+type ConnectionStats struct {
+	// ConnectionsObtained the number of times a connection was obtained for a request
+	ConnectionsObtained int64
+	// NewConnections the number of connections established
+	NewConnections int64
+	// ReusedConnections the number of times an already established connection was reused
+	ReusedConnections int64
+	// InFlightRequests the number of requests currently being performed
+	InFlightRequests int64
+	// DNSLookups the number of dns lookups performed
+	DNSLookups int64
+	// DNSLookupTime the total time spent on dns lookups
+	DNSLookupTime time.Duration
+	// Connects the number of tcp connection attempts
+	Connects int64
+	// ConnectTime the total time spent establishing tcp connections
+	ConnectTime time.Duration
+	// TLSHandshakes the number of tls handshakes performed
+	TLSHandshakes int64
+	// TLSHandshakeTime the total time spent on tls handshakes
+	TLSHandshakeTime time.Duration
+}
+
+// ReuseRate returns the fraction of obtained connections which were reused
+func (s ConnectionStats) ReuseRate() float64 {
+	if s.ConnectionsObtained == 0 {
+		return 0
+	}
+	return float64(s.ReusedConnections) / float64(s.ConnectionsObtained)
+}
+
+// AverageDNSLookupTime returns the mean duration of a dns lookup
+func (s ConnectionStats) AverageDNSLookupTime() time.Duration {
+	return average(s.DNSLookupTime, s.DNSLookups)
+}
+
+// AverageConnectTime returns the mean duration of establishing a tcp connection
+func (s ConnectionStats) AverageConnectTime() time.Duration {
+	return average(s.ConnectTime, s.Connects)
+}
+
+// AverageTLSHandshakeTime returns the mean duration of a tls handshake
+func (s ConnectionStats) AverageTLSHandshakeTime() time.Duration {
+	return average(s.TLSHandshakeTime, s.TLSHandshakes)
+}
+
+func average(total time.Duration, count int64) time.Duration {
+	if count == 0 {
+		return 0
+	}
+	return total / time.Duration(count)
+}
+
+// ConnectionStatsCollector collects ConnectionStats of the requests performed with a traced context.
+// It is safe for concurrent use
+type ConnectionStatsCollector struct {
+	connectionsObtained int64
+	newConnections      int64
+	reusedConnections   int64
+	inFlightRequests    int64
+	dnsLookups          int64
+	dnsLookupTime       int64
+	connects            int64
+	connectTime         int64
+	tlsHandshakes       int64
+	tlsHandshakeTime    int64
+}
+
+// NewConnectionStatsCollector is a constructor for ConnectionStatsCollector
+func NewConnectionStatsCollector() *ConnectionStatsCollector {
+	return &ConnectionStatsCollector{}
+}
+
+// WithTrace returns a context collecting the connection events of requests performed with it
+func (c *ConnectionStatsCollector) WithTrace(ctx context.Context) context.Context {
+	var lock sync.Mutex
+	var dnsStart, tlsStart time.Time
+	connectStarts := map[string]time.Time{}
+
+	return httptrace.WithClientTrace(ctx, &httptrace.ClientTrace{
+		GotConn: func(info httptrace.GotConnInfo) {
+			atomic.AddInt64(&c.connectionsObtained, 1)
+			if info.Reused {
+				atomic.AddInt64(&c.reusedConnections, 1)
+			} else {
+				atomic.AddInt64(&c.newConnections, 1)
+			}
+		},
+		DNSStart: func(httptrace.DNSStartInfo) {
+			lock.Lock()
+			defer lock.Unlock()
+			dnsStart = time.Now()
+		},
+		DNSDone: func(httptrace.DNSDoneInfo) {
+			lock.Lock()
+			defer lock.Unlock()
+			atomic.AddInt64(&c.dnsLookups, 1)
+			atomic.AddInt64(&c.dnsLookupTime, int64(time.Since(dnsStart)))
+		},
+		ConnectStart: func(network, addr string) {
+			lock.Lock()
+			defer lock.Unlock()
+			connectStarts[network+addr] = time.Now()
+		},
+		ConnectDone: func(network, addr string, err error) {
+			lock.Lock()
+			defer lock.Unlock()
+			atomic.AddInt64(&c.connects, 1)
+			atomic.AddInt64(&c.connectTime, int64(time.Since(connectStarts[network+addr])))
+		},
+		TLSHandshakeStart: func() {
+			lock.Lock()
+			defer lock.Unlock()
+			tlsStart = time.Now()
+		},
+		TLSHandshakeDone: func(tls.ConnectionState, error) {
+			lock.Lock()
+			defer lock.Unlock()
+			atomic.AddInt64(&c.tlsHandshakes, 1)
+			atomic.AddInt64(&c.tlsHandshakeTime, int64(time.Since(tlsStart)))
+		},
+	})
+}
+
+// RequestStarted marks a request as in flight, call the returned function when it completes
+func (c *ConnectionStatsCollector) RequestStarted() (done func()) {
+	atomic.AddInt64(&c.inFlightRequests, 1)
+	return func() {
+		atomic.AddInt64(&c.inFlightRequests, -1)
+	}
+}
+
+// Snapshot returns the stats collected so far
+func (c *ConnectionStatsCollector) Snapshot() ConnectionStats {
+	return ConnectionStats{
+		ConnectionsObtained: atomic.LoadInt64(&c.connectionsObtained),
+		NewConnections:      atomic.LoadInt64(&c.newConnections),
+		ReusedConnections:   atomic.LoadInt64(&c.reusedConnections),
+		InFlightRequests:    atomic.LoadInt64(&c.inFlightRequests),
+		DNSLookups:          atomic.LoadInt64(&c.dnsLookups),
+		DNSLookupTime:       time.Duration(atomic.LoadInt64(&c.dnsLookupTime)),
+		Connects:            atomic.LoadInt64(&c.connects),
+		ConnectTime:         time.Duration(atomic.LoadInt64(&c.connectTime)),
+		TLSHandshakes:       atomic.LoadInt64(&c.tlsHandshakes),
+		TLSHandshakeTime:    time.Duration(atomic.LoadInt64(&c.tlsHandshakeTime)),
+	}
+}
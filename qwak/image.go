@@ -0,0 +1,204 @@
+package qwak
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"image"
+	"image/jpeg"
+	"image/png"
+	"io"
+	"os"
+	"sort"
+
+	// registers the gif decoder, png and jpeg are registered by their encoders imports
+	_ "image/gif"
+)
+
+// DefaultImageColumn is the input column of image models, used when no column is set
+const DefaultImageColumn = "image"
+
+// ImageFormat is the encoding of an image sent to a model
+type ImageFormat string
+
+const (
+	// OriginalImageFormat sends the image bytes as read. A resized image is re-encoded: as jpeg when it was
+	// a jpeg, as png otherwise
+	OriginalImageFormat ImageFormat = ""
+	PNGImageFormat      ImageFormat = "png"
+	JPEGImageFormat     ImageFormat = "jpeg"
+)
+
+// ImageOptions controls how an image is prepared for an image model. The zero value sends the image as is
+type ImageOptions struct {
+	// Column the input column of the model, default to DefaultImageColumn
+	Column string
+	// Width and Height resize the image, when only one of them is set the aspect ratio is kept
+	Width  int
+	Height int
+	// Format re-encodes the image, default to the original format
+	Format ImageFormat
+	// JPEGQuality the quality of jpeg images between 1 and 100, default to jpeg.DefaultQuality
+	JPEGQuality int
+}
+
+// ReadImage reads a png, jpeg or gif image, and resizes and re-encodes it according to options
+func ReadImage(reader io.Reader, options ImageOptions) ([]byte, error) {
+	raw, err := io.ReadAll(reader)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read image: %w", err)
+	}
+
+	if options.Width == 0 && options.Height == 0 && options.Format == OriginalImageFormat {
+		if _, _, err := image.DecodeConfig(bytes.NewReader(raw)); err != nil {
+			return nil, fmt.Errorf("failed to decode image: %w", err)
+		}
+		return raw, nil
+	}
+
+	decoded, format, err := image.Decode(bytes.NewReader(raw))
+	if err != nil {
+		return nil, fmt.Errorf("failed to decode image: %w", err)
+	}
+
+	if options.Width > 0 || options.Height > 0 {
+		decoded = resizeImage(decoded, options.Width, options.Height)
+		if options.Format == OriginalImageFormat && format != string(JPEGImageFormat) {
+			format = string(PNGImageFormat)
+		}
+	}
+	if options.Format != OriginalImageFormat {
+		format = string(options.Format)
+	}
+
+	var encoded bytes.Buffer
+	switch ImageFormat(format) {
+	case JPEGImageFormat:
+		quality := options.JPEGQuality
+		if quality == 0 {
+			quality = jpeg.DefaultQuality
+		}
+		err = jpeg.Encode(&encoded, decoded, &jpeg.Options{Quality: quality})
+	case PNGImageFormat:
+		err = png.Encode(&encoded, decoded)
+	default:
+		return nil, fmt.Errorf("unsupported image format '%s'", format)
+	}
+
+	if err != nil {
+		return nil, fmt.Errorf("failed to encode image: %w", err)
+	}
+	return encoded.Bytes(), nil
+}
+
+// ReadImageFile is ReadImage reading the image from a file
+func ReadImageFile(path string, options ImageOptions) ([]byte, error) {
+	file, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer file.Close()
+
+	return ReadImage(file, options)
+}
+
+// NewImagePredictionRequest returns a prediction request with a single feature vector holding the image
+// in the column set by options, prepared by ReadImage
+func NewImagePredictionRequest(modelId string, reader io.Reader, options ImageOptions) (*PredictionRequest, error) {
+	data, err := ReadImage(reader, options)
+	if err != nil {
+		return nil, err
+	}
+
+	column := options.Column
+	if column == "" {
+		column = DefaultImageColumn
+	}
+
+	return NewPredictionRequest(modelId).AddFeatureVector(NewFeatureVector().WithBytesFeature(column, data)), nil
+}
+
+// resizeImage scales the image with nearest neighbor sampling, keeping the aspect ratio when width or height is 0
+func resizeImage(source image.Image, width int, height int) image.Image {
+	bounds := source.Bounds()
+	if width == 0 {
+		width = bounds.Dx() * height / bounds.Dy()
+	}
+	if height == 0 {
+		height = bounds.Dy() * width / bounds.Dx()
+	}
+	if width < 1 {
+		width = 1
+	}
+	if height < 1 {
+		height = 1
+	}
+
+	resized := image.NewRGBA(image.Rect(0, 0, width, height))
+	for y := 0; y < height; y++ {
+		sourceY := bounds.Min.Y + y*bounds.Dy()/height
+		for x := 0; x < width; x++ {
+			sourceX := bounds.Min.X + x*bounds.Dx()/width
+			resized.Set(x, y, source.At(sourceX, sourceY))
+		}
+	}
+	return resized
+}
+
+// LabelScore is a label predicted by a classification model with its score
+type LabelScore struct {
+	Label string
+	Score float64
+}
+
+// GetValueAsLabelScores returning the labels and scores of a classification result, sorted by descending score.
+// The labels and scores are read from two array columns of the same length, or from an object mapping labels
+// to scores in scoresColumn when labelsColumn is empty
+func (pr *PredictionResult) GetValueAsLabelScores(labelsColumn string, scoresColumn string) ([]LabelScore, error) {
+	scoresValue, ok := pr.valuesMap[scoresColumn]
+	if !ok {
+		return nil, errors.New("column is not exists")
+	}
+
+	var labelScores []LabelScore
+	if labelsColumn == "" {
+		scoresByLabel, ok := scoresValue.(map[string]interface{})
+		if !ok {
+			return nil, errors.New("column value is not an object")
+		}
+		for label, value := range scoresByLabel {
+			score, ok := numberAsFloat(value)
+			if !ok {
+				return nil, fmt.Errorf("the score of label '%s' is not a number", label)
+			}
+			labelScores = append(labelScores, LabelScore{Label: label, Score: score})
+		}
+	} else {
+		labels, err := pr.GetValueAsArrayOfStrings(labelsColumn)
+		if err != nil {
+			return nil, err
+		}
+		scores, ok := scoresValue.([]interface{})
+		if !ok {
+			return nil, errors.New("column value is not an array")
+		}
+		if len(scores) != len(labels) {
+			return nil, fmt.Errorf("%d labels for %d scores", len(labels), len(scores))
+		}
+		for idx, value := range scores {
+			score, ok := numberAsFloat(value)
+			if !ok {
+				return nil, fmt.Errorf("the value of '%s' at index '%d' is not a number", scoresColumn, idx)
+			}
+			labelScores = append(labelScores, LabelScore{Label: labels[idx], Score: score})
+		}
+	}
+
+	sort.SliceStable(labelScores, func(i, j int) bool {
+		if labelScores[i].Score == labelScores[j].Score {
+			return labelScores[i].Label < labelScores[j].Label
+		}
+		return labelScores[i].Score > labelScores[j].Score
+	})
+	return labelScores, nil
+}
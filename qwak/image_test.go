@@ -0,0 +1,76 @@
+package qwak
+
+import (
+	"bytes"
+	"image"
+	"image/color"
+	"image/png"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func testImage(t *testing.T) []byte {
+	source := image.NewRGBA(image.Rect(0, 0, 4, 2))
+	source.Set(3, 1, color.RGBA{R: 255, A: 255})
+
+	var encoded bytes.Buffer
+	require.NoError(t, png.Encode(&encoded, source))
+	return encoded.Bytes()
+}
+
+func TestReadImage(t *testing.T) {
+	raw := testImage(t)
+
+	unchanged, err := ReadImage(bytes.NewReader(raw), ImageOptions{})
+	require.NoError(t, err)
+	require.Equal(t, raw, unchanged)
+
+	resized, err := ReadImage(bytes.NewReader(raw), ImageOptions{Width: 2})
+	require.NoError(t, err)
+	config, format, err := image.DecodeConfig(bytes.NewReader(resized))
+	require.NoError(t, err)
+	require.Equal(t, "png", format)
+	require.Equal(t, 2, config.Width)
+	require.Equal(t, 1, config.Height)
+
+	converted, err := ReadImage(bytes.NewReader(raw), ImageOptions{Format: JPEGImageFormat})
+	require.NoError(t, err)
+	_, format, err = image.DecodeConfig(bytes.NewReader(converted))
+	require.NoError(t, err)
+	require.Equal(t, "jpeg", format)
+
+	resizedJPEG, err := ReadImage(bytes.NewReader(converted), ImageOptions{Height: 1})
+	require.NoError(t, err)
+	config, format, err = image.DecodeConfig(bytes.NewReader(resizedJPEG))
+	require.NoError(t, err)
+	require.Equal(t, "jpeg", format, "resized jpeg images stay jpeg")
+	require.Equal(t, 2, config.Width)
+	require.Equal(t, 1, config.Height)
+
+	_, err = ReadImage(bytes.NewReader([]byte("not an image")), ImageOptions{})
+	require.Error(t, err)
+
+	request, err := NewImagePredictionRequest("vision", bytes.NewReader(raw), ImageOptions{Column: "pixels"})
+	require.NoError(t, err)
+	value, ok := request.GetFeatureVectors()[0].GetFeature("pixels")
+	require.True(t, ok)
+	require.Equal(t, bytesValue(raw), value)
+}
+
+func TestGetValueAsLabelScores(t *testing.T) {
+	response, err := responseFromRaw([]byte(`[{"labels":["cat","dog"],"scores":[0.2,0.7],"probabilities":{"cat":0.9,"dog":0.1}}]`))
+	require.NoError(t, err)
+	result := response.GetSinglePrediction()
+
+	labelScores, err := result.GetValueAsLabelScores("labels", "scores")
+	require.NoError(t, err)
+	require.Equal(t, []LabelScore{{"dog", 0.7}, {"cat", 0.2}}, labelScores)
+
+	labelScores, err = result.GetValueAsLabelScores("", "probabilities")
+	require.NoError(t, err)
+	require.Equal(t, []LabelScore{{"cat", 0.9}, {"dog", 0.1}}, labelScores)
+
+	_, err = result.GetValueAsLabelScores("labels", "probabilities")
+	require.Error(t, err)
+}
@@ -0,0 +1,199 @@
+package qwak
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"math"
+	gohttp "net/http"
+	"sync"
+	"time"
+
+	"github.com/qwak-ai/go-sdk/qwak/http"
+)
+
+// ErrConcurrencyLimitExceeded is returned when a prediction could not get an in-flight slot,
+// because the queue was full or the queue timeout elapsed
+var ErrConcurrencyLimitExceeded = errors.New("concurrency limit exceeded")
+
+// ConcurrencyLimit bounds the number of predictions in flight, protecting shared model deployments
+// from traffic spikes. Cached and coalesced predictions do not take a slot
+type ConcurrencyLimit struct {
+	// MaxInFlight the maximum number of predictions sent concurrently, not limited when 0
+	MaxInFlight int
+	// MaxQueued the maximum number of predictions waiting for a slot, not limited when 0.
+	// A negative value fails predictions immediately when no slot is free
+	MaxQueued int
+	// QueueTimeout how long a prediction waits for a slot, until its context is done when 0
+	QueueTimeout time.Duration
+	// Adaptive adjusts the number of predictions in flight to the observed latency and errors, MaxInFlight
+	// is ignored when set
+	Adaptive *AdaptiveConcurrency
+}
+
+// AdaptiveConcurrency adjusts the limit of predictions in flight with additive increase and multiplicative
+// decrease (AIMD). The limit grows by one per limit successful predictions, and shrinks by BackoffRatio on
+// overload signals: predictions slower than LatencyThreshold, timeouts, connection errors, 5xx and 429 responses
+type AdaptiveConcurrency struct {
+	// InitialLimit the limit before any prediction completed, default to 10
+	InitialLimit int
+	// MinLimit the lowest limit, default to 1
+	MinLimit int
+	// MaxLimit the highest limit, default to 200
+	MaxLimit int
+	// LatencyThreshold predictions slower than it are overload signals, only errors are when 0
+	LatencyThreshold time.Duration
+	// BackoffRatio multiplies the limit on overload signals, between 0 and 1, default to 0.9
+	BackoffRatio float64
+}
+
+// concurrencyLimiter is a semaphore of in-flight predictions, nil when there is no limit
+type concurrencyLimiter struct {
+	lock     sync.Mutex
+	inFlight int
+	limit    float64
+	waiters  []chan struct{}
+	options  ConcurrencyLimit
+	adaptive AdaptiveConcurrency
+}
+
+func newConcurrencyLimiter(options ConcurrencyLimit) *concurrencyLimiter {
+	if options.Adaptive != nil {
+		adaptive := *options.Adaptive
+		adaptive.InitialLimit = intOrDefault(adaptive.InitialLimit, 10)
+		adaptive.MinLimit = intOrDefault(adaptive.MinLimit, 1)
+		adaptive.MaxLimit = intOrDefault(adaptive.MaxLimit, 200)
+		if adaptive.BackoffRatio <= 0 || adaptive.BackoffRatio >= 1 {
+			adaptive.BackoffRatio = 0.9
+		}
+		return &concurrencyLimiter{limit: float64(adaptive.InitialLimit), options: options, adaptive: adaptive}
+	}
+
+	if options.MaxInFlight <= 0 {
+		return nil
+	}
+	return &concurrencyLimiter{limit: float64(options.MaxInFlight), options: options}
+}
+
+func intOrDefault(value int, defaultValue int) int {
+	if value <= 0 {
+		return defaultValue
+	}
+	return value
+}
+
+// currentLimit returns the number of predictions allowed in flight
+func (l *concurrencyLimiter) currentLimit() int {
+	l.lock.Lock()
+	defer l.lock.Unlock()
+	return int(l.limit)
+}
+
+// acquire waits for a free slot, to be returned with release
+func (l *concurrencyLimiter) acquire(ctx context.Context) error {
+	if l == nil {
+		return nil
+	}
+
+	l.lock.Lock()
+	if l.inFlight < int(l.limit) {
+		l.inFlight++
+		l.lock.Unlock()
+		return nil
+	}
+
+	if l.options.MaxQueued < 0 || (l.options.MaxQueued > 0 && len(l.waiters) >= l.options.MaxQueued) {
+		l.lock.Unlock()
+		return ErrConcurrencyLimitExceeded
+	}
+
+	granted := make(chan struct{})
+	l.waiters = append(l.waiters, granted)
+	l.lock.Unlock()
+
+	var timeout <-chan time.Time
+	if l.options.QueueTimeout > 0 {
+		timer := time.NewTimer(l.options.QueueTimeout)
+		defer timer.Stop()
+		timeout = timer.C
+	}
+
+	var err error
+	select {
+	case <-granted:
+		return nil
+	case <-timeout:
+		err = ErrConcurrencyLimitExceeded
+	case <-ctx.Done():
+		err = fmt.Errorf("waiting for a concurrency slot: %w", ctx.Err())
+	}
+
+	l.lock.Lock()
+	defer l.lock.Unlock()
+	for idx, waiter := range l.waiters {
+		if waiter == granted {
+			l.waiters = append(l.waiters[:idx], l.waiters[idx+1:]...)
+			return err
+		}
+	}
+
+	// the slot was granted while giving up, hand it to the next waiter
+	l.inFlight--
+	l.grantLocked()
+	return err
+}
+
+// release returns the slot of a prediction which took latency and failed with err, adapting the limit
+func (l *concurrencyLimiter) release(latency time.Duration, err error) {
+	if l == nil {
+		return
+	}
+
+	l.lock.Lock()
+	defer l.lock.Unlock()
+
+	l.inFlight--
+	if l.options.Adaptive != nil {
+		l.adaptLocked(latency, err)
+	}
+	l.grantLocked()
+}
+
+func (l *concurrencyLimiter) adaptLocked(latency time.Duration, err error) {
+	overloaded := isOverloadSignal(err) ||
+		(l.adaptive.LatencyThreshold > 0 && latency > l.adaptive.LatencyThreshold)
+
+	if overloaded {
+		l.limit = math.Max(float64(l.adaptive.MinLimit), l.limit*l.adaptive.BackoffRatio)
+	} else if err == nil {
+		l.limit = math.Min(float64(l.adaptive.MaxLimit), l.limit+1/l.limit)
+	}
+}
+
+// grantLocked hands the free slots to the waiters, in order
+func (l *concurrencyLimiter) grantLocked() {
+	for len(l.waiters) > 0 && l.inFlight < int(l.limit) {
+		l.inFlight++
+		close(l.waiters[0])
+		l.waiters = l.waiters[1:]
+	}
+}
+
+// isOverloadSignal reports whether a prediction error suggests the model deployment is overloaded
+func isOverloadSignal(err error) bool {
+	if err == nil || errors.Is(err, context.Canceled) {
+		return false
+	}
+
+	if errors.Is(err, context.DeadlineExceeded) {
+		return true
+	}
+
+	var retryErr *http.RetryError
+	if !errors.As(err, &retryErr) || len(retryErr.Attempts) == 0 {
+		return false
+	}
+
+	statusCode := retryErr.Attempts[len(retryErr.Attempts)-1].StatusCode
+	return statusCode == 0 || statusCode >= 500 || statusCode == gohttp.StatusTooManyRequests
+}
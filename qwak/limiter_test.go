@@ -0,0 +1,69 @@
+package qwak
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/qwak-ai/go-sdk/qwak/http"
+	"github.com/stretchr/testify/require"
+)
+
+func TestConcurrencyLimiterQueue(t *testing.T) {
+	require.Nil(t, newConcurrencyLimiter(ConcurrencyLimit{}))
+	require.NoError(t, (*concurrencyLimiter)(nil).acquire(context.Background()))
+
+	limiter := newConcurrencyLimiter(ConcurrencyLimit{MaxInFlight: 1, MaxQueued: 1, QueueTimeout: 50 * time.Millisecond})
+	require.NoError(t, limiter.acquire(context.Background()))
+
+	waited := make(chan error, 1)
+	go func() {
+		waited <- limiter.acquire(context.Background())
+	}()
+
+	require.Eventually(t, func() bool {
+		return limiter.acquire(context.Background()) == ErrConcurrencyLimitExceeded
+	}, time.Second, time.Millisecond)
+	require.ErrorIs(t, <-waited, ErrConcurrencyLimitExceeded)
+
+	limiter.release(0, nil)
+	require.NoError(t, limiter.acquire(context.Background()))
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	require.ErrorIs(t, limiter.acquire(ctx), context.Canceled)
+}
+
+func TestAdaptiveConcurrencyLimit(t *testing.T) {
+	limiter := newConcurrencyLimiter(ConcurrencyLimit{Adaptive: &AdaptiveConcurrency{
+		InitialLimit:     4,
+		MinLimit:         2,
+		MaxLimit:         5,
+		LatencyThreshold: 100 * time.Millisecond,
+		BackoffRatio:     0.5,
+	}})
+	require.Equal(t, 4, limiter.currentLimit())
+
+	unavailable := &http.RetryError{Attempts: []http.AttemptFailure{{StatusCode: 503, Err: errors.New("unavailable")}}}
+	require.NoError(t, limiter.acquire(context.Background()))
+	limiter.release(time.Millisecond, unavailable)
+	require.Equal(t, 2, limiter.currentLimit())
+
+	require.NoError(t, limiter.acquire(context.Background()))
+	limiter.release(time.Second, nil)
+	require.Equal(t, 2, limiter.currentLimit(), "the limit must not go under MinLimit")
+
+	for i := 0; i < 20; i++ {
+		require.NoError(t, limiter.acquire(context.Background()))
+		limiter.release(time.Millisecond, nil)
+	}
+	require.Equal(t, 5, limiter.currentLimit(), "the limit must not go over MaxLimit")
+
+	badRequest := &http.RetryError{Attempts: []http.AttemptFailure{{StatusCode: 400, Err: errors.New("bad request")}}}
+	require.NoError(t, limiter.acquire(context.Background()))
+	limiter.release(time.Millisecond, badRequest)
+	require.NoError(t, limiter.acquire(context.Background()))
+	limiter.release(time.Millisecond, context.Canceled)
+	require.Equal(t, 5, limiter.currentLimit(), "client errors are not overload signals")
+}
@@ -0,0 +1,78 @@
+package qwak
+
+import (
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/qwak-ai/go-sdk/qwak/http"
+)
+
+// PredictionMeta describes how a prediction was performed
+type PredictionMeta struct {
+	// ModelId the id of the model which served the prediction
+	ModelId string
+	// Latency the total duration of the prediction call, including authentication and retries
+	Latency time.Duration
+	// Attempts the number of http attempts performed, 0 when served from the cache
+	Attempts int
+	// StatusCode the status code of the model response
+	StatusCode int
+	// Cached whether the response was served from the client prediction cache
+	Cached bool
+	// Fallback whether the response was served by the client fallback handler after the prediction failed
+	Fallback bool
+	// RequestId the id the model deployment assigned to the request, from the X-Request-Id response header
+	RequestId string
+	// ServerTiming the metrics of the Server-Timing response header, e.g. the model inference duration
+	ServerTiming []ServerTimingMetric
+	// RateLimit the rate limit advertised by the model response headers, nil when it has none
+	RateLimit *http.RateLimit
+}
+
+// ServerTimingMetric is a metric of the Server-Timing response header
+type ServerTimingMetric struct {
+	Name        string
+	Duration    time.Duration
+	Description string
+}
+
+// Meta returns the metadata of the prediction call which returned the response
+func (pr *PredictionResponse) Meta() PredictionMeta {
+	return pr.meta
+}
+
+// parseServerTiming parses Server-Timing header values, e.g. `inference;dur=12.5, queue;desc="Queue";dur=3`,
+// skipping malformed metrics
+func parseServerTiming(values []string) []ServerTimingMetric {
+	var metrics []ServerTimingMetric
+	for _, value := range values {
+		for _, entry := range strings.Split(value, ",") {
+			params := strings.Split(entry, ";")
+			metric := ServerTimingMetric{Name: strings.TrimSpace(params[0])}
+			if metric.Name == "" {
+				continue
+			}
+
+			for _, param := range params[1:] {
+				key, paramValue, _ := strings.Cut(strings.TrimSpace(param), "=")
+				paramValue = strings.Trim(strings.TrimSpace(paramValue), `"`)
+				switch strings.ToLower(strings.TrimSpace(key)) {
+				case "dur":
+					if millis, err := strconv.ParseFloat(paramValue, 64); err == nil {
+						metric.Duration = time.Duration(millis * float64(time.Millisecond))
+					}
+				case "desc":
+					metric.Description = paramValue
+				}
+			}
+			metrics = append(metrics, metric)
+		}
+	}
+	return metrics
+}
+
+// PredictionHook is invoked synchronously after each successful prediction with the request, the response and
+// the call metadata. Hooks must not modify the request nor the response, and should hand slow work off
+// to another goroutine
+type PredictionHook func(request *PredictionRequest, response *PredictionResponse, meta PredictionMeta)
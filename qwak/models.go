@@ -0,0 +1,121 @@
+package qwak
+
+import (
+	"context"
+	"errors"
+	gohttp "net/http"
+	"sync"
+	"time"
+
+	"github.com/qwak-ai/go-sdk/qwak/http"
+)
+
+// ModelConfig overrides the client configuration for the predictions of a single model
+type ModelConfig struct {
+	// Timeout bounds each prediction of the model, including its retries. Not limited by default
+	Timeout time.Duration
+	// RetryPolicy how to retry the predictions of the model, default to the client RetryPolicy
+	RetryPolicy *http.RetryPolicy
+	// Headers set on every prediction request of the model
+	Headers map[string]string
+	// Variation requests a specific variation of the model deployment
+	Variation string
+	// ConcurrencyLimit bounds the predictions of the model in flight, in addition to the client limit
+	ConcurrencyLimit ConcurrencyLimit
+}
+
+// modelConfigs holds the per model configurations of a client
+type modelConfigs struct {
+	lock     sync.RWMutex
+	configs  map[string]ModelConfig
+	limiters map[string]*concurrencyLimiter
+}
+
+func (m *modelConfigs) get(modelId string) (ModelConfig, bool) {
+	m.lock.RLock()
+	defer m.lock.RUnlock()
+	config, ok := m.configs[modelId]
+	return config, ok
+}
+
+// ConfigureModel overrides the client configuration for the predictions of a model, replacing the overrides
+// previously set for it. It can be called while predictions are running
+func (c *RealTimeClient) ConfigureModel(modelId string, config ModelConfig) error {
+	if modelId == "" {
+		return errors.New("model id is missing")
+	}
+
+	if config.Timeout < 0 {
+		return errors.New("model timeout must not be negative")
+	}
+
+	c.models.lock.Lock()
+	defer c.models.lock.Unlock()
+	if c.models.configs == nil {
+		c.models.configs = map[string]ModelConfig{}
+		c.models.limiters = map[string]*concurrencyLimiter{}
+	}
+	c.models.configs[modelId] = config
+	c.models.limiters[modelId] = newConcurrencyLimiter(config.ConcurrencyLimit)
+	return nil
+}
+
+// acquireSlots waits for an in-flight slot of the client and of the model, returning a function releasing them
+// with the outcome of the prediction
+func (c *RealTimeClient) acquireSlots(ctx context.Context, modelId string) (func(err error), error) {
+	c.models.lock.RLock()
+	modelLimiter := c.models.limiters[modelId]
+	c.models.lock.RUnlock()
+
+	if err := c.limiter.acquire(ctx); err != nil {
+		return nil, err
+	}
+
+	if err := modelLimiter.acquire(ctx); err != nil {
+		c.limiter.release(0, nil)
+		return nil, err
+	}
+
+	start := time.Now()
+	return func(err error) {
+		latency := time.Since(start)
+		modelLimiter.release(latency, err)
+		c.limiter.release(latency, err)
+	}, nil
+}
+
+// withModelTimeout bounds ctx with the timeout configured for the model, if any
+func (c *RealTimeClient) withModelTimeout(ctx context.Context, modelId string) (context.Context, context.CancelFunc) {
+	if config, ok := c.models.get(modelId); ok && config.Timeout > 0 {
+		return context.WithTimeout(ctx, config.Timeout)
+	}
+	return ctx, func() {}
+}
+
+// retryPolicyFor returns the retry policy of the model predictions
+func (c *RealTimeClient) retryPolicyFor(modelId string) http.RetryPolicy {
+	policy := c.RetryPolicy
+	if config, ok := c.models.get(modelId); ok && config.RetryPolicy != nil {
+		policy = *config.RetryPolicy
+	}
+	if policy.Clock == nil {
+		policy.Clock = c.clock
+	}
+	return policy
+}
+
+// setModelHeaders sets the headers and the variation configured for the model
+func (c *RealTimeClient) setModelHeaders(request *gohttp.Request, modelId string) {
+	config, ok := c.models.get(modelId)
+	if !ok {
+		return
+	}
+
+	for header, value := range config.Headers {
+		request.Header.Set(header, value)
+	}
+
+	if config.Variation != "" {
+		request.Header.Set(http.VariationHeader, config.Variation)
+	}
+}
@@ -0,0 +1,167 @@
+package qwak
+
+import (
+	"fmt"
+	"reflect"
+	"strings"
+	"time"
+)
+
+// FeatureVectorFromProto returns a feature vector with a feature per set field of a message generated by
+// protoc-gen-go, named by the field name in the .proto file. nameOverrides renames fields, keyed by their
+// proto name, and an empty name skips the field. Enums are sent by name, nested messages as objects, and
+// google.protobuf Timestamp and Duration as times and durations, encoded as set in the FeatureEncoding.
+// Fields are read from the generated struct tags, so the SDK does not depend on the protobuf module
+func FeatureVectorFromProto(msg interface{}, nameOverrides map[string]string) (*FeatureVector, error) {
+	value := reflect.ValueOf(msg)
+	if value.Kind() != reflect.Ptr || value.IsNil() || value.Elem().Kind() != reflect.Struct {
+		return nil, fmt.Errorf("%T is not a generated protobuf message", msg)
+	}
+
+	fields, ok := protoFields(value.Elem())
+	if !ok {
+		return nil, fmt.Errorf("%T is not a generated protobuf message", msg)
+	}
+
+	vector := NewFeatureVector()
+	for _, field := range fields {
+		name := field.name
+		if override, ok := nameOverrides[name]; ok {
+			if override == "" {
+				continue
+			}
+			name = override
+		}
+		vector.WithFeature(name, protoValue(field.value))
+	}
+
+	return vector, nil
+}
+
+type protoField struct {
+	name  string
+	value reflect.Value
+}
+
+// protoFields returns the fields of a generated message struct, including the set field of each oneof,
+// and false when the struct has no protobuf fields
+func protoFields(message reflect.Value) ([]protoField, bool) {
+	messageType := message.Type()
+	var fields []protoField
+	isMessage := false
+
+	for idx := 0; idx < messageType.NumField(); idx++ {
+		structField := messageType.Field(idx)
+		if !structField.IsExported() {
+			continue
+		}
+
+		if tag, ok := structField.Tag.Lookup("protobuf"); ok {
+			isMessage = true
+			fields = append(fields, protoField{name: protoFieldName(tag, structField.Name), value: message.Field(idx)})
+			continue
+		}
+
+		if _, ok := structField.Tag.Lookup("protobuf_oneof"); ok {
+			isMessage = true
+			// a oneof is an interface holding a pointer to a wrapper struct of the set field
+			oneof := message.Field(idx)
+			if oneof.IsNil() || oneof.Elem().Kind() != reflect.Ptr || oneof.Elem().IsNil() {
+				continue
+			}
+			wrapper := oneof.Elem().Elem()
+			if wrapper.Kind() != reflect.Struct || wrapper.NumField() != 1 {
+				continue
+			}
+			if tag, ok := wrapper.Type().Field(0).Tag.Lookup("protobuf"); ok {
+				fields = append(fields, protoField{name: protoFieldName(tag, wrapper.Type().Field(0).Name), value: wrapper.Field(0)})
+			}
+		}
+	}
+
+	return fields, isMessage
+}
+
+// protoFieldName returns the name option of a protobuf struct tag, e.g. `protobuf:"varint,1,opt,name=user_id,json=userId,proto3"`
+func protoFieldName(tag string, defaultName string) string {
+	for _, option := range strings.Split(tag, ",") {
+		if strings.HasPrefix(option, "name=") {
+			return strings.TrimPrefix(option, "name=")
+		}
+	}
+	return defaultName
+}
+
+type protoTimestamp interface {
+	AsTime() time.Time
+}
+
+type protoDuration interface {
+	AsDuration() time.Duration
+}
+
+// protoValue converts a field of a generated message to a feature value
+func protoValue(value reflect.Value) interface{} {
+	switch value.Kind() {
+	case reflect.Ptr, reflect.Interface:
+		if value.IsNil() {
+			return nil
+		}
+	}
+
+	switch v := value.Interface().(type) {
+	case protoTimestamp:
+		return v.AsTime()
+	case protoDuration:
+		return v.AsDuration()
+	case []byte:
+		return v
+	case fmt.Stringer:
+		// enums are generated as int32 types with a String method returning their name
+		if value.Kind() == reflect.Int32 {
+			return v.String()
+		}
+	}
+
+	switch value.Kind() {
+	case reflect.Ptr:
+		if value.Elem().Kind() != reflect.Struct {
+			// optional scalar fields of proto2 messages
+			return protoValue(value.Elem())
+		}
+		fields, ok := protoFields(value.Elem())
+		if !ok {
+			return value.Interface()
+		}
+		nested := make(map[string]interface{}, len(fields))
+		for _, field := range fields {
+			nested[field.name] = protoValue(field.value)
+		}
+		return nested
+	case reflect.Slice:
+		if value.IsNil() {
+			return nil
+		}
+		values := make([]interface{}, value.Len())
+		for idx := range values {
+			values[idx] = protoValue(value.Index(idx))
+		}
+		return values
+	case reflect.Map:
+		if value.IsNil() {
+			return nil
+		}
+		values := make(map[string]interface{}, value.Len())
+		iter := value.MapRange()
+		for iter.Next() {
+			key, ok := mapKey(iter.Key())
+			if !ok {
+				return value.Interface()
+			}
+			values[key] = protoValue(iter.Value())
+		}
+		return values
+	}
+
+	return value.Interface()
+}
@@ -0,0 +1,83 @@
+package qwak
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+)
+
+// the following types mirror the code protoc-gen-go generates
+
+type testTier int32
+
+func (t testTier) String() string {
+	return map[testTier]string{0: "TIER_UNSPECIFIED", 1: "TIER_GOLD"}[t]
+}
+
+type testTimestamp struct {
+	Seconds int64 `protobuf:"varint,1,opt,name=seconds,proto3"`
+}
+
+func (t *testTimestamp) AsTime() time.Time {
+	return time.Unix(t.Seconds, 0).UTC()
+}
+
+type testAddress struct {
+	City string `protobuf:"bytes,1,opt,name=city,proto3"`
+}
+
+type isTestUser_Contact interface {
+	isTestUser_Contact()
+}
+
+type testUser_Email struct {
+	Email string `protobuf:"bytes,7,opt,name=email,proto3,oneof"`
+}
+
+func (*testUser_Email) isTestUser_Contact() {}
+
+type testUser struct {
+	state         struct{}
+	UserId        int64              `protobuf:"varint,1,opt,name=user_id,json=userId,proto3"`
+	Tier          testTier           `protobuf:"varint,2,opt,name=tier,proto3,enum=test.Tier"`
+	Address       *testAddress       `protobuf:"bytes,3,opt,name=address,proto3"`
+	Tags          []string           `protobuf:"bytes,4,rep,name=tags,proto3"`
+	SignedUpAt    *testTimestamp     `protobuf:"bytes,5,opt,name=signed_up_at,json=signedUpAt,proto3"`
+	InternalNotes string             `protobuf:"bytes,6,opt,name=internal_notes,json=internalNotes,proto3"`
+	Contact       isTestUser_Contact `protobuf_oneof:"contact"`
+}
+
+func TestFeatureVectorFromProto(t *testing.T) {
+	user := &testUser{
+		UserId:        42,
+		Tier:          1,
+		Address:       &testAddress{City: "TLV"},
+		Tags:          []string{"a"},
+		SignedUpAt:    &testTimestamp{Seconds: 1700000000},
+		InternalNotes: "secret",
+		Contact:       &testUser_Email{Email: "ann@example.com"},
+	}
+
+	vector, err := FeatureVectorFromProto(user, map[string]string{"user_id": "id", "internal_notes": ""})
+	require.NoError(t, err)
+
+	expected := map[string]interface{}{
+		"id":           int64(42),
+		"tier":         "TIER_GOLD",
+		"address":      map[string]interface{}{"city": "TLV"},
+		"tags":         []interface{}{"a"},
+		"signed_up_at": time.Unix(1700000000, 0).UTC(),
+		"email":        "ann@example.com",
+	}
+	for name, value := range expected {
+		actual, ok := vector.GetFeature(name)
+		require.True(t, ok, name)
+		require.Equal(t, value, actual, name)
+	}
+	_, ok := vector.GetFeature("internal_notes")
+	require.False(t, ok)
+
+	_, err = FeatureVectorFromProto(&address{City: "TLV"}, nil)
+	require.Error(t, err)
+}
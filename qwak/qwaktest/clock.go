@@ -0,0 +1,59 @@
+package qwaktest
+
+import (
+	"sync"
+	"time"
+
+	qwakhttp "github.com/qwak-ai/go-sdk/qwak/http"
+)
+
+var _ qwakhttp.Clock = (*FakeClock)(nil)
+
+// FakeClock is an http.Clock whose time moves only when advanced. Waits complete immediately, advancing
+// the clock by the waited duration, so tests of token expiry and retry backoffs take no real time
+type FakeClock struct {
+	lock  sync.Mutex
+	now   time.Time
+	waits []time.Duration
+}
+
+// NewFakeClock returns a FakeClock set to now
+func NewFakeClock(now time.Time) *FakeClock {
+	return &FakeClock{now: now}
+}
+
+// Now returns the time of the clock
+func (c *FakeClock) Now() time.Time {
+	c.lock.Lock()
+	defer c.lock.Unlock()
+	return c.now
+}
+
+// After advances the clock by d, and returns a channel holding the new time
+func (c *FakeClock) After(d time.Duration) <-chan time.Time {
+	c.lock.Lock()
+	defer c.lock.Unlock()
+
+	c.waits = append(c.waits, d)
+	if d > 0 {
+		c.now = c.now.Add(d)
+	}
+
+	fired := make(chan time.Time, 1)
+	fired <- c.now
+	return fired
+}
+
+// Advance moves the clock forward by d
+func (c *FakeClock) Advance(d time.Duration) {
+	c.lock.Lock()
+	defer c.lock.Unlock()
+	c.now = c.now.Add(d)
+}
+
+// Waits returns the durations waited with After, in order, e.g. the backoffs between retries
+func (c *FakeClock) Waits() []time.Duration {
+	c.lock.Lock()
+	defer c.lock.Unlock()
+	return append([]time.Duration(nil), c.waits...)
+}
@@ -0,0 +1,3 @@
+// Package qwaktest provides test doubles for code depending on the qwak package,
+// so unit tests can run without mocking the http layer and the authentication flow
+package qwaktest
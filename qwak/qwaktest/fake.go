@@ -0,0 +1,160 @@
+package qwaktest
+
+import (
+	"context"
+	"fmt"
+	"sync"
+
+	"github.com/qwak-ai/go-sdk/qwak"
+)
+
+// Call is a prediction call recorded by FakeRealTimeClient
+type Call struct {
+	Ctx     context.Context
+	Request *qwak.PredictionRequest
+}
+
+// ResponderFunc computes a response for a prediction request
+type ResponderFunc func(ctx context.Context, request *qwak.PredictionRequest) (*qwak.PredictionResponse, error)
+
+type cannedResult struct {
+	response *qwak.PredictionResponse
+	err      error
+}
+
+// FakeRealTimeClient is an in-memory qwak.Predictor returning programmed responses and recording its calls.
+// Responses are looked up per model id in this order: queued responses (consumed once),
+// the model default response, and the responder function. It is safe for concurrent use.
+type FakeRealTimeClient struct {
+	lock      sync.Mutex
+	queued    map[string][]cannedResult
+	defaults  map[string]cannedResult
+	responder ResponderFunc
+	calls     []Call
+}
+
+var _ qwak.Predictor = (*FakeRealTimeClient)(nil)
+
+// NewFakeRealTimeClient is a constructor for FakeRealTimeClient with no programmed responses
+func NewFakeRealTimeClient() *FakeRealTimeClient {
+	return &FakeRealTimeClient{
+		queued:   map[string][]cannedResult{},
+		defaults: map[string]cannedResult{},
+	}
+}
+
+// RespondWith sets the response returned for every call to the model
+func (f *FakeRealTimeClient) RespondWith(modelId string, response *qwak.PredictionResponse) *FakeRealTimeClient {
+	f.lock.Lock()
+	defer f.lock.Unlock()
+	f.defaults[modelId] = cannedResult{response: response}
+	return f
+}
+
+// FailWith sets the error returned for every call to the model
+func (f *FakeRealTimeClient) FailWith(modelId string, err error) *FakeRealTimeClient {
+	f.lock.Lock()
+	defer f.lock.Unlock()
+	f.defaults[modelId] = cannedResult{err: err}
+	return f
+}
+
+// EnqueueResponse queues a response returned once for the next call to the model
+func (f *FakeRealTimeClient) EnqueueResponse(modelId string, response *qwak.PredictionResponse) *FakeRealTimeClient {
+	return f.enqueue(modelId, cannedResult{response: response})
+}
+
+// EnqueueError queues an error returned once for the next call to the model
+func (f *FakeRealTimeClient) EnqueueError(modelId string, err error) *FakeRealTimeClient {
+	return f.enqueue(modelId, cannedResult{err: err})
+}
+
+// RespondWithFunc sets a function computing the response of calls with no programmed response
+func (f *FakeRealTimeClient) RespondWithFunc(responder ResponderFunc) *FakeRealTimeClient {
+	f.lock.Lock()
+	defer f.lock.Unlock()
+	f.responder = responder
+	return f
+}
+
+// shared returns the programmed response as a shared view, so callers releasing it don't wipe the programmed one
+func (r cannedResult) shared() (*qwak.PredictionResponse, error) {
+	if r.response == nil {
+		return nil, r.err
+	}
+	return r.response.Filter(func(*qwak.PredictionResult) bool { return true }), r.err
+}
+
+func (f *FakeRealTimeClient) enqueue(modelId string, result cannedResult) *FakeRealTimeClient {
+	f.lock.Lock()
+	defer f.lock.Unlock()
+	f.queued[modelId] = append(f.queued[modelId], result)
+	return f
+}
+
+// Predict records the call and returns the programmed response
+func (f *FakeRealTimeClient) Predict(predictionRequest *qwak.PredictionRequest) (*qwak.PredictionResponse, error) {
+	return f.PredictWithCtx(context.Background(), predictionRequest)
+}
+
+// PredictWithCtx records the call and returns the programmed response
+func (f *FakeRealTimeClient) PredictWithCtx(ctx context.Context, predictionRequest *qwak.PredictionRequest) (*qwak.PredictionResponse, error) {
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+
+	modelId := predictionRequest.GetModelId()
+
+	f.lock.Lock()
+	f.calls = append(f.calls, Call{Ctx: ctx, Request: predictionRequest})
+
+	if queue := f.queued[modelId]; len(queue) > 0 {
+		f.queued[modelId] = queue[1:]
+		f.lock.Unlock()
+		return queue[0].shared()
+	}
+
+	if result, ok := f.defaults[modelId]; ok {
+		f.lock.Unlock()
+		return result.shared()
+	}
+
+	responder := f.responder
+	f.lock.Unlock()
+
+	if responder != nil {
+		return responder(ctx, predictionRequest)
+	}
+
+	return nil, fmt.Errorf("qwaktest: no response programmed for model '%s'", modelId)
+}
+
+// Calls returns all the recorded calls, in the order they were made
+func (f *FakeRealTimeClient) Calls() []Call {
+	f.lock.Lock()
+	defer f.lock.Unlock()
+	calls := make([]Call, len(f.calls))
+	copy(calls, f.calls)
+	return calls
+}
+
+// CallsForModel returns the recorded calls made to a model
+func (f *FakeRealTimeClient) CallsForModel(modelId string) []Call {
+	var calls []Call
+	for _, call := range f.Calls() {
+		if call.Request.GetModelId() == modelId {
+			calls = append(calls, call)
+		}
+	}
+	return calls
+}
+
+// Reset removes all programmed responses and recorded calls
+func (f *FakeRealTimeClient) Reset() {
+	f.lock.Lock()
+	defer f.lock.Unlock()
+	f.queued = map[string][]cannedResult{}
+	f.defaults = map[string]cannedResult{}
+	f.responder = nil
+	f.calls = nil
+}
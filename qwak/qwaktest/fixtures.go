@@ -0,0 +1,41 @@
+package qwaktest
+
+import (
+	"encoding/json"
+
+	"github.com/qwak-ai/go-sdk/qwak"
+)
+
+// NewPredictionResponse builds a PredictionResponse fixture with a result per row.
+// Rows go through the same json decoding as real model responses,
+// so numbers are read back by the result accessors exactly as in production.
+// The fixture is never released, it may be returned by several calls
+func NewPredictionResponse(rows ...map[string]interface{}) (*qwak.PredictionResponse, error) {
+	if rows == nil {
+		rows = []map[string]interface{}{}
+	}
+
+	raw, err := json.Marshal(rows)
+
+	if err != nil {
+		return nil, err
+	}
+
+	return qwak.ParsePredictionResponse(raw)
+}
+
+// MustPredictionResponse is like NewPredictionResponse but panics on error
+func MustPredictionResponse(rows ...map[string]interface{}) *qwak.PredictionResponse {
+	response, err := NewPredictionResponse(rows...)
+
+	if err != nil {
+		panic(err)
+	}
+
+	return response
+}
+
+// PredictionResponseFromJSON builds a PredictionResponse fixture from a raw model response body
+func PredictionResponseFromJSON(raw string) (*qwak.PredictionResponse, error) {
+	return qwak.ParsePredictionResponse([]byte(raw))
+}
@@ -0,0 +1,61 @@
+package qwaktest
+
+import (
+	"fmt"
+	"io"
+	"net/http"
+	"strings"
+	"time"
+
+	qwakhttp "github.com/qwak-ai/go-sdk/qwak/http"
+	"github.com/stretchr/testify/mock"
+)
+
+var _ qwakhttp.Client = (*HttpClientMock)(nil)
+
+// HttpClientMock is a testify mock of the http.Client interface of the SDK. Set it as the
+// RealTimeClientConfig.HttpClient, and program the responses of the requests with On("Do", ...)
+// or with the OnAuthenticate and OnPredict shortcuts. Each response can be returned once,
+// since its body is consumed
+type HttpClientMock struct {
+	mock.Mock
+}
+
+// Do returns the response programmed for the request
+func (m *HttpClientMock) Do(request *http.Request) (*http.Response, error) {
+	args := m.Mock.MethodCalled("Do", request)
+	response, _ := args.Get(0).(*http.Response)
+	return response, args.Error(1)
+}
+
+// OnAuthenticate answers the next authentication request to the default endpoint with a token expiring in expiresIn
+func (m *HttpClientMock) OnAuthenticate(token string, expiresIn time.Duration) *mock.Call {
+	return m.On("Do", RequestTo(qwakhttp.DefaultAuthEndpointUri)).
+		Return(NewHttpResponse(NewAuthResponse(token, expiresIn), http.StatusOK), nil).Once()
+}
+
+// OnPredict answers the next prediction request to url with body and statusCode
+func (m *HttpClientMock) OnPredict(url string, body string, statusCode int) *mock.Call {
+	return m.On("Do", RequestTo(url)).Return(NewHttpResponse(body, statusCode), nil).Once()
+}
+
+// RequestTo matches the requests sent to url
+func RequestTo(url string) interface{} {
+	return mock.MatchedBy(func(request *http.Request) bool {
+		return request.URL.String() == url
+	})
+}
+
+// NewHttpResponse returns a response with body and statusCode
+func NewHttpResponse(body string, statusCode int) *http.Response {
+	return &http.Response{
+		Body:       io.NopCloser(strings.NewReader(body)),
+		StatusCode: statusCode,
+		Header:     http.Header{},
+	}
+}
+
+// NewAuthResponse returns the body of an authentication response with a token expiring in expiresIn
+func NewAuthResponse(token string, expiresIn time.Duration) string {
+	return fmt.Sprintf(`{"accessToken":%q,"expiredAt":%d}`, token, time.Now().Add(expiresIn).Unix())
+}
@@ -0,0 +1,278 @@
+package qwaktest
+
+import (
+	"encoding/json"
+	"fmt"
+	"math/rand"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/qwak-ai/go-sdk/qwak"
+	qwakhttp "github.com/qwak-ai/go-sdk/qwak/http"
+)
+
+const (
+	// AuthEndpointPath the path of the authentication endpoint served by Server
+	AuthEndpointPath  = "/api/v1/authentication/qwak-api-key"
+	predictPathPrefix = "/v1/"
+	predictPathSuffix = "/predict"
+)
+
+// ModelFunc computes the output rows of a fake model from its input rows
+type ModelFunc func(rows []map[string]interface{}) ([]map[string]interface{}, error)
+
+// ServerOptions configures the behavior of Server
+type ServerOptions struct {
+	// ApiKey the accepted api key, any api key is accepted when empty
+	ApiKey string
+	// TokenTTL the lifetime of issued tokens, default to 24 hours
+	TokenTTL time.Duration
+	// AuthLatency the duration each authentication request takes
+	AuthLatency time.Duration
+	// AuthFailureRate the fraction [0, 1] of authentication requests answered with status code 503
+	AuthFailureRate float64
+	// PredictLatency the duration each prediction request takes
+	PredictLatency time.Duration
+	// PredictFailureRate the fraction [0, 1] of prediction requests answered with status code 503
+	PredictFailureRate float64
+	// Seed the seed of the failures random generator, making failures reproducible
+	Seed int64
+	// Clock tells the expiry of issued tokens, share a FakeClock with the client to test token renewals.
+	// Default to the system clock
+	Clock qwakhttp.Clock
+}
+
+// Server is a local fake of the Qwak authentication and prediction endpoints, built on httptest.
+// Point a RealTimeClient at it with ClientConfig to run integration tests without credentials or network access.
+type Server struct {
+	server  *httptest.Server
+	options ServerOptions
+
+	lock           sync.Mutex
+	random         *rand.Rand
+	models         map[string]ModelFunc
+	tokens         map[string]time.Time
+	authRequests   int
+	predictRequest map[string]int
+}
+
+// NewServer starts a fake Qwak server, call Close when done
+func NewServer(options ServerOptions) *Server {
+	if options.TokenTTL == 0 {
+		options.TokenTTL = 24 * time.Hour
+	}
+	if options.Clock == nil {
+		options.Clock = qwakhttp.SystemClock
+	}
+
+	s := &Server{
+		options:        options,
+		random:         rand.New(rand.NewSource(options.Seed)),
+		models:         map[string]ModelFunc{},
+		tokens:         map[string]time.Time{},
+		predictRequest: map[string]int{},
+	}
+
+	s.server = httptest.NewServer(http.HandlerFunc(s.handle))
+	return s
+}
+
+// URL returns the base url of the server, to be used as RealTimeClientConfig.Url
+func (s *Server) URL() string {
+	return s.server.URL
+}
+
+// AuthURL returns the url of the authentication endpoint, to be used as RealTimeClientConfig.AuthEndpointUrl
+func (s *Server) AuthURL() string {
+	return s.server.URL + AuthEndpointPath
+}
+
+// ClientConfig returns a RealTimeClientConfig targeting the server
+func (s *Server) ClientConfig() qwak.RealTimeClientConfig {
+	apiKey := s.options.ApiKey
+	if apiKey == "" {
+		apiKey = "qwaktest-api-key"
+	}
+
+	return qwak.RealTimeClientConfig{
+		ApiKey:          apiKey,
+		Url:             s.URL(),
+		AuthEndpointUrl: s.AuthURL(),
+		HttpClient:      s.server.Client(),
+	}
+}
+
+// Close shuts down the server
+func (s *Server) Close() {
+	s.server.Close()
+}
+
+// HandleModel serves a model computing its outputs with fn
+func (s *Server) HandleModel(modelId string, fn ModelFunc) *Server {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+	s.models[modelId] = fn
+	return s
+}
+
+// RespondWith serves a model answering every request with the given rows
+func (s *Server) RespondWith(modelId string, rows ...map[string]interface{}) *Server {
+	return s.HandleModel(modelId, func([]map[string]interface{}) ([]map[string]interface{}, error) {
+		return rows, nil
+	})
+}
+
+// AuthRequests returns the number of authentication requests received
+func (s *Server) AuthRequests() int {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+	return s.authRequests
+}
+
+// PredictRequests returns the number of prediction requests received for a model
+func (s *Server) PredictRequests(modelId string) int {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+	return s.predictRequest[modelId]
+}
+
+func (s *Server) handle(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	if r.URL.Path == AuthEndpointPath {
+		s.handleAuth(w, r)
+		return
+	}
+
+	if strings.HasPrefix(r.URL.Path, predictPathPrefix) && strings.HasSuffix(r.URL.Path, predictPathSuffix) {
+		modelId := strings.TrimSuffix(strings.TrimPrefix(r.URL.Path, predictPathPrefix), predictPathSuffix)
+		s.handlePredict(w, r, modelId)
+		return
+	}
+
+	http.NotFound(w, r)
+}
+
+func (s *Server) handleAuth(w http.ResponseWriter, r *http.Request) {
+	s.lock.Lock()
+	s.authRequests++
+	shouldFail := s.shouldFail(s.options.AuthFailureRate)
+	s.lock.Unlock()
+
+	if !s.sleep(r, s.options.AuthLatency) {
+		return
+	}
+
+	if shouldFail {
+		http.Error(w, "injected failure", http.StatusServiceUnavailable)
+		return
+	}
+
+	var body qwakhttp.AuthenticationBody
+	if err := json.NewDecoder(r.Body).Decode(&body); err != nil {
+		http.Error(w, "malformed authentication request", http.StatusBadRequest)
+		return
+	}
+
+	if s.options.ApiKey != "" && body.ApiKey != s.options.ApiKey {
+		http.Error(w, "wrong api key", http.StatusUnauthorized)
+		return
+	}
+
+	expiredAt := s.options.Clock.Now().Add(s.options.TokenTTL)
+
+	s.lock.Lock()
+	token := fmt.Sprintf("qwaktest-token-%d", len(s.tokens)+1)
+	s.tokens[token] = expiredAt
+	s.lock.Unlock()
+
+	writeJSON(w, map[string]interface{}{
+		"accessToken": token,
+		"expiredAt":   expiredAt.Unix(),
+	})
+}
+
+func (s *Server) handlePredict(w http.ResponseWriter, r *http.Request, modelId string) {
+	s.lock.Lock()
+	s.predictRequest[modelId]++
+	shouldFail := s.shouldFail(s.options.PredictFailureRate)
+	model, modelExists := s.models[modelId]
+	expiredAt, tokenExists := s.tokens[strings.TrimPrefix(r.Header.Get("authorization"), "Bearer ")]
+	s.lock.Unlock()
+
+	if !tokenExists || s.options.Clock.Now().After(expiredAt) {
+		http.Error(w, "unauthorized", http.StatusUnauthorized)
+		return
+	}
+
+	if !s.sleep(r, s.options.PredictLatency) {
+		return
+	}
+
+	if shouldFail {
+		http.Error(w, "injected failure", http.StatusServiceUnavailable)
+		return
+	}
+
+	if !modelExists {
+		http.Error(w, fmt.Sprintf("model '%s' is not deployed", modelId), http.StatusNotFound)
+		return
+	}
+
+	var df qwakhttp.PandaOrientedDf
+	if err := json.NewDecoder(r.Body).Decode(&df); err != nil {
+		http.Error(w, "malformed prediction request", http.StatusBadRequest)
+		return
+	}
+
+	rows := make([]map[string]interface{}, len(df.Data))
+	for rowIdx, values := range df.Data {
+		rows[rowIdx] = make(map[string]interface{}, len(df.Columns))
+		for columnIdx, column := range df.Columns {
+			if columnIdx < len(values) {
+				rows[rowIdx][column] = values[columnIdx]
+			}
+		}
+	}
+
+	outputs, err := model(rows)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	if outputs == nil {
+		outputs = []map[string]interface{}{}
+	}
+
+	writeJSON(w, outputs)
+}
+
+// shouldFail must be called with the lock held
+func (s *Server) shouldFail(rate float64) bool {
+	return rate > 0 && s.random.Float64() < rate
+}
+
+func (s *Server) sleep(r *http.Request, duration time.Duration) bool {
+	if duration <= 0 {
+		return true
+	}
+
+	select {
+	case <-r.Context().Done():
+		return false
+	case <-time.After(duration):
+		return true
+	}
+}
+
+func writeJSON(w http.ResponseWriter, value interface{}) {
+	w.Header().Set("content-type", "application/json")
+	_ = json.NewEncoder(w).Encode(value)
+}
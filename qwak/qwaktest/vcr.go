@@ -0,0 +1,284 @@
+package qwaktest
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"sync"
+	"time"
+
+	qwakhttp "github.com/qwak-ai/go-sdk/qwak/http"
+)
+
+// RedactedValue replaces secrets in recorded interactions
+const RedactedValue = "REDACTED"
+
+// replayedTokenTTL the lifetime of the tokens of replayed authentication responses
+const replayedTokenTTL = 24 * time.Hour
+
+// ErrNoRecordedInteraction is returned by Replayer when a request has no matching recorded interaction
+var ErrNoRecordedInteraction = errors.New("qwaktest: no recorded interaction matches the request")
+
+// Cassette is a set of recorded http interactions
+type Cassette struct {
+	Interactions []Interaction `json:"interactions"`
+}
+
+// Interaction is a recorded request and the response it received
+type Interaction struct {
+	Request  RecordedRequest  `json:"request"`
+	Response RecordedResponse `json:"response"`
+}
+
+// RecordedRequest is the recorded part of a request, secrets are redacted
+type RecordedRequest struct {
+	Method string `json:"method"`
+	URL    string `json:"url"`
+	Body   string `json:"body"`
+}
+
+// RecordedResponse is the recorded part of a response
+type RecordedResponse struct {
+	StatusCode  int    `json:"statusCode"`
+	ContentType string `json:"contentType,omitempty"`
+	Body        string `json:"body"`
+}
+
+// LoadCassette reads a cassette saved by Recorder.Save
+func LoadCassette(path string) (*Cassette, error) {
+	raw, err := ioutil.ReadFile(path)
+
+	if err != nil {
+		return nil, fmt.Errorf("qwaktest: failed to read cassette: %w", err)
+	}
+
+	cassette := &Cassette{}
+	if err := json.Unmarshal(raw, cassette); err != nil {
+		return nil, fmt.Errorf("qwaktest: failed to parse cassette: %w", err)
+	}
+
+	return cassette, nil
+}
+
+// Recorder is a qwakhttp.Client recording the interactions performed through the wrapped client.
+// API keys in authentication requests and access tokens in authentication responses are redacted,
+// and the authorization header is never recorded.
+type Recorder struct {
+	client qwakhttp.Client
+
+	lock     sync.Mutex
+	cassette Cassette
+}
+
+var _ qwakhttp.Client = (*Recorder)(nil)
+
+// NewRecorder is a constructor for a Recorder wrapping client, usually the default http client
+func NewRecorder(client qwakhttp.Client) *Recorder {
+	return &Recorder{client: client}
+}
+
+// Do performs the request with the wrapped client and records the interaction
+func (r *Recorder) Do(request *http.Request) (*http.Response, error) {
+	requestBody, err := readRequestBody(request)
+
+	if err != nil {
+		return nil, err
+	}
+
+	response, err := r.client.Do(request)
+
+	if err != nil {
+		return response, err
+	}
+
+	responseBody, err := ioutil.ReadAll(response.Body)
+	response.Body.Close()
+
+	if err != nil {
+		return nil, err
+	}
+
+	response.Body = ioutil.NopCloser(bytes.NewReader(responseBody))
+
+	r.lock.Lock()
+	defer r.lock.Unlock()
+	r.cassette.Interactions = append(r.cassette.Interactions, Interaction{
+		Request: RecordedRequest{
+			Method: request.Method,
+			URL:    request.URL.String(),
+			Body:   redactBody(requestBody),
+		},
+		Response: RecordedResponse{
+			StatusCode:  response.StatusCode,
+			ContentType: response.Header.Get("content-type"),
+			Body:        redactResponseBody(responseBody),
+		},
+	})
+
+	return response, nil
+}
+
+// Cassette returns a copy of the interactions recorded so far
+func (r *Recorder) Cassette() *Cassette {
+	r.lock.Lock()
+	defer r.lock.Unlock()
+	interactions := make([]Interaction, len(r.cassette.Interactions))
+	copy(interactions, r.cassette.Interactions)
+	return &Cassette{Interactions: interactions}
+}
+
+// Save writes the recorded interactions to a file, loadable with LoadCassette
+func (r *Recorder) Save(path string) error {
+	raw, err := json.MarshalIndent(r.Cassette(), "", "  ")
+
+	if err != nil {
+		return err
+	}
+
+	return ioutil.WriteFile(path, raw, 0644)
+}
+
+// Replayer is a qwakhttp.Client answering requests from a Cassette without network access.
+// Requests are matched by method, url and body. Identical requests are answered in the recorded order,
+// and the last matching interaction is replayed again once they are exhausted.
+// Replayed authentication responses expire in replayedTokenTTL, so the redacted token is not renewed on every call.
+type Replayer struct {
+	lock         sync.Mutex
+	interactions []Interaction
+	used         map[int]bool
+}
+
+var _ qwakhttp.Client = (*Replayer)(nil)
+
+// NewReplayer is a constructor for a Replayer of the cassette interactions
+func NewReplayer(cassette *Cassette) *Replayer {
+	return &Replayer{
+		interactions: cassette.Interactions,
+		used:         map[int]bool{},
+	}
+}
+
+// Do answers the request with the matching recorded response
+func (r *Replayer) Do(request *http.Request) (*http.Response, error) {
+	requestBody, err := readRequestBody(request)
+
+	if err != nil {
+		return nil, err
+	}
+
+	recorded := RecordedRequest{
+		Method: request.Method,
+		URL:    request.URL.String(),
+		Body:   redactBody(requestBody),
+	}
+
+	r.lock.Lock()
+	defer r.lock.Unlock()
+
+	lastMatch := -1
+	for idx, interaction := range r.interactions {
+		if interaction.Request != recorded {
+			continue
+		}
+		lastMatch = idx
+		if !r.used[idx] {
+			break
+		}
+	}
+
+	if lastMatch < 0 {
+		return nil, fmt.Errorf("%w: %s %s", ErrNoRecordedInteraction, recorded.Method, recorded.URL)
+	}
+
+	r.used[lastMatch] = true
+	recordedResponse := r.interactions[lastMatch].Response
+
+	header := http.Header{}
+	if recordedResponse.ContentType != "" {
+		header.Set("content-type", recordedResponse.ContentType)
+	}
+
+	return &http.Response{
+		StatusCode: recordedResponse.StatusCode,
+		Header:     header,
+		Body:       ioutil.NopCloser(bytes.NewReader(refreshTokenExpiry(recordedResponse.Body, time.Now()))),
+		Request:    request,
+	}, nil
+}
+
+func readRequestBody(request *http.Request) ([]byte, error) {
+	if request.Body == nil {
+		return nil, nil
+	}
+
+	if request.GetBody != nil {
+		body, err := request.GetBody()
+		if err != nil {
+			return nil, err
+		}
+		defer body.Close()
+		return ioutil.ReadAll(body)
+	}
+
+	raw, err := ioutil.ReadAll(request.Body)
+	request.Body.Close()
+
+	if err != nil {
+		return nil, err
+	}
+
+	request.Body = ioutil.NopCloser(bytes.NewReader(raw))
+	return raw, nil
+}
+
+func redactBody(body []byte) string {
+	var authBody qwakhttp.AuthenticationBody
+	decoder := json.NewDecoder(bytes.NewReader(body))
+	decoder.DisallowUnknownFields()
+
+	if err := decoder.Decode(&authBody); err == nil && authBody.ApiKey != "" {
+		authBody.ApiKey = RedactedValue
+		redacted, _ := json.Marshal(authBody)
+		return string(redacted)
+	}
+
+	return string(body)
+}
+
+// authResponseFields decodes a json object holding an access token, e.g. an authentication response
+func authResponseFields(body []byte) (map[string]json.RawMessage, bool) {
+	var fields map[string]json.RawMessage
+	if err := json.Unmarshal(body, &fields); err != nil {
+		return nil, false
+	}
+	_, ok := fields["accessToken"]
+	return fields, ok
+}
+
+func redactResponseBody(body []byte) string {
+	fields, ok := authResponseFields(body)
+	if !ok {
+		return string(body)
+	}
+
+	fields["accessToken"], _ = json.Marshal(RedactedValue)
+	redacted, _ := json.Marshal(fields)
+	return string(redacted)
+}
+
+func refreshTokenExpiry(body string, now time.Time) []byte {
+	fields, ok := authResponseFields([]byte(body))
+	if !ok {
+		return []byte(body)
+	}
+	if _, ok := fields["expiredAt"]; !ok {
+		return []byte(body)
+	}
+
+	fields["expiredAt"], _ = json.Marshal(now.Add(replayedTokenTTL).Unix())
+	refreshed, _ := json.Marshal(fields)
+	return refreshed
+}
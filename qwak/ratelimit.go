@@ -0,0 +1,85 @@
+package qwak
+
+import (
+	"context"
+	"fmt"
+	"sync"
+	"time"
+
+	"github.com/qwak-ai/go-sdk/qwak/http"
+)
+
+// rateThrottle delays predictions to stay under the rate limit advertised by the model responses,
+// nil when the client does not adapt to rate limits
+type rateThrottle struct {
+	lock         sync.Mutex
+	clock        http.Clock
+	blockedUntil time.Time
+	interval     time.Duration
+	next         time.Time
+}
+
+func newRateThrottle(enabled bool, clock http.Clock) *rateThrottle {
+	if !enabled {
+		return nil
+	}
+	return &rateThrottle{clock: clock}
+}
+
+// observe adapts the throttle to the rate limit of a response: requests are spread evenly over the rest
+// of the window, and held until its end when none is left or the server asked to retry later
+func (t *rateThrottle) observe(rateLimit http.RateLimit) {
+	if t == nil {
+		return
+	}
+
+	t.lock.Lock()
+	defer t.lock.Unlock()
+
+	now := t.clock.Now()
+	if rateLimit.RetryAfter > 0 {
+		t.blockedUntil = maxTime(t.blockedUntil, now.Add(rateLimit.RetryAfter))
+	}
+
+	if rateLimit.Remaining < 0 || !rateLimit.Reset.After(now) {
+		return
+	}
+	if rateLimit.Remaining == 0 {
+		t.blockedUntil = maxTime(t.blockedUntil, rateLimit.Reset)
+		t.interval = 0
+		return
+	}
+	t.interval = rateLimit.Reset.Sub(now) / time.Duration(rateLimit.Remaining)
+}
+
+// wait holds the caller until it may send a request, or until ctx is done
+func (t *rateThrottle) wait(ctx context.Context) error {
+	if t == nil {
+		return nil
+	}
+
+	t.lock.Lock()
+	now := t.clock.Now()
+	start := maxTime(now, maxTime(t.blockedUntil, t.next))
+	t.next = start.Add(t.interval)
+	t.lock.Unlock()
+
+	delay := start.Sub(now)
+	if delay <= 0 {
+		return nil
+	}
+
+	select {
+	case <-t.clock.After(delay):
+		return nil
+	case <-ctx.Done():
+		return fmt.Errorf("waiting for the model rate limit: %w", ctx.Err())
+	}
+}
+
+func maxTime(a time.Time, b time.Time) time.Time {
+	if a.After(b) {
+		return a
+	}
+	return b
+}
@@ -0,0 +1,60 @@
+package qwak
+
+import (
+	"context"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+
+	"github.com/qwak-ai/go-sdk/qwak/http"
+)
+
+// steppingClock advances its time by the durations waited for
+type steppingClock struct {
+	lock sync.Mutex
+	now  time.Time
+}
+
+func (c *steppingClock) Now() time.Time {
+	c.lock.Lock()
+	defer c.lock.Unlock()
+	return c.now
+}
+
+func (c *steppingClock) After(duration time.Duration) <-chan time.Time {
+	c.lock.Lock()
+	defer c.lock.Unlock()
+	c.now = c.now.Add(duration)
+	fired := make(chan time.Time, 1)
+	fired <- c.now
+	return fired
+}
+
+func TestRateThrottle(t *testing.T) {
+	start := time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+	clock := &steppingClock{now: start}
+	throttle := newRateThrottle(true, clock)
+
+	// 4 requests left for the next 2 seconds are spread every 500ms
+	throttle.observe(http.RateLimit{Limit: 10, Remaining: 4, Reset: start.Add(2 * time.Second)})
+	for idx := 0; idx < 3; idx++ {
+		require.NoError(t, throttle.wait(context.Background()))
+	}
+	require.Equal(t, start.Add(time.Second), clock.Now())
+
+	// no request left holds until the window resets
+	throttle.observe(http.RateLimit{Limit: 10, Remaining: 0, Reset: clock.Now().Add(10 * time.Second)})
+	require.NoError(t, throttle.wait(context.Background()))
+	require.Equal(t, start.Add(11*time.Second), clock.Now())
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	blocked := newRateThrottle(true, http.SystemClock)
+	blocked.observe(http.RateLimit{Limit: -1, Remaining: -1, RetryAfter: time.Minute})
+	require.Error(t, blocked.wait(ctx))
+
+	var disabled *rateThrottle
+	require.NoError(t, disabled.wait(context.Background()))
+}
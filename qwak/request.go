@@ -1,17 +1,30 @@
 package qwak
 
 import (
+	"bytes"
+	"encoding/base64"
 	"encoding/json"
 	"errors"
 	"fmt"
+	"math"
+	"math/big"
+	"sort"
+	"strconv"
+	"sync"
 
 	"github.com/qwak-ai/go-sdk/qwak/http"
 )
 
 // PredictionRequest represents a fluent API to build a prediction request on your model
 type PredictionRequest struct {
-	modelId        string
-	featuresVector []*FeatureVector
+	modelId         string
+	featuresVector  []*FeatureVector
+	idempotencyKey  string
+	expectedColumns []expectedColumn
+	tags            map[string]string
+	defaults        map[string]interface{}
+	template        *RequestTemplate
+	frozen          bool
 }
 
 // NewPredictionRequest is a constructor of PredictionRequest fluent API
@@ -21,17 +34,97 @@ func NewPredictionRequest(modelId string) *PredictionRequest {
 
 // AddFeatureVector adding a new feature vector to your prediction request using fluent API
 func (ir *PredictionRequest) AddFeatureVector(featureVector *FeatureVector) *PredictionRequest {
+	ir = ir.mutable()
 	ir.featuresVector = append(ir.featuresVector, featureVector)
 	return ir
 }
 
 // AddFeatureVectors adding many new feature vectors to your prediction request using fluent API
 func (ir *PredictionRequest) AddFeatureVectors(featuresVector ...*FeatureVector) *PredictionRequest {
+	ir = ir.mutable()
 	ir.featuresVector = append(ir.featuresVector, featuresVector...)
 	return ir
 }
 
+// WithIdempotencyKey sets the idempotency key sent with the request, and preserved across its retries,
+// so the server can identify retried requests. It overrides the key generated by the client
+func (ir *PredictionRequest) WithIdempotencyKey(key string) *PredictionRequest {
+	ir = ir.mutable()
+	ir.idempotencyKey = key
+	return ir
+}
+
+// WithDefaults sets default feature values, applied to every feature vector missing their column when the
+// request is encoded. Feature vectors are not modified. Calling it again merges the defaults
+func (ir *PredictionRequest) WithDefaults(defaults map[string]interface{}) *PredictionRequest {
+	ir = ir.mutable()
+	if ir.defaults == nil {
+		ir.defaults = make(map[string]interface{}, len(defaults))
+	}
+	for name, value := range defaults {
+		ir.defaults[name] = value
+	}
+	return ir
+}
+
+// withDefaultsApplied returns the request with the default values appended to the vectors missing them,
+// or the request itself when it has no defaults
+func (ir *PredictionRequest) withDefaultsApplied() *PredictionRequest {
+	if len(ir.defaults) == 0 {
+		return ir
+	}
+
+	names := make([]string, 0, len(ir.defaults))
+	for name := range ir.defaults {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	applied := *ir
+	applied.featuresVector = make([]*FeatureVector, len(ir.featuresVector))
+	for idx, vector := range ir.featuresVector {
+		applied.featuresVector[idx] = vector
+		if vector == nil {
+			continue
+		}
+
+		var withDefaults *FeatureVector
+		for _, name := range names {
+			if _, ok := vector.GetFeature(name); ok {
+				continue
+			}
+			if withDefaults == nil {
+				withDefaults = &FeatureVector{vectorId: vector.vectorId}
+				withDefaults.features = append(withDefaults.features, vector.features...)
+			}
+			withDefaults.features = append(withDefaults.features, &feature{name: name, value: ir.defaults[name]})
+		}
+		if withDefaults != nil {
+			applied.featuresVector[idx] = withDefaults
+		}
+	}
+	return &applied
+}
+
+// GetModelId returns the id of the model the request is targeting
+func (ir *PredictionRequest) GetModelId() string {
+	return ir.modelId
+}
+
+// GetFeatureVectors returns the feature vectors added to the request
+func (ir *PredictionRequest) GetFeatureVectors() []*FeatureVector {
+	return ir.featuresVector
+}
+
 func (ir *PredictionRequest) asPandaOrientedDf() http.PandaOrientedDf {
+	return ir.asPandaOrientedDfWith(defaultFeatureEncoding)
+}
+
+func (ir *PredictionRequest) asPandaOrientedDfWith(encoding *FeatureEncoding) http.PandaOrientedDf {
+	ir = encoding.prepare(ir)
+	if template := ir.layoutTemplate(); template != nil {
+		return ir.templateDataFrame(template, encoding)
+	}
 
 	index := make([]int, len(ir.featuresVector))
 	columnNextIdx := 0
@@ -54,7 +147,11 @@ func (ir *PredictionRequest) asPandaOrientedDf() http.PandaOrientedDf {
 		columnsData[idx] = make([]interface{}, len(columnsIdxByName))
 
 		for _, feature := range vector.features {
-			columnsData[idx][columnsIdxByName[feature.name]] = feature.value
+			value := feature.value
+			if encoded, ok := encoding.encodeValue(value); ok {
+				value = encoded
+			}
+			columnsData[idx][columnsIdxByName[feature.name]] = value
 		}
 	}
 
@@ -70,6 +167,12 @@ func (ir *PredictionRequest) asPandaOrientedDf() http.PandaOrientedDf {
 // PredictionResponse represents a response from your model to a prediction request
 type PredictionResponse struct {
 	predictions []*PredictionResult
+	rows        *[]map[string]interface{}
+	// shared responses, e.g. cached ones, may be returned to several callers and are never released
+	shared bool
+	// request the prediction request the results answer, nil for responses not returned by a client
+	request *PredictionRequest
+	meta    PredictionMeta
 }
 
 // GetPredictions is getting a results array from response
@@ -77,7 +180,8 @@ func (pr *PredictionResponse) GetPredictions() []*PredictionResult {
 	return pr.predictions
 }
 
-// GetSinglePrediction returns a single result from a prediction response
+// GetSinglePrediction returns a single result from a prediction response, nil when it has no results.
+// SinglePrediction returns an error instead
 func (pr *PredictionResponse) GetSinglePrediction() *PredictionResult {
 	if len(pr.predictions) > 0 {
 		return pr.predictions[0]
@@ -86,24 +190,199 @@ func (pr *PredictionResponse) GetSinglePrediction() *PredictionResult {
 	return nil
 }
 
+// ErrEmptyPrediction is returned when a prediction response has no results
+var ErrEmptyPrediction = errors.New("prediction response has no results")
+
+// SinglePrediction returns the first result of a prediction response, or ErrEmptyPrediction when it has none
+func (pr *PredictionResponse) SinglePrediction() (*PredictionResult, error) {
+	if len(pr.predictions) == 0 {
+		return nil, ErrEmptyPrediction
+	}
+
+	return pr.predictions[0], nil
+}
+
+// checkComplete returns an error when the response has no results, or when a result misses
+// a column the other results have
+func (pr *PredictionResponse) checkComplete() error {
+	if len(pr.predictions) == 0 {
+		return ErrEmptyPrediction
+	}
+
+	columns := map[string]bool{}
+	for _, prediction := range pr.predictions {
+		for column := range prediction.valuesMap {
+			columns[column] = true
+		}
+	}
+
+	for idx, prediction := range pr.predictions {
+		for column := range columns {
+			if _, ok := prediction.valuesMap[column]; !ok {
+				return fmt.Errorf("result at index %d is missing column '%s'", idx, column)
+			}
+		}
+	}
+
+	return nil
+}
+
+// GetPredictionForVector returns the result of the feature vector at index vectorIdx of the request.
+// An error is returned when the index is out of range, or when the number of results differs from the
+// number of feature vectors sent, since results can't be matched to vectors reliably then
+func (pr *PredictionResponse) GetPredictionForVector(vectorIdx int) (*PredictionResult, error) {
+	if pr.request != nil && len(pr.request.featuresVector) != len(pr.predictions) {
+		return nil, fmt.Errorf("model returned %d results for %d feature vectors", len(pr.predictions), len(pr.request.featuresVector))
+	}
+
+	if vectorIdx < 0 || vectorIdx >= len(pr.predictions) {
+		return nil, fmt.Errorf("no result for feature vector at index %d", vectorIdx)
+	}
+
+	return pr.predictions[vectorIdx], nil
+}
+
+// GetPredictionByVectorId returns the result of the feature vector with the id set by FeatureVector.WithVectorId
+func (pr *PredictionResponse) GetPredictionByVectorId(vectorId string) (*PredictionResult, error) {
+	if pr.request == nil {
+		return nil, errors.New("response is not attached to a prediction request")
+	}
+
+	for idx, vector := range pr.request.featuresVector {
+		if vector.vectorId == vectorId {
+			return pr.GetPredictionForVector(idx)
+		}
+	}
+
+	return nil, fmt.Errorf("no feature vector with id '%s' in request", vectorId)
+}
+
+// forRequest returns a view of the response attached to the request it answers.
+// The view shares the results, and is shared itself if the response is
+func (pr *PredictionResponse) forRequest(request *PredictionRequest) *PredictionResponse {
+	view := *pr
+	view.request = request
+	return &view
+}
+
+// ParsePredictionResponse parses a raw model response body, as returned by the model endpoint.
+// The response does not use pooled memory, so it may be kept and returned to several callers,
+// e.g. as a test fixture: Release has no effect on it
+func ParsePredictionResponse(raw []byte) (*PredictionResponse, error) {
+	var rows []map[string]interface{}
+	if err := defaultCodec.Unmarshal(raw, &rows); err != nil {
+		return nil, fmt.Errorf("qwak client failed to predict: %w", http.DescribeJSONError(raw, err))
+	}
+
+	response := newPredictionResponse(rows)
+	response.shared = true
+	return response, nil
+}
+
+// Release returns the memory of the response results to a pool, reducing GC pressure
+// on services doing many predictions per second. Calling Release is optional.
+// Neither the response nor any of its results or values may be used after calling it.
+// Releasing a response shared with other callers, such as a cached response, has no effect.
+func (pr *PredictionResponse) Release() {
+	if pr.shared {
+		return
+	}
+
+	rows := pr.rows
+	pr.predictions = nil
+	pr.rows = nil
+
+	if rows != nil {
+		releaseRows(rows)
+	}
+}
+
+func releaseRows(rows *[]map[string]interface{}) {
+	if len(*rows) > maxPooledRows {
+		return
+	}
+
+	for _, row := range *rows {
+		for column := range row {
+			delete(row, column)
+		}
+	}
+	*rows = (*rows)[:0]
+	rowsPool.Put(rows)
+}
+
+// maxPooledRows prevents huge responses from pinning their rows in the pool
+const maxPooledRows = 1024
+
+var rowsPool = sync.Pool{
+	New: func() interface{} {
+		return &[]map[string]interface{}{}
+	},
+}
+
 func responseFromRaw(results []byte) (*PredictionResponse, error) {
+	return responseFromRawWithCodec(results, defaultCodec)
+}
+
+func responseFromRawWithCodec(results []byte, codec Codec) (*PredictionResponse, error) {
+	if codec == nil {
+		codec = defaultCodec
+	}
 
-	var response []map[string]interface{}
-	err := json.Unmarshal(results, &response)
+	// rows released to the pool keep their emptied maps in the slice backing array,
+	// json.Unmarshal reuses them instead of allocating new maps
+	response := rowsPool.Get().(*[]map[string]interface{})
+	err := codec.Unmarshal(results, response)
 
 	if err != nil {
-		return nil, fmt.Errorf("qwak client failed to predict: %s", err.Error())
+		releaseRows(response)
+		return nil, fmt.Errorf("qwak client failed to predict: %w", http.DescribeJSONError(results, err))
 	}
 
-	predictionResponse := &PredictionResponse{}
+	predictionResponse := newPredictionResponse(*response)
+	predictionResponse.rows = response
+	return predictionResponse, nil
+}
 
-	for _, result := range response {
+// newPredictionResponse returns a response with a result per row
+func newPredictionResponse(rows []map[string]interface{}) *PredictionResponse {
+	predictionResponse := &PredictionResponse{
+		predictions: make([]*PredictionResult, 0, len(rows)),
+	}
+
+	for _, result := range rows {
 		predictionResponse.predictions = append(predictionResponse.predictions, &PredictionResult{
 			valuesMap: result,
 		})
 	}
 
-	return predictionResponse, nil
+	return predictionResponse
+}
+
+// checkArrayOfObjects returns an error when a decoded response body is not a json array of objects
+func checkArrayOfObjects(body []byte, response *PredictionResponse) error {
+	trimmed := bytes.TrimSpace(body)
+	if len(trimmed) == 0 {
+		return errors.New("response body is empty")
+	}
+
+	switch trimmed[0] {
+	case '[':
+	case '{':
+		return errors.New("response is a json object, not an array of objects")
+	case 'n':
+		return errors.New("response is json null, not an array of objects")
+	default:
+		return errors.New("response is a json scalar, not an array of objects")
+	}
+
+	for idx, prediction := range response.predictions {
+		if prediction.valuesMap == nil {
+			return fmt.Errorf("result at index %d is not a json object", idx)
+		}
+	}
+
+	return nil
 }
 
 // PredictionResult represents one result in a response for prediction request
@@ -120,7 +399,7 @@ func (pr *PredictionResult) GetValueAsInt(columnName string) (int, error) {
 		return 0, errors.New("column is not exists")
 	}
 
-	parsedValue, ok := value.(float64)
+	parsedValue, ok := numberAsFloat(value)
 
 	if !ok {
 		return 0, errors.New("column value is not a number")
@@ -129,6 +408,99 @@ func (pr *PredictionResult) GetValueAsInt(columnName string) (int, error) {
 	return int(parsedValue), nil
 }
 
+// GetValueAsInt64 returning the value of column in a result converted to int64.
+// Decode responses with EncodingJSONCodec.UseNumber for integers beyond 2^53 to keep their precision.
+// If the value is not an integer or if the column dose not exists, an error returned
+func (pr *PredictionResult) GetValueAsInt64(columnName string) (int64, error) {
+	value, ok := pr.valuesMap[columnName]
+
+	if !ok {
+		return 0, errors.New("column is not exists")
+	}
+
+	switch number := value.(type) {
+	case json.Number:
+		parsedValue, err := number.Int64()
+		if err != nil {
+			return 0, fmt.Errorf("column value is not an int64: %w", err)
+		}
+		return parsedValue, nil
+	case float64:
+		if number != math.Trunc(number) || number < math.MinInt64 || number >= math.MaxInt64 {
+			return 0, errors.New("column value is not an int64")
+		}
+		return int64(number), nil
+	default:
+		return 0, errors.New("column value is not a number")
+	}
+}
+
+// GetValueAsNumber returning the value of column in a result as a json.Number, holding its textual representation.
+// Decode responses with EncodingJSONCodec.UseNumber to get the number exactly as sent by the model.
+// If the value is not a number or if the column dose not exists, an error returned
+func (pr *PredictionResult) GetValueAsNumber(columnName string) (json.Number, error) {
+	value, ok := pr.valuesMap[columnName]
+
+	if !ok {
+		return "", errors.New("column is not exists")
+	}
+
+	switch number := value.(type) {
+	case json.Number:
+		return number, nil
+	case float64:
+		return json.Number(strconv.FormatFloat(number, 'g', -1, 64)), nil
+	default:
+		return "", errors.New("column value is not a number")
+	}
+}
+
+// GetValueAsDecimal returning the value of column in a result as an exact rational number, for pricing and
+// finance models where float64 rounding is unacceptable. Numbers and decimal strings are accepted.
+// Decode responses with EncodingJSONCodec.UseNumber to keep every digit of numbers sent by the model,
+// float64 values are converted from their shortest representation, e.g. 0.1 is 1/10.
+// If conversion failed or if the column dose not exists, an error returned
+func (pr *PredictionResult) GetValueAsDecimal(columnName string) (*big.Rat, error) {
+	value, ok := pr.valuesMap[columnName]
+
+	if !ok {
+		return nil, errors.New("column is not exists")
+	}
+
+	var text string
+	switch number := value.(type) {
+	case json.Number:
+		text = number.String()
+	case float64:
+		text = strconv.FormatFloat(number, 'g', -1, 64)
+	case string:
+		text = number
+	default:
+		return nil, errors.New("column value is not a decimal")
+	}
+
+	parsedValue, ok := new(big.Rat).SetString(text)
+
+	if !ok {
+		return nil, fmt.Errorf("column value '%s' is not a decimal", text)
+	}
+
+	return parsedValue, nil
+}
+
+// numberAsFloat converts a decoded json number, a float64 or a json.Number, to float64
+func numberAsFloat(value interface{}) (float64, bool) {
+	switch number := value.(type) {
+	case float64:
+		return number, true
+	case json.Number:
+		parsedValue, err := number.Float64()
+		return parsedValue, err == nil
+	default:
+		return 0, false
+	}
+}
+
 // GetValueAsFloat returning the value of column in a result converted to float.
 // If conversion failed or if the column dose not exists, an error returned
 func (pr *PredictionResult) GetValueAsFloat(columnName string) (float64, error) {
@@ -138,7 +510,7 @@ func (pr *PredictionResult) GetValueAsFloat(columnName string) (float64, error)
 		return 0, errors.New("column is not exists")
 	}
 
-	parsedValue, ok := value.(float64)
+	parsedValue, ok := numberAsFloat(value)
 
 	if !ok {
 		return 0, errors.New("column value is not a float")
@@ -195,6 +567,34 @@ func (pr *PredictionResult) GetValueAsArrayOfStrings(columnName string) ([]strin
 	return result, nil
 }
 
+// GetValueAsBytes returning the value of column in a result decoded from base64.
+// If decoding failed or if the column dose not exists, an error returned
+func (pr *PredictionResult) GetValueAsBytes(columnName string) ([]byte, error) {
+	value, ok := pr.valuesMap[columnName]
+
+	if !ok {
+		return nil, errors.New("column is not exists")
+	}
+
+	parsedValue, ok := value.(string)
+
+	if !ok {
+		return nil, errors.New("column value is not a base64 string")
+	}
+
+	decoded, err := base64.StdEncoding.DecodeString(parsedValue)
+
+	if err != nil {
+		decoded, err = base64.URLEncoding.DecodeString(parsedValue)
+	}
+
+	if err != nil {
+		return nil, fmt.Errorf("column value is not a base64 string: %w", err)
+	}
+
+	return decoded, nil
+}
+
 // GetValueAsInterface returning the value of column in a result without any conversion
 // If the column is missing, an error return
 func (pr *PredictionResult) GetValueAsInterface(columnName string) (interface{}, error) {
@@ -210,6 +610,10 @@ func (pr *PredictionResult) GetValueAsInterface(columnName string) (interface{},
 // FeatureVector represents a vector of features with their name and value
 type FeatureVector struct {
 	features []*feature
+	vectorId string
+	// template the template which built the vector, its features are in the order of the template columns
+	template *RequestTemplate
+	frozen   bool
 }
 
 // NewFeatureVector is a constructor for FeatureVector with fluent API
@@ -217,8 +621,11 @@ func NewFeatureVector() *FeatureVector {
 	return &FeatureVector{}
 }
 
-// WithFeature set a feature on a FeatureVector
+// WithFeature set a feature on a FeatureVector. Decimal values, such as *big.Rat, *big.Float or types
+// having a Rat() *big.Rat method like shopspring decimal.Decimal, are sent as json numbers without rounding.
+// Setting a feature again overrides its value, unless FeatureEncoding.DuplicateFeatures rejects it
 func (fr *FeatureVector) WithFeature(name string, value interface{}) *FeatureVector {
+	fr = fr.mutable()
 	fr.features = append(fr.features, &feature{
 		name:  name,
 		value: value,
@@ -227,6 +634,36 @@ func (fr *FeatureVector) WithFeature(name string, value interface{}) *FeatureVec
 	return fr
 }
 
+// WithBytesFeature set a binary feature on a FeatureVector, such as an image or a serialized tensor.
+// It is sent as a base64 string, and its column is listed in the binary features header of the request
+func (fr *FeatureVector) WithBytesFeature(name string, value []byte) *FeatureVector {
+	return fr.WithFeature(name, bytesValue(value))
+}
+
+// WithVectorId sets an id identifying the vector, to get its result with PredictionResponse.GetPredictionByVectorId.
+// The id is not sent to the model
+func (fr *FeatureVector) WithVectorId(vectorId string) *FeatureVector {
+	fr = fr.mutable()
+	fr.vectorId = vectorId
+	return fr
+}
+
+// GetVectorId returns the id set by WithVectorId
+func (fr *FeatureVector) GetVectorId() string {
+	return fr.vectorId
+}
+
+// GetFeature returns the value of a feature in the vector, and whether it exists
+func (fr *FeatureVector) GetFeature(name string) (interface{}, bool) {
+	for idx := len(fr.features) - 1; idx >= 0; idx-- {
+		if fr.features[idx].name == name {
+			return fr.features[idx].value, true
+		}
+	}
+
+	return nil, false
+}
+
 type feature struct {
 	name  string
 	value interface{}
@@ -0,0 +1,155 @@
+package qwak
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestReleasedResponseRowsDoNotLeakColumns(t *testing.T) {
+	first, err := responseFromRaw([]byte(`[{"churn":1,"score":0.5},{"churn":0}]`))
+	require.NoError(t, err)
+	first.Release()
+	require.Nil(t, first.GetPredictions())
+
+	second, err := responseFromRaw([]byte(`[{"label":"a"}]`))
+	require.NoError(t, err)
+
+	require.Len(t, second.GetPredictions(), 1)
+	_, err = second.GetSinglePrediction().GetValueAsInt("churn")
+	require.Error(t, err)
+	label, err := second.GetSinglePrediction().GetValueAsString("label")
+	require.NoError(t, err)
+	require.Equal(t, "a", label)
+}
+
+func TestParsedResponseIsNotReleased(t *testing.T) {
+	parsed, err := ParsePredictionResponse([]byte(`[{"churn":1}]`))
+	require.NoError(t, err)
+	parsed.Release()
+
+	pooled, err := responseFromRaw([]byte(`[{"label":"a"}]`))
+	require.NoError(t, err)
+	pooled.Release()
+
+	require.Len(t, parsed.GetPredictions(), 1)
+	churn, err := parsed.GetSinglePrediction().GetValueAsInt("churn")
+	require.NoError(t, err)
+	require.Equal(t, 1, churn)
+}
+
+func TestResultsMappedToFeatureVectors(t *testing.T) {
+	request := NewPredictionRequest("model").AddFeatureVectors(
+		NewFeatureVector().WithVectorId("first").WithFeature("x", 1),
+		NewFeatureVector().WithVectorId("second").WithFeature("x", 2),
+	)
+
+	parsed, err := responseFromRaw([]byte(`[{"y":10},{"y":20}]`))
+	require.NoError(t, err)
+	response := parsed.forRequest(request)
+
+	result, err := response.GetPredictionByVectorId("second")
+	require.NoError(t, err)
+	value, err := result.GetValueAsInt("y")
+	require.NoError(t, err)
+	require.Equal(t, 20, value)
+
+	_, err = response.GetPredictionForVector(2)
+	require.Error(t, err)
+	_, err = response.GetPredictionByVectorId("missing")
+	require.Error(t, err)
+
+	truncated, err := responseFromRaw([]byte(`[{"y":10}]`))
+	require.NoError(t, err)
+	_, err = truncated.forRequest(request).GetPredictionForVector(0)
+	require.Error(t, err)
+}
+
+func TestIncompleteResponses(t *testing.T) {
+	empty, err := responseFromRaw([]byte(`[]`))
+	require.NoError(t, err)
+	_, err = empty.SinglePrediction()
+	require.ErrorIs(t, err, ErrEmptyPrediction)
+	require.ErrorIs(t, empty.checkComplete(), ErrEmptyPrediction)
+
+	missingColumn, err := responseFromRaw([]byte(`[{"churn":1,"score":0.5},{"churn":0}]`))
+	require.NoError(t, err)
+	require.EqualError(t, missingColumn.checkComplete(), "result at index 1 is missing column 'score'")
+
+	complete, err := responseFromRaw([]byte(`[{"churn":1},{"churn":0}]`))
+	require.NoError(t, err)
+	require.NoError(t, complete.checkComplete())
+	result, err := complete.SinglePrediction()
+	require.NoError(t, err)
+	require.Same(t, complete.GetSinglePrediction(), result)
+}
+
+func TestNumbersPrecision(t *testing.T) {
+	raw := []byte(`[{"id":9007199254740993,"price":0.1000000000000000055511151231257827,"score":0.5}]`)
+
+	lossy, err := responseFromRaw(raw)
+	require.NoError(t, err)
+	_, err = lossy.GetSinglePrediction().GetValueAsInt64("score")
+	require.Error(t, err)
+	id, err := lossy.GetSinglePrediction().GetValueAsInt64("id")
+	require.NoError(t, err)
+	require.NotEqual(t, int64(9007199254740993), id)
+
+	exact, err := responseFromRawWithCodec(raw, EncodingJSONCodec{UseNumber: true})
+	require.NoError(t, err)
+	result := exact.GetSinglePrediction()
+
+	id, err = result.GetValueAsInt64("id")
+	require.NoError(t, err)
+	require.Equal(t, int64(9007199254740993), id)
+
+	price, err := result.GetValueAsNumber("price")
+	require.NoError(t, err)
+	require.Equal(t, "0.1000000000000000055511151231257827", price.String())
+
+	score, err := result.GetValueAsFloat("score")
+	require.NoError(t, err)
+	require.Equal(t, 0.5, score)
+
+	_, err = responseFromRawWithCodec([]byte(`[{"id":1}] trailing`), EncodingJSONCodec{UseNumber: true})
+	require.Error(t, err)
+}
+
+func TestDecimalValues(t *testing.T) {
+	raw := []byte(`[{"price":12345678901234567.89,"rate":0.1,"fee":"19.99","label":true}]`)
+
+	exact, err := responseFromRawWithCodec(raw, EncodingJSONCodec{UseNumber: true})
+	require.NoError(t, err)
+	price, err := exact.GetSinglePrediction().GetValueAsDecimal("price")
+	require.NoError(t, err)
+	require.Equal(t, "12345678901234567.89", price.FloatString(2))
+
+	lossy, err := responseFromRaw(raw)
+	require.NoError(t, err)
+	rate, err := lossy.GetSinglePrediction().GetValueAsDecimal("rate")
+	require.NoError(t, err)
+	require.Equal(t, "1/10", rate.String())
+	fee, err := lossy.GetSinglePrediction().GetValueAsDecimal("fee")
+	require.NoError(t, err)
+	require.Equal(t, "1999/100", fee.String())
+	_, err = lossy.GetSinglePrediction().GetValueAsDecimal("label")
+	require.Error(t, err)
+}
+
+func TestRequestDefaults(t *testing.T) {
+	sparse := NewFeatureVector().WithFeature("age", 42)
+	request := NewPredictionRequest("model").
+		AddFeatureVectors(sparse, NewFeatureVector().WithFeature("country", "IL").WithFeature("age", nil)).
+		WithDefaults(map[string]interface{}{"country": "US", "age": 0})
+
+	body, err := request.encodeBody()
+	require.NoError(t, err)
+	require.Equal(t, `{"columns":["age","country"],"index":[0,1],"data":[[42,"US"],[null,"IL"]]}`, string(body))
+
+	_, ok := sparse.GetFeature("country")
+	require.False(t, ok)
+
+	size, err := request.EstimateSize()
+	require.NoError(t, err)
+	require.Equal(t, len(body), size)
+}
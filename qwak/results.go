@@ -0,0 +1,74 @@
+package qwak
+
+import "fmt"
+
+// Results returns an iterator over the results of the response, in order. With Go 1.23 or later, range over it:
+//
+//	for result := range response.Results() {
+//		...
+//	}
+//
+// Earlier Go versions may call it with a callback instead, or use ForEachResult.
+// The results are not copied, and the iterator must not be used after Release
+func (pr *PredictionResponse) Results() func(yield func(*PredictionResult) bool) {
+	return func(yield func(*PredictionResult) bool) {
+		for _, prediction := range pr.predictions {
+			if !yield(prediction) {
+				return
+			}
+		}
+	}
+}
+
+// ForEachResult calls fn with the index of each result of the response and the result, in order,
+// until fn returns false
+func (pr *PredictionResponse) ForEachResult(fn func(idx int, result *PredictionResult) bool) {
+	for idx, prediction := range pr.predictions {
+		if !fn(idx, prediction) {
+			return
+		}
+	}
+}
+
+// GetColumnAsFloats returns the values of a column across all results converted to float, in order.
+// An error is returned when a result misses the column or its value is not a number
+func (pr *PredictionResponse) GetColumnAsFloats(columnName string) ([]float64, error) {
+	return getColumn(pr, columnName, (*PredictionResult).GetValueAsFloat)
+}
+
+// GetColumnAsInts returns the values of a column across all results converted to int, in order.
+// An error is returned when a result misses the column or its value is not a number
+func (pr *PredictionResponse) GetColumnAsInts(columnName string) ([]int, error) {
+	return getColumn(pr, columnName, (*PredictionResult).GetValueAsInt)
+}
+
+// GetColumnAsInt64s returns the values of a column across all results converted to int64, in order.
+// An error is returned when a result misses the column or its value is not an integer
+func (pr *PredictionResponse) GetColumnAsInt64s(columnName string) ([]int64, error) {
+	return getColumn(pr, columnName, (*PredictionResult).GetValueAsInt64)
+}
+
+// GetColumnAsStrings returns the values of a column across all results converted to string, in order.
+// An error is returned when a result misses the column or its value is not a string
+func (pr *PredictionResponse) GetColumnAsStrings(columnName string) ([]string, error) {
+	return getColumn(pr, columnName, (*PredictionResult).GetValueAsString)
+}
+
+// GetColumnAsInterfaces returns the values of a column across all results without any conversion, in order.
+// An error is returned when a result misses the column
+func (pr *PredictionResponse) GetColumnAsInterfaces(columnName string) ([]interface{}, error) {
+	return getColumn(pr, columnName, (*PredictionResult).GetValueAsInterface)
+}
+
+// getColumn extracts a column from every result with one of the PredictionResult accessors
+func getColumn[T any](pr *PredictionResponse, columnName string, get func(*PredictionResult, string) (T, error)) ([]T, error) {
+	values := make([]T, len(pr.predictions))
+	for idx, prediction := range pr.predictions {
+		value, err := get(prediction, columnName)
+		if err != nil {
+			return nil, fmt.Errorf("column '%s' of result at index %d: %w", columnName, idx, err)
+		}
+		values[idx] = value
+	}
+	return values, nil
+}
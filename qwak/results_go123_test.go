@@ -0,0 +1,25 @@
+//go:build go1.23
+
+package qwak
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestRangeOverResults(t *testing.T) {
+	response, err := responseFromRaw([]byte(`[{"y":1},{"y":2},{"y":3}]`))
+	require.NoError(t, err)
+
+	var values []int
+	for result := range response.Results() {
+		value, err := result.GetValueAsInt("y")
+		require.NoError(t, err)
+		if value == 3 {
+			break
+		}
+		values = append(values, value)
+	}
+	require.Equal(t, []int{1, 2}, values)
+}
@@ -0,0 +1,54 @@
+package qwak
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestResultsCallbacks(t *testing.T) {
+	response, err := responseFromRaw([]byte(`[{"y":1},{"y":2},{"y":3}]`))
+	require.NoError(t, err)
+
+	var values []int
+	response.Results()(func(result *PredictionResult) bool {
+		value, _ := result.GetValueAsInt("y")
+		values = append(values, value)
+		return true
+	})
+	require.Equal(t, []int{1, 2, 3}, values)
+
+	var indexes []int
+	response.ForEachResult(func(idx int, result *PredictionResult) bool {
+		indexes = append(indexes, idx)
+		return idx < 1
+	})
+	require.Equal(t, []int{0, 1}, indexes)
+}
+
+func TestColumnAccessors(t *testing.T) {
+	response, err := responseFromRaw([]byte(`[{"score":0.5,"label":"a","rank":1},{"score":1,"label":"b","rank":2}]`))
+	require.NoError(t, err)
+
+	scores, err := response.GetColumnAsFloats("score")
+	require.NoError(t, err)
+	require.Equal(t, []float64{0.5, 1}, scores)
+
+	labels, err := response.GetColumnAsStrings("label")
+	require.NoError(t, err)
+	require.Equal(t, []string{"a", "b"}, labels)
+
+	ranks, err := response.GetColumnAsInt64s("rank")
+	require.NoError(t, err)
+	require.Equal(t, []int64{1, 2}, ranks)
+
+	_, err = response.GetColumnAsFloats("label")
+	require.Error(t, err)
+	require.Contains(t, err.Error(), "result at index 0")
+
+	incomplete, err := responseFromRaw([]byte(`[{"score":0.5},{}]`))
+	require.NoError(t, err)
+	_, err = incomplete.GetColumnAsInterfaces("score")
+	require.Error(t, err)
+	require.Contains(t, err.Error(), "result at index 1")
+}
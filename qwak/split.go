@@ -0,0 +1,82 @@
+package qwak
+
+import (
+	"context"
+	"errors"
+	"hash/fnv"
+)
+
+// SplitBranch identifies the model which served a request routed by a SplitClient
+type SplitBranch string
+
+const (
+	ControlBranch   SplitBranch = "control"
+	TreatmentBranch SplitBranch = "treatment"
+)
+
+// SplitOptions configures the traffic split of a SplitClient
+type SplitOptions struct {
+	// ControlModelId the model serving the keys not routed to the treatment
+	ControlModelId string
+	// TreatmentModelId the model serving TreatmentPercent of the keys
+	TreatmentModelId string
+	// TreatmentPercent the percentage of keys routed to the treatment model, between 0 and 100
+	TreatmentPercent float64
+	// Salt is mixed into the keys hash, so that experiments sharing keys split them independently
+	Salt string
+	// OnRoute is invoked with the branch serving each request, e.g. to record the exposure of the key
+	OnRoute func(key string, branch SplitBranch, request *PredictionRequest)
+}
+
+// SplitClient routes requests between a control and a treatment model for consumer driven A/B tests.
+// Routing hashes a key supplied by the caller, such as a user id, so a key is always served by the same model
+type SplitClient struct {
+	predictor Predictor
+	options   SplitOptions
+}
+
+// NewSplitClient returns a SplitClient predicting with predictor
+func NewSplitClient(predictor Predictor, options SplitOptions) (*SplitClient, error) {
+	if options.ControlModelId == "" || options.TreatmentModelId == "" {
+		return nil, errors.New("control and treatment model ids are mandatory")
+	}
+
+	if options.TreatmentPercent < 0 || options.TreatmentPercent > 100 {
+		return nil, errors.New("treatment percent must be between 0 and 100")
+	}
+
+	return &SplitClient{predictor: predictor, options: options}, nil
+}
+
+// Branch returns the branch serving the key
+func (s *SplitClient) Branch(key string) SplitBranch {
+	hash := fnv.New64a()
+	_, _ = hash.Write([]byte(s.options.Salt))
+	_, _ = hash.Write([]byte{0})
+	_, _ = hash.Write([]byte(key))
+
+	// buckets of a hundredth of a percent
+	if float64(hash.Sum64()%10000) < s.options.TreatmentPercent*100 {
+		return TreatmentBranch
+	}
+	return ControlBranch
+}
+
+// Predict sends the request to the model of the key branch, ignoring the model id of the request,
+// and returns the branch which served it
+func (s *SplitClient) Predict(ctx context.Context, key string, predictionRequest *PredictionRequest) (*PredictionResponse, SplitBranch, error) {
+	branch := s.Branch(key)
+
+	routed := *predictionRequest
+	routed.modelId = s.options.ControlModelId
+	if branch == TreatmentBranch {
+		routed.modelId = s.options.TreatmentModelId
+	}
+
+	if s.options.OnRoute != nil {
+		s.options.OnRoute(key, branch, &routed)
+	}
+
+	response, err := s.predictor.PredictWithCtx(ctx, &routed)
+	return response, branch, err
+}
@@ -0,0 +1,359 @@
+package qwak
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"mime"
+	gohttp "net/http"
+	"strings"
+
+	"github.com/qwak-ai/go-sdk/qwak/http"
+)
+
+const (
+	// StreamDoneData is the payload some deployments send to mark the end of a stream, it is not delivered as an event
+	StreamDoneData = "[DONE]"
+
+	maxStreamLineSize = 4 * 1024 * 1024
+)
+
+// StreamEvent is a partial output of a streaming prediction
+type StreamEvent struct {
+	// Event the server-sent event name, empty for default events and chunked responses
+	Event string
+	// Data the raw payload of the event
+	Data []byte
+	// Predictions the results decoded from Data when it holds a json object or an array of objects
+	Predictions []*PredictionResult
+	// Err is set on the last event delivered when the stream failed
+	Err error
+}
+
+// PredictStreaming performs an inference on a streaming-capable model deployment.
+// Server-sent events and newline delimited chunked responses are supported, each event or line is delivered
+// in order through the returned channel, which is closed when the stream ends. The channel is unbuffered, so the
+// response is read only as fast as events are consumed. Cancel ctx to stop consuming the stream early.
+// The stream goes through the concurrency limits, failover urls, request hooks and rate limit throttling of
+// the other predictions, holding its in-flight slot until it ends. Unlike them, it is not retried, cached or
+// coalesced, and the client RequestTimeout does not apply: ctx and the model Timeout bound the whole stream
+func (c *RealTimeClient) PredictStreaming(ctx context.Context, predictionRequest *PredictionRequest) (<-chan StreamEvent, error) {
+	ctx, response, done, err := c.openStream(ctx, predictionRequest, http.StreamingAcceptHeader)
+
+	if err != nil {
+		return nil, err
+	}
+
+	events := make(chan StreamEvent)
+	mediaType, _, _ := mime.ParseMediaType(response.Header.Get("content-type"))
+
+	go func() {
+		defer close(events)
+		defer response.Body.Close()
+
+		emit := func(event StreamEvent) bool {
+			if event.Err == nil && len(event.Data) > 0 {
+				event.Predictions = c.decodeStreamPredictions(event.Data)
+			}
+			select {
+			case events <- event:
+				return true
+			case <-ctx.Done():
+				return false
+			}
+		}
+
+		var err error
+		if mediaType == "text/event-stream" {
+			err = readServerSentEvents(response.Body, emit)
+		} else {
+			err = readDelimitedChunks(response.Body, emit)
+		}
+
+		if err != nil && ctx.Err() == nil {
+			emit(StreamEvent{Err: fmt.Errorf("qwak client failed to read prediction stream: %w", err)})
+		}
+		done(err)
+	}()
+
+	return events, nil
+}
+
+// openStream sends a prediction whose response is read incrementally, through the limiters and failover urls.
+// The returned context bounds the stream, and done must be called with the outcome once the body is consumed
+func (c *RealTimeClient) openStream(ctx context.Context, predictionRequest *PredictionRequest, accept string) (context.Context, *gohttp.Response, func(err error), error) {
+	if len(predictionRequest.modelId) == 0 {
+		return nil, nil, nil, errors.New("model id is missing in request")
+	}
+
+	body, err := encodeRequestBody(c.codec, c.featureEncoding, predictionRequest)
+
+	if err != nil {
+		return nil, nil, nil, fmt.Errorf("qwak client failed to encode prediction request: %w", err)
+	}
+
+	ctx, cancel := c.withModelTimeout(ctx, predictionRequest.modelId)
+	release, err := c.acquireSlots(ctx, predictionRequest.modelId)
+
+	if err != nil {
+		cancel()
+		return nil, nil, nil, fmt.Errorf("qwak client failed to predict: %w", err)
+	}
+
+	response, err := c.openStreamWithFailover(ctx, predictionRequest, body, accept)
+
+	if err != nil {
+		release(err)
+		cancel()
+		return nil, nil, nil, err
+	}
+
+	return ctx, response, func(err error) {
+		release(err)
+		cancel()
+	}, nil
+}
+
+// openStreamWithFailover opens the stream on the first available endpoint, failing over to the next ones
+// while no response is received
+func (c *RealTimeClient) openStreamWithFailover(ctx context.Context, predictionRequest *PredictionRequest, body []byte, accept string) (*gohttp.Response, error) {
+	if c.endpoints == nil {
+		return c.doOpenStream(ctx, predictionRequest, body, c.url, accept)
+	}
+
+	var lastErr error
+	for _, idx := range c.endpoints.candidates() {
+		response, err := c.doOpenStream(ctx, predictionRequest, body, c.endpoints.urls[idx], accept)
+
+		if err == nil {
+			c.endpoints.markHealthy(idx)
+			return response, nil
+		}
+
+		lastErr = err
+		if !shouldFailover(err) || ctx.Err() != nil {
+			return nil, err
+		}
+		c.endpoints.markFailed(idx)
+	}
+
+	return nil, lastErr
+}
+
+// doOpenStream sends an encoded prediction request to the model, returning the response once its headers are received
+func (c *RealTimeClient) doOpenStream(ctx context.Context, predictionRequest *PredictionRequest, body []byte, baseUrl string, accept string) (*gohttp.Response, error) {
+	token, err := c.getToken(ctx)
+
+	if err != nil {
+		return nil, fmt.Errorf("qwak client failed to predict: %s", err.Error())
+	}
+
+	if c.requestHooks != nil {
+		ctx = http.WithRequestHooks(ctx, *c.requestHooks)
+	}
+
+	request, err := c.newPredictionHttpRequest(ctx, predictionRequest, body, baseUrl, token)
+
+	if err != nil {
+		return nil, fmt.Errorf("qwak client failed to predict: %s", err.Error())
+	}
+
+	if accept != "" {
+		request.Header.Set("accept", accept)
+	}
+
+	if err := c.rateThrottle.wait(ctx); err != nil {
+		return nil, fmt.Errorf("qwak client failed to predict: %w", err)
+	}
+
+	response, err := http.DoStreamingRequest(c.streamingClient, request)
+
+	if err != nil {
+		return nil, fmt.Errorf("qwak client failed to send predict request: %w", err)
+	}
+
+	if rateLimit, ok := http.ParseRateLimit(response.Header, c.clock.Now()); ok {
+		c.rateThrottle.observe(rateLimit)
+	}
+
+	return response, nil
+}
+
+// decodeStreamPredictions decodes a json object or an array of objects, other payloads have no predictions
+func (c *RealTimeClient) decodeStreamPredictions(data []byte) []*PredictionResult {
+	trimmed := bytes.TrimSpace(data)
+
+	if len(trimmed) > 0 && trimmed[0] == '{' {
+		trimmed = append(append([]byte{'['}, trimmed...), ']')
+	}
+
+	if len(trimmed) == 0 || trimmed[0] != '[' {
+		return nil
+	}
+
+	response, err := responseFromRawWithCodec(trimmed, c.codec)
+
+	if err != nil {
+		return nil
+	}
+
+	return response.GetPredictions()
+}
+
+// readServerSentEvents parses a text/event-stream body, calling emit for each dispatched event
+// until emit returns false or the body ends
+func readServerSentEvents(body io.Reader, emit func(StreamEvent) bool) error {
+	scanner := bufio.NewScanner(body)
+	scanner.Buffer(make([]byte, 0, 64*1024), maxStreamLineSize)
+
+	var eventName string
+	var data []string
+	hasData := false
+
+	for scanner.Scan() {
+		line := scanner.Text()
+
+		if line == "" {
+			if hasData {
+				payload := strings.Join(data, "\n")
+				if payload == StreamDoneData {
+					return nil
+				}
+				if !emit(StreamEvent{Event: eventName, Data: []byte(payload)}) {
+					return nil
+				}
+			}
+			eventName, data, hasData = "", nil, false
+			continue
+		}
+
+		if strings.HasPrefix(line, ":") {
+			continue
+		}
+
+		field, value := line, ""
+		if idx := strings.IndexByte(line, ':'); idx >= 0 {
+			field, value = line[:idx], strings.TrimPrefix(line[idx+1:], " ")
+		}
+
+		switch field {
+		case "event":
+			eventName = value
+		case "data":
+			data = append(data, value)
+			hasData = true
+		}
+	}
+
+	if err := scanner.Err(); err != nil {
+		return err
+	}
+
+	if hasData {
+		payload := strings.Join(data, "\n")
+		if payload != StreamDoneData {
+			emit(StreamEvent{Event: eventName, Data: []byte(payload)})
+		}
+	}
+
+	return nil
+}
+
+// readDelimitedChunks parses a newline delimited body, calling emit for each non empty line
+// until emit returns false or the body ends
+func readDelimitedChunks(body io.Reader, emit func(StreamEvent) bool) error {
+	scanner := bufio.NewScanner(body)
+	scanner.Buffer(make([]byte, 0, 64*1024), maxStreamLineSize)
+
+	for scanner.Scan() {
+		line := bytes.TrimSpace(scanner.Bytes())
+
+		if len(line) == 0 {
+			continue
+		}
+
+		if string(line) == StreamDoneData {
+			return nil
+		}
+
+		data := make([]byte, len(line))
+		copy(data, line)
+
+		if !emit(StreamEvent{Data: data}) {
+			return nil
+		}
+	}
+
+	return scanner.Err()
+}
+
+// PredictEach performs an inference and calls fn with the index and the result of each row of the response,
+// as they are decoded, so memory stays bounded for batches of many thousands of rows. A fn error stops
+// reading the response and is returned as is. The results must not be released.
+// Like streaming predictions, the request goes through the concurrency limits, failover urls, request hooks and
+// rate limit throttling, but is not retried, cached or coalesced, and ctx and the model Timeout bound the whole
+// response instead of the client RequestTimeout
+func (c *RealTimeClient) PredictEach(ctx context.Context, predictionRequest *PredictionRequest, fn func(idx int, result *PredictionResult) error) error {
+	_, response, done, err := c.openStream(ctx, predictionRequest, "")
+
+	if err != nil {
+		return err
+	}
+	defer response.Body.Close()
+
+	err = c.decodeEach(response.Body, fn)
+	done(err)
+	return err
+}
+
+// decodeEach decodes a json array of objects one object at a time, calling fn with each of them
+func (c *RealTimeClient) decodeEach(body io.Reader, fn func(idx int, result *PredictionResult) error) error {
+	decoder := json.NewDecoder(body)
+	codec := c.codec
+	if jsonCodec, ok := codec.(EncodingJSONCodec); codec == nil || ok {
+		// encoding/json rows are decoded from the body directly, other codecs decode each row separately
+		if jsonCodec.UseNumber {
+			decoder.UseNumber()
+		}
+		codec = nil
+	}
+
+	token, err := decoder.Token()
+	if err != nil {
+		return fmt.Errorf("qwak client failed to read prediction response: %w", err)
+	}
+	if delim, ok := token.(json.Delim); !ok || delim != '[' {
+		return errors.New("qwak client failed to read prediction response: response is not a json array")
+	}
+
+	for idx := 0; decoder.More(); idx++ {
+		var row map[string]interface{}
+		if codec == nil {
+			err = decoder.Decode(&row)
+		} else {
+			var raw json.RawMessage
+			if err = decoder.Decode(&raw); err == nil {
+				err = codec.Unmarshal(raw, &row)
+			}
+		}
+		if err != nil {
+			return fmt.Errorf("qwak client failed to read result at index %d: %w", idx, err)
+		}
+		if row == nil {
+			return fmt.Errorf("qwak client failed to read result at index %d: result is not a json object", idx)
+		}
+
+		if err := fn(idx, &PredictionResult{valuesMap: row}); err != nil {
+			return err
+		}
+	}
+
+	if _, err := decoder.Token(); err != nil {
+		return fmt.Errorf("qwak client failed to read prediction response: %w", err)
+	}
+	return nil
+}
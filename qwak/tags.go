@@ -0,0 +1,56 @@
+package qwak
+
+import (
+	gohttp "net/http"
+	"net/url"
+	"sort"
+	"strings"
+
+	"github.com/qwak-ai/go-sdk/qwak/http"
+)
+
+// WithTag attaches a free-form tag to the request, such as the team, use case or experiment it serves,
+// so its usage and cost can be attributed in Qwak analytics
+func (ir *PredictionRequest) WithTag(key string, value string) *PredictionRequest {
+	ir = ir.mutable()
+	if ir.tags == nil {
+		ir.tags = map[string]string{}
+	}
+	ir.tags[key] = value
+	return ir
+}
+
+// GetTags returns the tags attached to the request
+func (ir *PredictionRequest) GetTags() map[string]string {
+	return ir.tags
+}
+
+// setTags sets the tags header from the client tags and the request tags, which take precedence
+func (c *RealTimeClient) setTags(request *gohttp.Request, predictionRequest *PredictionRequest) {
+	if len(c.tags) == 0 && len(predictionRequest.tags) == 0 {
+		return
+	}
+
+	tags := make(map[string]string, len(c.tags)+len(predictionRequest.tags))
+	for key, value := range c.tags {
+		tags[key] = value
+	}
+	for key, value := range predictionRequest.tags {
+		tags[key] = value
+	}
+
+	request.Header.Set(http.TagsHeader, encodeTags(tags))
+}
+
+// encodeTags formats tags as comma separated key=value pairs sorted by key, with keys and values url encoded
+func encodeTags(tags map[string]string) string {
+	pairs := make([]string, 0, len(tags))
+	for key, value := range tags {
+		if key == "" {
+			continue
+		}
+		pairs = append(pairs, url.QueryEscape(key)+"="+url.QueryEscape(value))
+	}
+	sort.Strings(pairs)
+	return strings.Join(pairs, ",")
+}
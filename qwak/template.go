@@ -0,0 +1,192 @@
+package qwak
+
+import (
+	"errors"
+	"fmt"
+	"reflect"
+	"sync"
+
+	"github.com/qwak-ai/go-sdk/qwak/http"
+)
+
+// RequestTemplate builds prediction requests of a model with a fixed column layout. Requests built from
+// a template are encoded without looking up the column of each feature, which matters at thousands of
+// predictions per second. Adding features to the vectors of a template, or mixing them with other vectors,
+// is allowed but falls back to the regular encoding. A RequestTemplate is safe for concurrent use
+type RequestTemplate struct {
+	modelId     string
+	columns     []string
+	columnsJSON []byte
+	// structColumns caches the field index of each column by struct type
+	structColumns sync.Map
+}
+
+// NewRequestTemplate returns a template of requests to the model with the columns, in order
+func NewRequestTemplate(modelId string, columns ...string) (*RequestTemplate, error) {
+	if modelId == "" {
+		return nil, errors.New("model id is missing")
+	}
+	if len(columns) == 0 {
+		return nil, errors.New("template has no columns")
+	}
+
+	seen := make(map[string]bool, len(columns))
+	for _, column := range columns {
+		if column == "" {
+			return nil, errors.New("template has a column without name")
+		}
+		if seen[column] {
+			return nil, fmt.Errorf("column '%s' is repeated", column)
+		}
+		seen[column] = true
+	}
+
+	columnsJSON := []byte{'['}
+	for idx, column := range columns {
+		if idx > 0 {
+			columnsJSON = append(columnsJSON, ',')
+		}
+		columnsJSON = appendJSONString(columnsJSON, column)
+	}
+
+	return &RequestTemplate{
+		modelId:     modelId,
+		columns:     append([]string(nil), columns...),
+		columnsJSON: append(columnsJSON, ']'),
+	}, nil
+}
+
+// GetColumns returns the columns of the template, in order
+func (t *RequestTemplate) GetColumns() []string {
+	return append([]string(nil), t.columns...)
+}
+
+// Row returns a feature vector with a value per column of the template, in order
+func (t *RequestTemplate) Row(values ...interface{}) (*FeatureVector, error) {
+	if len(values) != len(t.columns) {
+		return nil, fmt.Errorf("row has %d values for %d columns", len(values), len(t.columns))
+	}
+
+	vector := &FeatureVector{features: make([]*feature, len(values)), template: t}
+	features := make([]feature, len(values))
+	for idx, value := range values {
+		features[idx] = feature{name: t.columns[idx], value: value}
+		vector.features[idx] = &features[idx]
+	}
+	return vector, nil
+}
+
+// Struct returns a feature vector with the fields of a struct, or a pointer to a struct, mapped to the
+// columns of the template as in TypedClient. An error is returned when a column has no field
+func (t *RequestTemplate) Struct(value interface{}) (*FeatureVector, error) {
+	reflected := reflect.ValueOf(value)
+	if reflected.Kind() == reflect.Ptr && !reflected.IsNil() {
+		reflected = reflected.Elem()
+	}
+	if reflected.Kind() != reflect.Struct {
+		return nil, fmt.Errorf("%T is not a struct", value)
+	}
+
+	indexes, err := t.fieldIndexes(reflected.Type())
+	if err != nil {
+		return nil, err
+	}
+
+	values := make([]interface{}, len(indexes))
+	for idx, fieldIndex := range indexes {
+		values[idx] = reflected.FieldByIndex(fieldIndex).Interface()
+	}
+	return t.Row(values...)
+}
+
+// fieldIndexes returns the index of the field of each column in a struct type
+func (t *RequestTemplate) fieldIndexes(structType reflect.Type) ([][]int, error) {
+	if cached, ok := t.structColumns.Load(structType); ok {
+		return cached.([][]int), nil
+	}
+
+	fields, err := structFields(structType)
+	if err != nil {
+		return nil, err
+	}
+
+	byColumn := make(map[string][]int, len(fields))
+	for _, field := range fields {
+		byColumn[field.column] = field.index
+	}
+
+	indexes := make([][]int, len(t.columns))
+	for idx, column := range t.columns {
+		index, ok := byColumn[column]
+		if !ok {
+			return nil, fmt.Errorf("%s has no field for column '%s'", structType, column)
+		}
+		indexes[idx] = index
+	}
+
+	t.structColumns.Store(structType, indexes)
+	return indexes, nil
+}
+
+// NewRequest returns a prediction request with a feature vector per row, each row holding a value
+// per column of the template, in order
+func (t *RequestTemplate) NewRequest(rows ...[]interface{}) (*PredictionRequest, error) {
+	request := &PredictionRequest{modelId: t.modelId, template: t, featuresVector: make([]*FeatureVector, len(rows))}
+	for idx, row := range rows {
+		vector, err := t.Row(row...)
+		if err != nil {
+			return nil, fmt.Errorf("invalid row at index %d: %w", idx, err)
+		}
+		request.featuresVector[idx] = vector
+	}
+	return request, nil
+}
+
+// NewRequestFromStructs returns a prediction request with a feature vector per element of values,
+// a slice of structs or of pointers to structs, mapped to the columns of the template as by Struct
+func (t *RequestTemplate) NewRequestFromStructs(values interface{}) (*PredictionRequest, error) {
+	reflected := reflect.ValueOf(values)
+	if reflected.Kind() != reflect.Slice {
+		return nil, fmt.Errorf("%T is not a slice", values)
+	}
+
+	request := &PredictionRequest{modelId: t.modelId, template: t, featuresVector: make([]*FeatureVector, reflected.Len())}
+	for idx := range request.featuresVector {
+		vector, err := t.Struct(reflected.Index(idx).Interface())
+		if err != nil {
+			return nil, fmt.Errorf("invalid struct at index %d: %w", idx, err)
+		}
+		request.featuresVector[idx] = vector
+	}
+	return request, nil
+}
+
+// layoutTemplate returns the template of the request when all its vectors still have the layout of the
+// template, nil otherwise
+func (ir *PredictionRequest) layoutTemplate() *RequestTemplate {
+	if ir.template == nil {
+		return nil
+	}
+
+	for _, vector := range ir.featuresVector {
+		if vector == nil || vector.template != ir.template || len(vector.features) != len(ir.template.columns) {
+			return nil
+		}
+	}
+	return ir.template
+}
+
+// templateDataFrame is asPandaOrientedDfWith for requests having the layout of their template
+func (ir *PredictionRequest) templateDataFrame(template *RequestTemplate, encoding *FeatureEncoding) http.PandaOrientedDf {
+	index := make([]int, len(ir.featuresVector))
+	data := make([][]interface{}, len(ir.featuresVector))
+	for idx, vector := range ir.featuresVector {
+		index[idx] = idx
+		data[idx] = make([]interface{}, len(vector.features))
+		for featureIdx, feature := range vector.features {
+			data[idx][featureIdx] = encoding.encodeAny(feature.value)
+		}
+	}
+
+	return http.NewPandaOrientedDf(append([]string(nil), template.columns...), index, data)
+}
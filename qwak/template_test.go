@@ -0,0 +1,62 @@
+package qwak
+
+import (
+	"encoding/json"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+type templateInput struct {
+	Age   int     `qwak:"age"`
+	Score float64 `json:"score"`
+	Plan  string
+}
+
+func TestRequestTemplate(t *testing.T) {
+	template, err := NewRequestTemplate("churn", "age", "score", "Plan")
+	require.NoError(t, err)
+
+	request, err := template.NewRequest([]interface{}{31, 0.5, "basic"}, []interface{}{42, nil, "pro"})
+	require.NoError(t, err)
+	require.NotNil(t, request.layoutTemplate())
+
+	fromStructs, err := template.NewRequestFromStructs([]templateInput{{31, 0.5, "basic"}, {42, 0, "pro"}})
+	require.NoError(t, err)
+
+	expected := `{"columns":["age","score","Plan"],"index":[0,1],"data":[[31,0.5,"basic"],[42,null,"pro"]]}`
+	body, err := request.encodeBody()
+	require.NoError(t, err)
+	require.Equal(t, expected, string(body))
+
+	marshaled, err := json.Marshal(request.asPandaOrientedDf())
+	require.NoError(t, err)
+	require.Equal(t, expected, string(marshaled))
+
+	body, err = fromStructs.encodeBody()
+	require.NoError(t, err)
+	require.Equal(t, `{"columns":["age","score","Plan"],"index":[0,1],"data":[[31,0.5,"basic"],[42,0,"pro"]]}`, string(body))
+
+	// vectors changed after being built fall back to the regular encoding
+	request.GetFeatureVectors()[1].WithFeature("extra", true)
+	require.Nil(t, request.layoutTemplate())
+	body, err = request.encodeBody()
+	require.NoError(t, err)
+	require.Equal(t, `{"columns":["age","score","Plan","extra"],"index":[0,1],"data":[[31,0.5,"basic",null],[42,null,"pro",true]]}`, string(body))
+
+	_, err = template.NewRequest([]interface{}{1})
+	require.Error(t, err)
+	_, err = template.Struct(address{})
+	require.Error(t, err)
+	_, err = NewRequestTemplate("churn", "age", "age")
+	require.Error(t, err)
+}
+
+func BenchmarkTemplateEncoding(b *testing.B) {
+	template, _ := NewRequestTemplate("churn", "age", "score", "plan", "country", "tenure")
+	b.ReportAllocs()
+	for i := 0; i < b.N; i++ {
+		request, _ := template.NewRequest([]interface{}{31, 0.5, "basic", "IL", 12})
+		_, _ = encodeRequestBody(defaultCodec, defaultFeatureEncoding, request)
+	}
+}
@@ -2,22 +2,16 @@ package it
 
 import (
 	"fmt"
-	"github.com/stretchr/testify/mock"
+	"github.com/qwak-ai/go-sdk/qwak/qwaktest"
 	"io"
 	"net/http"
 	"strings"
 	"time"
 )
 
-type HttpClientMock struct {
-	mock.Mock
-}
-
-func (hcm *HttpClientMock) Do(request *http.Request) (*http.Response, error) {
-	args := hcm.Mock.MethodCalled("Do", request)
-
-	return args.Get(0).(*http.Response), args.Error(1)
-}
+// HttpClientMock is kept for the existing tests.
+// Deprecated: use qwaktest.HttpClientMock
+type HttpClientMock = qwaktest.HttpClientMock
 
 func GetAuthResponseWithLongExpiration() string {
 	now := time.Now()
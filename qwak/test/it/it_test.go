@@ -2,14 +2,27 @@ package it_test
 
 import (
 	"context"
+	"errors"
+	"fmt"
+	"io"
+	"net"
 	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"sync/atomic"
 	"testing"
 	"time"
 
 	"github.com/qwak-ai/go-sdk/qwak"
 	"github.com/stretchr/testify/require"
 
+	"github.com/qwak-ai/go-sdk/qwak/authentication"
 	qwakhttp "github.com/qwak-ai/go-sdk/qwak/http"
+	"github.com/qwak-ai/go-sdk/qwak/qwaktest"
 	"github.com/qwak-ai/go-sdk/qwak/test/it"
 	"github.com/stretchr/testify/mock"
 	"github.com/stretchr/testify/suite"
@@ -411,9 +424,307 @@ func (s *IntegrationTestSuite) TestRetryOnPredictFailureMaxAttempts() {
 	require.Error(s.T(), err)
 
 	// Then
+	var retryErr *qwakhttp.RetryError
+	require.True(s.T(), errors.As(err, &retryErr))
+	s.Assert().Len(retryErr.Attempts, 5)
+	s.Assert().Equal(503, retryErr.LastStatusCode())
 	s.HttpMock.Mock.AssertExpectations(s.T())
 }
 
+func (s *IntegrationTestSuite) TestRetryAfterOnTooManyRequests() {
+	// Given
+	s.givenQwakClientWithMockedHttpClientWithRetryPolicy()
+
+	s.HttpMock.On("Do", mock.MatchedBy(func(req *http.Request) bool {
+		return req.URL.String() == qwakhttp.DefaultAuthEndpointUri
+	})).Return(it.GetHttpReponse(it.GetAuthResponseWithLongExpiration(), 200), nil).Once()
+
+	throttledResponse := it.GetHttpReponse("slow down", 429)
+	throttledResponse.Header = http.Header{"Retry-After": []string{"1"}}
+	s.HttpMock.On("Do", mock.MatchedBy(func(req *http.Request) bool {
+		return req.URL.String() == "https://models.donald.qwak.ai/v1/otf/predict"
+	})).Return(throttledResponse, nil).Once().
+		On("Do", mock.MatchedBy(func(req *http.Request) bool {
+			return req.URL.String() == "https://models.donald.qwak.ai/v1/otf/predict"
+		})).Return(it.GetHttpReponse(it.GetPredictionResult(), 200), nil).Once()
+
+	// When
+	predictionRequest := qwak.NewPredictionRequest("otf").AddFeatureVector(
+		qwak.NewFeatureVector().
+			WithFeature("State", "PPP"),
+	)
+	start := time.Now()
+	_, err := s.realTimeClient.Predict(predictionRequest)
+
+	// Then
+	require.NoError(s.T(), err)
+	s.Assert().True(time.Since(start) >= time.Second)
+	s.HttpMock.Mock.AssertExpectations(s.T())
+}
+
+func (s *IntegrationTestSuite) TestIdempotencyKeyPreservedAcrossRetries() {
+	// Given
+	client, err := qwak.NewRealTimeClient(qwak.RealTimeClientConfig{
+		ApiKey:          s.ApiKey,
+		RetryPolicy:     qwakhttp.BasicExponentialBackoffRetryPolicy(),
+		Environment:     "donald",
+		HttpClient:      &s.HttpMock,
+		IdempotencyKeys: true,
+	})
+	require.NoError(s.T(), err)
+
+	s.HttpMock.On("Do", mock.MatchedBy(func(req *http.Request) bool {
+		return req.URL.String() == qwakhttp.DefaultAuthEndpointUri
+	})).Return(it.GetHttpReponse(it.GetAuthResponseWithLongExpiration(), 200), nil).Once()
+
+	var keys []string
+	s.HttpMock.On("Do", mock.MatchedBy(func(req *http.Request) bool {
+		if req.URL.String() != "https://models.donald.qwak.ai/v1/idempotent/predict" {
+			return false
+		}
+		keys = append(keys, req.Header.Get(qwakhttp.IdempotencyKeyHeader))
+		return true
+	})).Return(it.GetHttpReponse(it.GetPredictionResult(), 503), nil).Once().
+		On("Do", mock.MatchedBy(func(req *http.Request) bool {
+			if req.URL.String() != "https://models.donald.qwak.ai/v1/idempotent/predict" {
+				return false
+			}
+			keys = append(keys, req.Header.Get(qwakhttp.IdempotencyKeyHeader))
+			return true
+		})).Return(it.GetHttpReponse(it.GetPredictionResult(), 200), nil).Once()
+
+	// When
+	_, err = client.Predict(qwak.NewPredictionRequest("idempotent").AddFeatureVector(
+		qwak.NewFeatureVector().WithFeature("State", "PPP"),
+	))
+
+	// Then
+	require.NoError(s.T(), err)
+	require.NotEmpty(s.T(), keys)
+	for _, key := range keys {
+		s.Assert().Len(key, 36)
+		s.Assert().Equal(keys[0], key)
+	}
+	s.HttpMock.Mock.AssertExpectations(s.T())
+}
+
+type tenantKey struct{}
+
+func (s *IntegrationTestSuite) TestContextHeaders() {
+	// Given
+	client, err := qwak.NewRealTimeClient(qwak.RealTimeClientConfig{
+		ApiKey:      s.ApiKey,
+		Environment: "donald",
+		HttpClient:  &s.HttpMock,
+		ContextHeaders: []qwak.ContextHeaderExtractor{
+			qwak.HeaderFromContextKey("X-Tenant-Id", tenantKey{}),
+		},
+	})
+	require.NoError(s.T(), err)
+
+	s.HttpMock.On("Do", mock.MatchedBy(func(req *http.Request) bool {
+		return req.URL.String() == qwakhttp.DefaultAuthEndpointUri
+	})).Return(it.GetHttpReponse(it.GetAuthResponseWithLongExpiration(), 200), nil).Once()
+
+	s.HttpMock.On("Do", mock.MatchedBy(func(req *http.Request) bool {
+		return req.URL.String() == "https://models.donald.qwak.ai/v1/tenants/predict" &&
+			req.Header.Get("X-Tenant-Id") == "acme"
+	})).Return(it.GetHttpReponse(it.GetPredictionResult(), 200), nil).Once()
+
+	// When
+	ctx := context.WithValue(context.Background(), tenantKey{}, "acme")
+	_, err = client.PredictWithCtx(ctx, qwak.NewPredictionRequest("tenants").AddFeatureVector(
+		qwak.NewFeatureVector().WithFeature("State", "PPP"),
+	))
+
+	// Then
+	require.NoError(s.T(), err)
+	s.HttpMock.Mock.AssertExpectations(s.T())
+}
+
+func (s *IntegrationTestSuite) TestRequestTags() {
+	// Given
+	client, err := qwak.NewRealTimeClient(qwak.RealTimeClientConfig{
+		ApiKey:      s.ApiKey,
+		Environment: "donald",
+		HttpClient:  &s.HttpMock,
+		Tags:        map[string]string{"team": "risk", "use_case": "churn"},
+	})
+	require.NoError(s.T(), err)
+
+	s.HttpMock.On("Do", mock.MatchedBy(func(req *http.Request) bool {
+		return req.URL.String() == qwakhttp.DefaultAuthEndpointUri
+	})).Return(it.GetHttpReponse(it.GetAuthResponseWithLongExpiration(), 200), nil).Once()
+
+	s.HttpMock.On("Do", mock.MatchedBy(func(req *http.Request) bool {
+		return req.URL.String() == "https://models.donald.qwak.ai/v1/tagged/predict" &&
+			req.Header.Get(qwakhttp.TagsHeader) == "experiment=new+threshold,team=risk,use_case=retention"
+	})).Return(it.GetHttpReponse(it.GetPredictionResult(), 200), nil).Once()
+
+	// When
+	_, err = client.Predict(qwak.NewPredictionRequest("tagged").
+		WithTag("use_case", "retention").
+		WithTag("experiment", "new threshold").
+		AddFeatureVector(qwak.NewFeatureVector().WithFeature("State", "PPP")))
+
+	// Then
+	require.NoError(s.T(), err)
+	s.HttpMock.Mock.AssertExpectations(s.T())
+}
+
+func (s *IntegrationTestSuite) TestBytesFeatures() {
+	// Given
+	server := qwaktest.NewServer(qwaktest.ServerOptions{}).
+		HandleModel("thumbnails", func(rows []map[string]interface{}) ([]map[string]interface{}, error) {
+			return []map[string]interface{}{{"thumbnail": rows[0]["image"]}}, nil
+		})
+	defer server.Close()
+
+	var binaryFeaturesHeader string
+	config := server.ClientConfig()
+	httpClient := config.HttpClient
+	config.HttpClient = clientFunc(func(request *http.Request) (*http.Response, error) {
+		if strings.HasSuffix(request.URL.Path, "/predict") {
+			binaryFeaturesHeader = request.Header.Get(qwakhttp.BinaryFeaturesHeader)
+		}
+		return httpClient.Do(request)
+	})
+	client, err := qwak.NewRealTimeClient(config)
+	require.NoError(s.T(), err)
+
+	image := []byte{0x89, 'P', 'N', 'G', 0x00, 0xff}
+
+	// When
+	response, err := client.Predict(qwak.NewPredictionRequest("thumbnails").AddFeatureVector(
+		qwak.NewFeatureVector().WithBytesFeature("image", image).WithFeature("width", 64),
+	))
+
+	// Then
+	require.NoError(s.T(), err)
+	s.Assert().Equal("image", binaryFeaturesHeader)
+	thumbnail, err := response.GetSinglePrediction().GetValueAsBytes("thumbnail")
+	s.Assert().NoError(err)
+	s.Assert().Equal(image, thumbnail)
+}
+
+func (s *IntegrationTestSuite) TestConfigureModel() {
+	// Given
+	server := qwaktest.NewServer(qwaktest.ServerOptions{PredictLatency: 200 * time.Millisecond}).
+		RespondWith("otf", map[string]interface{}{"churn": 1}).
+		RespondWith("slow", map[string]interface{}{"churn": 1})
+	defer server.Close()
+
+	var lock sync.Mutex
+	headers := map[string]http.Header{}
+	config := server.ClientConfig()
+	httpClient := config.HttpClient
+	config.HttpClient = clientFunc(func(request *http.Request) (*http.Response, error) {
+		lock.Lock()
+		headers[request.URL.Path] = request.Header.Clone()
+		lock.Unlock()
+		return httpClient.Do(request)
+	})
+	client, err := qwak.NewRealTimeClient(config)
+	require.NoError(s.T(), err)
+
+	require.NoError(s.T(), client.ConfigureModel("otf", qwak.ModelConfig{
+		Headers:   map[string]string{"X-Team": "risk"},
+		Variation: "shadow",
+	}))
+	require.NoError(s.T(), client.ConfigureModel("slow", qwak.ModelConfig{Timeout: 50 * time.Millisecond}))
+	s.Assert().Error(client.ConfigureModel("", qwak.ModelConfig{}))
+
+	newRequest := func(modelId string) *qwak.PredictionRequest {
+		return qwak.NewPredictionRequest(modelId).AddFeatureVector(qwak.NewFeatureVector().WithFeature("State", "PPP"))
+	}
+
+	// When
+	_, err = client.Predict(newRequest("otf"))
+	_, slowErr := client.Predict(newRequest("slow"))
+
+	// Then
+	s.Assert().NoError(err)
+	s.Assert().ErrorIs(slowErr, context.DeadlineExceeded)
+	s.Assert().Equal("risk", headers["/v1/otf/predict"].Get("X-Team"))
+	s.Assert().Equal("shadow", headers["/v1/otf/predict"].Get(qwakhttp.VariationHeader))
+	s.Assert().Empty(headers["/v1/slow/predict"].Get("X-Team"))
+}
+
+func (s *IntegrationTestSuite) TestConcurrencyLimit() {
+	// Given
+	server := qwaktest.NewServer(qwaktest.ServerOptions{PredictLatency: 300 * time.Millisecond}).
+		RespondWith("otf", map[string]interface{}{"churn": 1})
+	defer server.Close()
+
+	config := server.ClientConfig()
+	config.ConcurrencyLimit = qwak.ConcurrencyLimit{MaxInFlight: 1, MaxQueued: -1}
+	client, err := qwak.NewRealTimeClient(config)
+	require.NoError(s.T(), err)
+
+	newRequest := func(value int) *qwak.PredictionRequest {
+		return qwak.NewPredictionRequest("otf").AddFeatureVector(qwak.NewFeatureVector().WithFeature("Account_Length", value))
+	}
+
+	// When
+	firstErr := make(chan error, 1)
+	go func() {
+		_, err := client.Predict(newRequest(1))
+		firstErr <- err
+	}()
+	time.Sleep(100 * time.Millisecond)
+	_, rejectedErr := client.Predict(newRequest(2))
+
+	// Then
+	s.Assert().NoError(<-firstErr)
+	s.Assert().ErrorIs(rejectedErr, qwak.ErrConcurrencyLimitExceeded)
+	s.Assert().Equal(1, server.PredictRequests("otf"))
+
+	_, err = client.Predict(newRequest(3))
+	s.Assert().NoError(err)
+}
+
+type clientFunc func(request *http.Request) (*http.Response, error)
+
+func (f clientFunc) Do(request *http.Request) (*http.Response, error) {
+	return f(request)
+}
+
+func (s *IntegrationTestSuite) TestPerTryTimeout() {
+	// Given
+	predictAttempts := 0
+	policy := qwakhttp.BasicExponentialBackoffRetryPolicy()
+	policy.PerTryTimeout = 200 * time.Millisecond
+
+	client, err := qwak.NewRealTimeClient(qwak.RealTimeClientConfig{
+		ApiKey:      s.ApiKey,
+		Environment: "donald",
+		RetryPolicy: policy,
+		HttpClient: clientFunc(func(request *http.Request) (*http.Response, error) {
+			if request.URL.String() == qwakhttp.DefaultAuthEndpointUri {
+				return it.GetHttpReponse(it.GetAuthResponseWithLongExpiration(), 200), nil
+			}
+			predictAttempts++
+			if predictAttempts == 1 {
+				<-request.Context().Done()
+				return nil, request.Context().Err()
+			}
+			return it.GetHttpReponse(it.GetPredictionResult(), 200), nil
+		}),
+	})
+	require.NoError(s.T(), err)
+
+	// When
+	ctx, cancelFunc := context.WithTimeout(context.Background(), 2*time.Second)
+	defer cancelFunc()
+	_, err = client.PredictWithCtx(ctx, qwak.NewPredictionRequest("otf").AddFeatureVector(
+		qwak.NewFeatureVector().WithFeature("State", "PPP"),
+	))
+
+	// Then
+	require.NoError(s.T(), err)
+	s.Assert().Equal(2, predictAttempts)
+}
+
 func (s *IntegrationTestSuite) TestContextDeadlineExceeded() {
 	// Given
 	s.givenQwakClientWithMockedHttpClientWithRetryPolicy()
@@ -448,6 +759,34 @@ func (s *IntegrationTestSuite) TestContextDeadlineExceeded() {
 	s.HttpMock.Mock.AssertExpectations(s.T())
 }
 
+func (s *IntegrationTestSuite) TestRetryStopsWhenDeadlineBudgetExhausted() {
+	// Given
+	s.givenQwakClientWithMockedHttpClientWithRetryPolicy()
+
+	s.HttpMock.On("Do", mock.MatchedBy(func(req *http.Request) bool {
+		return req.URL.String() == qwakhttp.DefaultAuthEndpointUri
+	})).Return(it.GetHttpReponse(it.GetAuthResponseWithLongExpiration(), 200), nil).Once()
+
+	s.HttpMock.On("Do", mock.MatchedBy(func(req *http.Request) bool {
+		return req.URL.String() == "https://models.donald.qwak.ai/v1/otf/predict" &&
+			req.Header.Get("authorization") == "Bearer jwt-token"
+	})).Return(it.GetHttpReponse(it.GetPredictionResult(), 503), nil).Once().After(600 * time.Millisecond)
+
+	// When
+	predictionRequest := qwak.NewPredictionRequest("otf").AddFeatureVector(
+		qwak.NewFeatureVector().
+			WithFeature("State", "PPP"),
+	)
+	ctx, cancelFunc := context.WithTimeout(context.Background(), time.Second)
+	defer cancelFunc()
+	_, err := s.realTimeClient.PredictWithCtx(ctx, predictionRequest)
+
+	// Then
+	require.Error(s.T(), err)
+	s.Assert().True(errors.Is(err, qwakhttp.ErrDeadlineBudgetExhausted))
+	s.HttpMock.Mock.AssertExpectations(s.T())
+}
+
 func (s *IntegrationTestSuite) TestAuthFailed() {
 	// Given
 	s.givenQwakClientWithMockedHttpClient()
@@ -466,38 +805,1523 @@ func (s *IntegrationTestSuite) TestAuthFailed() {
 
 	// Then
 	s.Assert().NotEqual(nil, err)
+	tokenInfo := s.realTimeClient.TokenInfo()
+	s.Assert().False(tokenInfo.HasToken)
+	s.Assert().Error(tokenInfo.LastRenewalError)
 	s.HttpMock.Mock.AssertExpectations(s.T())
 }
 
-func (s *IntegrationTestSuite) givenQwakClientWithMockedHttpClient() {
+func (s *IntegrationTestSuite) TestTokenInfo() {
+	// Given
+	server := qwaktest.NewServer(qwaktest.ServerOptions{TokenTTL: 3 * time.Hour}).
+		RespondWith("otf", map[string]interface{}{"churn": 1})
+	defer server.Close()
+
+	client, err := qwak.NewRealTimeClient(server.ClientConfig())
+	require.NoError(s.T(), err)
+
+	// When
+	_, err = client.Predict(qwak.NewPredictionRequest("otf").AddFeatureVector(
+		qwak.NewFeatureVector().WithFeature("State", "PPP"),
+	))
+
+	// Then
+	require.NoError(s.T(), err)
+	tokenInfo := client.TokenInfo()
+	s.Assert().True(tokenInfo.HasToken)
+	s.Assert().NoError(tokenInfo.LastRenewalError)
+	s.Assert().WithinDuration(time.Now().Add(3*time.Hour), tokenInfo.ExpiresAt, 5*time.Second)
+	s.Assert().True(tokenInfo.RefreshIn > 2*time.Hour)
+}
+
+func (s *IntegrationTestSuite) TestCredentialsChain() {
+	// Given
+	server := qwaktest.NewServer(qwaktest.ServerOptions{ApiKey: "env-key"}).
+		RespondWith("otf", map[string]interface{}{"churn": 1})
+	defer server.Close()
+
+	s.T().Setenv(authentication.CredentialsFileEnvVariable, filepath.Join(s.T().TempDir(), "missing"))
+	s.T().Setenv(authentication.ApiKeyEnvVariable, "")
+	config := server.ClientConfig()
+	config.ApiKey = ""
+	_, missingErr := qwak.NewRealTimeClient(config)
+
+	s.T().Setenv(authentication.ApiKeyEnvVariable, "env-key")
+	client, err := qwak.NewRealTimeClient(config)
+	require.NoError(s.T(), err)
+
+	// When
+	_, err = client.Predict(qwak.NewPredictionRequest("otf").AddFeatureVector(
+		qwak.NewFeatureVector().WithFeature("State", "PPP"),
+	))
+
+	// Then
+	require.NoError(s.T(), err)
+	s.Assert().ErrorIs(missingErr, authentication.ErrNoCredentials)
+	s.Assert().Contains(missingErr.Error(), "environment variable QWAK_API_KEY is not set")
+	s.Assert().Equal(1, server.AuthRequests())
+}
 
+func (s *IntegrationTestSuite) TestBearerToken() {
+	// Given
 	client, err := qwak.NewRealTimeClient(qwak.RealTimeClientConfig{
-		ApiKey:      s.ApiKey,
+		BearerToken: "gateway-token",
 		Environment: "donald",
-		Context:     s.ctx,
 		HttpClient:  &s.HttpMock,
 	})
+	require.NoError(s.T(), err)
 
-	if err != nil {
-		s.Assert().Fail("client init failed", err)
-	}
+	s.HttpMock.On("Do", mock.MatchedBy(func(req *http.Request) bool {
+		return req.URL.String() == "https://models.donald.qwak.ai/v1/bearer/predict" &&
+			req.Header.Get("authorization") == "Bearer gateway-token"
+	})).Return(it.GetHttpReponse(it.GetPredictionResult(), 200), nil).Once()
 
-	s.realTimeClient = client
+	// When
+	_, err = client.Predict(qwak.NewPredictionRequest("bearer").AddFeatureVector(
+		qwak.NewFeatureVector().WithFeature("State", "PPP"),
+	))
+
+	// Then
+	require.NoError(s.T(), err)
+	s.Assert().False(client.TokenInfo().HasToken)
+	s.HttpMock.Mock.AssertExpectations(s.T())
+
+	_, err = qwak.NewRealTimeClient(qwak.RealTimeClientConfig{
+		BearerToken: "gateway-token",
+		TokenSource: authentication.StaticToken("other"),
+		Environment: "donald",
+	})
+	s.Assert().Error(err)
 }
 
-func (s *IntegrationTestSuite) givenQwakClientWithMockedHttpClientWithRetryPolicy() {
+func (s *IntegrationTestSuite) TestLocalModelWithoutAuthentication() {
+	// Given
+	var authorization atomic.Value
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		authorization.Store(r.Header.Get("authorization"))
+		if r.URL.Path != qwak.LocalPredictionPath {
+			w.WriteHeader(http.StatusNotFound)
+			return
+		}
+		_, _ = w.Write([]byte(`[{"churn": 1}]`))
+	}))
+	defer server.Close()
+
+	client, err := qwak.NewRealTimeClient(qwak.NewLocalClientConfig(server.URL))
+	require.NoError(s.T(), err)
+
+	// When
+	response, err := client.Predict(qwak.NewPredictionRequest("local").AddFeatureVector(
+		qwak.NewFeatureVector().WithFeature("State", "PPP"),
+	))
+
+	// Then
+	require.NoError(s.T(), err)
+	value, err := response.GetSinglePrediction().GetValueAsInt("churn")
+	s.Assert().NoError(err)
+	s.Assert().Equal(1, value)
+	s.Assert().Equal("", authorization.Load())
+
+	// Credentials are rejected along with disabled authentication
+	withApiKey := qwak.NewLocalClientConfig(server.URL)
+	withApiKey.ApiKey = "key"
+	_, err = qwak.NewRealTimeClient(withApiKey)
+	s.Assert().EqualError(err, "disabled authentication and credentials are mutually exclusive")
+
+	withBearerToken := qwak.NewLocalClientConfig(server.URL)
+	withBearerToken.BearerToken = "token"
+	_, err = qwak.NewRealTimeClient(withBearerToken)
+	s.Assert().EqualError(err, "disabled authentication and credentials are mutually exclusive")
+}
+
+func (s *IntegrationTestSuite) TestDryRun() {
+	// Given
+	httpMock := &it.HttpClientMock{}
+	client, err := qwak.NewRealTimeClient(qwak.RealTimeClientConfig{
+		ApiKey:          s.ApiKey,
+		Environment:     "donald",
+		HttpClient:      httpMock,
+		Tags:            map[string]string{"team": "growth"},
+		MaxRequestBytes: 200,
+	})
+	require.NoError(s.T(), err)
+
+	predictionRequest := qwak.NewPredictionRequest("otf").AddFeatureVector(
+		qwak.NewFeatureVector().WithFeature("State", "PPP").WithFeature("Account_Length", 82),
+	)
+
+	// When
+	result, err := client.DryRun(s.ctx, predictionRequest)
+	_, invalidErr := client.DryRun(s.ctx, qwak.NewPredictionRequest("otf"))
+	_, tooLargeErr := client.DryRun(s.ctx, qwak.NewPredictionRequest("otf").AddFeatureVector(
+		qwak.NewFeatureVector().WithFeature("State", strings.Repeat("P", 200)),
+	))
+
+	// Then
+	require.NoError(s.T(), err)
+	s.Assert().Equal("https://models.donald.qwak.ai/v1/otf/predict", result.Url)
+	s.Assert().Equal("Bearer "+qwak.RedactedToken, result.Header.Get("authorization"))
+	s.Assert().Equal("team=growth", result.Header.Get(qwakhttp.TagsHeader))
+	s.Assert().JSONEq(`{"columns":["State","Account_Length"],"index":[0],"data":[["PPP",82]]}`, string(result.Body))
+
+	var validationErr *qwak.RequestValidationError
+	s.Assert().ErrorAs(invalidErr, &validationErr)
+	s.Assert().ErrorIs(tooLargeErr, qwak.ErrRequestTooLarge)
+	httpMock.AssertNotCalled(s.T(), "Do", mock.Anything)
+}
 
+func (s *IntegrationTestSuite) TestDebugDump() {
+	// Given
 	client, err := qwak.NewRealTimeClient(qwak.RealTimeClientConfig{
 		ApiKey:      s.ApiKey,
-		RetryPolicy: qwakhttp.BasicExponentialBackoffRetryPolicy(),
 		Environment: "donald",
-		Context:     s.ctx,
-		HttpClient:  &s.HttpMock,
+		HttpClient:  &it.HttpClientMock{},
 	})
+	require.NoError(s.T(), err)
 
-	if err != nil {
-		s.Assert().Fail("client init failed", err)
+	// When
+	dump, err := client.DebugDump(s.ctx, qwak.NewPredictionRequest("otf").AddFeatureVector(
+		qwak.NewFeatureVector().WithFeature("State", "O'Hare"),
+	))
+
+	// Then
+	require.NoError(s.T(), err)
+	s.Assert().True(strings.HasPrefix(dump, "POST https://models.donald.qwak.ai/v1/otf/predict\n"))
+	s.Assert().Contains(dump, "Authorization: Bearer REDACTED\n")
+	s.Assert().NotContains(dump, s.ApiKey)
+	s.Assert().Contains(dump, `curl -X POST 'https://models.donald.qwak.ai/v1/otf/predict' \`)
+	s.Assert().Contains(dump, `-H 'Authorization: Bearer REDACTED' \`)
+	s.Assert().Contains(dump, `--data-raw '{"columns":["State"],"index":[0],"data":[["O'\''Hare"]]}'`)
+}
+
+func (s *IntegrationTestSuite) TestResponseMeta() {
+	// Given
+	var calls int32
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if atomic.AddInt32(&calls, 1) == 1 {
+			w.WriteHeader(http.StatusServiceUnavailable)
+			return
+		}
+		w.Header().Set("X-Request-Id", "req-42")
+		w.Header().Set("Server-Timing", `inference;dur=12.5, queue;desc="Queue wait";dur=3`)
+		_, _ = w.Write([]byte(`[{"churn": 1}]`))
+	}))
+	defer server.Close()
+
+	client, err := qwak.NewRealTimeClient(qwak.RealTimeClientConfig{
+		BearerToken: "token",
+		Url:         server.URL,
+		RetryPolicy: qwakhttp.RetryPolicy{MaxAttempts: 2, IntervalMs: 1},
+	})
+	require.NoError(s.T(), err)
+
+	// When
+	response, err := client.Predict(qwak.NewPredictionRequest("otf").AddFeatureVector(
+		qwak.NewFeatureVector().WithFeature("State", "PPP"),
+	))
+
+	// Then
+	require.NoError(s.T(), err)
+	meta := response.Meta()
+	s.Assert().Equal("otf", meta.ModelId)
+	s.Assert().Equal(2, meta.Attempts)
+	s.Assert().Equal(200, meta.StatusCode)
+	s.Assert().Equal("req-42", meta.RequestId)
+	s.Assert().True(meta.Latency > 0)
+	s.Assert().Equal([]qwak.ServerTimingMetric{
+		{Name: "inference", Duration: 12500 * time.Microsecond},
+		{Name: "queue", Duration: 3 * time.Millisecond, Description: "Queue wait"},
+	}, meta.ServerTiming)
+}
+
+func (s *IntegrationTestSuite) TestRoundTripper() {
+	// Given
+	server := qwaktest.NewServer(qwaktest.ServerOptions{}).
+		RespondWith("otf", map[string]interface{}{"churn": 1})
+	defer server.Close()
+
+	var replacedCalls, wrappedCalls int32
+	countingTransport := func(counter *int32, next http.RoundTripper) http.RoundTripper {
+		return roundTripperFunc(func(request *http.Request) (*http.Response, error) {
+			atomic.AddInt32(counter, 1)
+			return next.RoundTrip(request)
+		})
 	}
 
-	s.realTimeClient = client
+	replacedConfig := server.ClientConfig()
+	replacedConfig.HttpClient = nil
+	replacedConfig.RoundTripper = countingTransport(&replacedCalls, http.DefaultTransport)
+	replaced, err := qwak.NewRealTimeClient(replacedConfig)
+	require.NoError(s.T(), err)
+
+	wrappedConfig := server.ClientConfig()
+	wrappedConfig.HttpClient = nil
+	wrappedConfig.Transport.WrapRoundTripper = func(transport http.RoundTripper) http.RoundTripper {
+		return countingTransport(&wrappedCalls, transport)
+	}
+	wrapped, err := qwak.NewRealTimeClient(wrappedConfig)
+	require.NoError(s.T(), err)
+
+	// When
+	predictionRequest := qwak.NewPredictionRequest("otf").AddFeatureVector(
+		qwak.NewFeatureVector().WithFeature("State", "PPP"),
+	)
+	_, replacedErr := replaced.Predict(predictionRequest)
+	_, wrappedErr := wrapped.Predict(predictionRequest)
+
+	// Then
+	require.NoError(s.T(), replacedErr)
+	require.NoError(s.T(), wrappedErr)
+	s.Assert().Equal(int32(2), atomic.LoadInt32(&replacedCalls), "authentication and prediction")
+	s.Assert().Equal(int32(2), atomic.LoadInt32(&wrappedCalls), "authentication and prediction")
+}
+
+type roundTripperFunc func(request *http.Request) (*http.Response, error)
+
+func (f roundTripperFunc) RoundTrip(request *http.Request) (*http.Response, error) {
+	return f(request)
+}
+
+func (s *IntegrationTestSuite) TestRequestHooks() {
+	// Given
+	var calls int32
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if atomic.AddInt32(&calls, 1) == 1 {
+			w.WriteHeader(http.StatusBadGateway)
+			return
+		}
+		_, _ = w.Write([]byte(`[{"churn": 1}]`))
+	}))
+	defer server.Close()
+
+	var lock sync.Mutex
+	var events []string
+	record := func(kind string) func(event qwakhttp.RequestEvent) {
+		return func(event qwakhttp.RequestEvent) {
+			lock.Lock()
+			defer lock.Unlock()
+			events = append(events, fmt.Sprintf("%s attempt=%d status=%d failed=%t", kind, event.Attempt, event.StatusCode, event.Err != nil))
+		}
+	}
+
+	client, err := qwak.NewRealTimeClient(qwak.RealTimeClientConfig{
+		BearerToken: "token",
+		Url:         server.URL,
+		RetryPolicy: qwakhttp.RetryPolicy{MaxAttempts: 2, IntervalMs: 1},
+		RequestHooks: qwakhttp.RequestHooks{
+			OnRequest:  record("request"),
+			OnRetry:    record("retry"),
+			OnResponse: record("response"),
+		},
+	})
+	require.NoError(s.T(), err)
+
+	// When
+	_, err = client.Predict(qwak.NewPredictionRequest("otf").AddFeatureVector(
+		qwak.NewFeatureVector().WithFeature("State", "PPP"),
+	))
+
+	// Then
+	require.NoError(s.T(), err)
+	s.Assert().Equal([]string{
+		"request attempt=0 status=0 failed=false",
+		"retry attempt=0 status=502 failed=true",
+		"request attempt=1 status=0 failed=false",
+		"response attempt=2 status=200 failed=false",
+	}, events)
+}
+
+func (s *IntegrationTestSuite) TestFakeClock() {
+	// Given
+	clock := qwaktest.NewFakeClock(time.Now())
+	var calls int32
+	server := qwaktest.NewServer(qwaktest.ServerOptions{TokenTTL: 3 * time.Hour, Clock: clock}).
+		HandleModel("otf", func(rows []map[string]interface{}) ([]map[string]interface{}, error) {
+			if atomic.AddInt32(&calls, 1) <= 2 {
+				return nil, errors.New("warming up")
+			}
+			return []map[string]interface{}{{"churn": 1}}, nil
+		})
+	defer server.Close()
+
+	config := server.ClientConfig()
+	config.Clock = clock
+	config.RetryPolicy = qwakhttp.RetryPolicy{MaxAttempts: 3, ExponentialBackoffFactor: 2}
+	client, err := qwak.NewRealTimeClient(config)
+	require.NoError(s.T(), err)
+
+	predictionRequest := qwak.NewPredictionRequest("otf").AddFeatureVector(
+		qwak.NewFeatureVector().WithFeature("State", "PPP"),
+	)
+
+	// When
+	_, err = client.Predict(predictionRequest)
+	require.NoError(s.T(), err)
+	refreshIn := client.TokenInfo().RefreshIn
+	clock.Advance(3 * time.Hour)
+	_, err = client.Predict(predictionRequest)
+
+	// Then
+	require.NoError(s.T(), err)
+	s.Assert().Equal([]time.Duration{4 * time.Millisecond, 16 * time.Millisecond}, clock.Waits())
+	s.Assert().InDelta(float64(150*time.Minute), float64(refreshIn), float64(time.Second))
+	s.Assert().Equal(2, server.AuthRequests())
+}
+
+func (s *IntegrationTestSuite) TestHttpClientMock() {
+	// Given
+	httpMock := &qwaktest.HttpClientMock{}
+	httpMock.OnAuthenticate("mocked-token", 3*time.Hour)
+	httpMock.OnPredict("https://models.donald.qwak.ai/v1/otf/predict", it.GetPredictionResult(), 200)
+	httpMock.On("Do", qwaktest.RequestTo("https://models.donald.qwak.ai/v1/broken/predict")).
+		Return(nil, errors.New("connection reset")).Once()
+
+	client, err := qwak.NewRealTimeClient(qwak.RealTimeClientConfig{
+		ApiKey:      s.ApiKey,
+		Environment: "donald",
+		HttpClient:  httpMock,
+	})
+	require.NoError(s.T(), err)
+
+	// When
+	response, err := client.Predict(qwak.NewPredictionRequest("otf").AddFeatureVector(
+		qwak.NewFeatureVector().WithFeature("State", "PPP"),
+	))
+	_, brokenErr := client.Predict(qwak.NewPredictionRequest("broken").AddFeatureVector(
+		qwak.NewFeatureVector().WithFeature("State", "PPP"),
+	))
+
+	// Then
+	require.NoError(s.T(), err)
+	s.Assert().Len(response.GetPredictions(), 1)
+	s.Assert().Contains(brokenErr.Error(), "connection reset")
+	httpMock.AssertExpectations(s.T())
+}
+
+func (s *IntegrationTestSuite) TestStrictDecoding() {
+	// Given
+	httpMock := &qwaktest.HttpClientMock{}
+	httpMock.On("Do", qwaktest.RequestTo(qwakhttp.DefaultAuthEndpointUri)).Return(qwaktest.NewHttpResponse(
+		`{"accessToken":"jwt-token","expiredAt":4102444800,"debug":true}`, 200), nil).Once()
+	httpMock.OnAuthenticate("jwt-token", 3*time.Hour)
+	httpMock.OnPredict("https://models.donald.qwak.ai/v1/otf/predict", "null", 200)
+	httpMock.OnPredict("https://models.donald.qwak.ai/v1/otf/predict", "<html><title>Gateway Timeout</title></html>", 200)
+
+	client, err := qwak.NewRealTimeClient(qwak.RealTimeClientConfig{
+		ApiKey:         s.ApiKey,
+		Environment:    "donald",
+		HttpClient:     httpMock,
+		StrictDecoding: true,
+	})
+	require.NoError(s.T(), err)
+	predictionRequest := qwak.NewPredictionRequest("otf").AddFeatureVector(
+		qwak.NewFeatureVector().WithFeature("State", "PPP"),
+	)
+
+	// When
+	_, unknownFieldErr := client.Predict(predictionRequest)
+	_, nullErr := client.Predict(predictionRequest)
+	_, htmlErr := client.Predict(predictionRequest)
+
+	// Then
+	s.Assert().Contains(unknownFieldErr.Error(), `unknown field "debug"`)
+	s.Assert().Contains(nullErr.Error(), "response is json null, not an array of objects")
+	s.Assert().Contains(htmlErr.Error(), "response is an html page titled 'Gateway Timeout', not json")
+	httpMock.AssertExpectations(s.T())
+}
+
+func (s *IntegrationTestSuite) TestFakeRealTimeClient() {
+	// Given
+	fake := qwaktest.NewFakeRealTimeClient().
+		RespondWith("otf", qwaktest.MustPredictionResponse(map[string]interface{}{"churn": 1})).
+		EnqueueError("otf", errors.New("model is down"))
+
+	var predictor qwak.Predictor = fake
+	predictionRequest := qwak.NewPredictionRequest("otf").AddFeatureVector(
+		qwak.NewFeatureVector().
+			WithFeature("State", "PPP"),
+	)
+
+	// When
+	_, firstErr := predictor.Predict(predictionRequest)
+	response, secondErr := predictor.Predict(predictionRequest)
+	_, unknownModelErr := predictor.Predict(qwak.NewPredictionRequest("unknown"))
+
+	// Then
+	s.Assert().EqualError(firstErr, "model is down")
+	require.NoError(s.T(), secondErr)
+	value, err := response.GetSinglePrediction().GetValueAsInt("churn")
+	s.Assert().NoError(err)
+	s.Assert().Equal(1, value)
+	s.Assert().Error(unknownModelErr)
+
+	calls := fake.CallsForModel("otf")
+	s.Assert().Len(calls, 2)
+	state, ok := calls[0].Request.GetFeatureVectors()[0].GetFeature("State")
+	s.Assert().True(ok)
+	s.Assert().Equal("PPP", state)
+}
+
+func (s *IntegrationTestSuite) TestFakeServer() {
+	// Given
+	server := qwaktest.NewServer(qwaktest.ServerOptions{ApiKey: "local-key"}).
+		HandleModel("otf", func(rows []map[string]interface{}) ([]map[string]interface{}, error) {
+			outputs := make([]map[string]interface{}, len(rows))
+			for idx, row := range rows {
+				outputs[idx] = map[string]interface{}{"churn": row["Account_Length"]}
+			}
+			return outputs, nil
+		})
+	defer server.Close()
+
+	client, err := qwak.NewRealTimeClient(server.ClientConfig())
+	require.NoError(s.T(), err)
+
+	// When
+	predictionRequest := qwak.NewPredictionRequest("otf").AddFeatureVectors(
+		qwak.NewFeatureVector().WithFeature("Account_Length", 82),
+		qwak.NewFeatureVector().WithFeature("Account_Length", 12),
+	)
+	response, err := client.Predict(predictionRequest)
+	_, missingModelErr := client.Predict(qwak.NewPredictionRequest("missing"))
+
+	// Then
+	require.NoError(s.T(), err)
+	s.Assert().Len(response.GetPredictions(), 2)
+	value, err := response.GetPredictions()[1].GetValueAsInt("churn")
+	s.Assert().NoError(err)
+	s.Assert().Equal(12, value)
+	s.Assert().Error(missingModelErr)
+	s.Assert().Equal(1, server.AuthRequests())
+	s.Assert().Equal(1, server.PredictRequests("otf"))
+}
+
+func (s *IntegrationTestSuite) TestEnvironmentNamesAccepted() {
+	for _, environment := range []string{"my_env", "Prod Env"} {
+		_, err := qwak.NewRealTimeClient(qwak.RealTimeClientConfig{ApiKey: s.ApiKey, Environment: environment})
+		s.Assert().NoError(err, environment)
+	}
+
+	_, err := qwak.NewRealTimeClient(qwak.RealTimeClientConfig{
+		ApiKey:          s.ApiKey,
+		Environment:     "donald",
+		BaseUrlTemplate: "ftp://%s.serving.internal.corp",
+	})
+	s.Assert().EqualError(err, "base url template is not valid")
+}
+
+func (s *IntegrationTestSuite) TestPredictWithUrlTemplates() {
+	// Given
+	client, err := qwak.NewRealTimeClient(qwak.RealTimeClientConfig{
+		ApiKey:          s.ApiKey,
+		Environment:     "donald",
+		BaseUrlTemplate: "https://%s.serving.internal.corp",
+		PathUrlTemplate: "/models/%s/invocations",
+		HttpClient:      &s.HttpMock,
+	})
+	require.NoError(s.T(), err)
+
+	resolvedClient, err := qwak.NewRealTimeClient(qwak.RealTimeClientConfig{
+		ApiKey: s.ApiKey,
+		UrlResolver: func(environment string, modelId string) string {
+			return "https://private-link.internal.corp/" + modelId
+		},
+		HttpClient: &s.HttpMock,
+	})
+	require.NoError(s.T(), err)
+
+	for i := 0; i < 2; i++ {
+		s.HttpMock.On("Do", mock.MatchedBy(func(req *http.Request) bool {
+			return req.URL.String() == qwakhttp.DefaultAuthEndpointUri
+		})).Return(it.GetHttpReponse(it.GetAuthResponseWithLongExpiration(), 200), nil).Once()
+	}
+
+	s.HttpMock.On("Do", mock.MatchedBy(func(req *http.Request) bool {
+		return req.URL.String() == "https://donald.serving.internal.corp/models/otf/invocations"
+	})).Return(it.GetHttpReponse(it.GetPredictionResult(), 200), nil).Once()
+
+	s.HttpMock.On("Do", mock.MatchedBy(func(req *http.Request) bool {
+		return req.URL.String() == "https://private-link.internal.corp/otf"
+	})).Return(it.GetHttpReponse(it.GetPredictionResult(), 200), nil).Once()
+
+	predictionRequest := qwak.NewPredictionRequest("otf").AddFeatureVector(
+		qwak.NewFeatureVector().WithFeature("State", "PPP"),
+	)
+
+	// When
+	_, err = client.Predict(predictionRequest)
+	_, resolvedErr := resolvedClient.Predict(predictionRequest)
+	_, invalidTemplateErr := qwak.NewRealTimeClient(qwak.RealTimeClientConfig{
+		ApiKey:          s.ApiKey,
+		Environment:     "donald",
+		PathUrlTemplate: "/predict",
+	})
+
+	// Then
+	s.Assert().NoError(err)
+	s.Assert().NoError(resolvedErr)
+	s.Assert().Error(invalidTemplateErr)
+	s.HttpMock.Mock.AssertExpectations(s.T())
+}
+
+func (s *IntegrationTestSuite) TestStrictResponses() {
+	// Given
+	server := qwaktest.NewServer(qwaktest.ServerOptions{}).RespondWith("otf")
+	defer server.Close()
+
+	config := server.ClientConfig()
+	config.StrictResponses = true
+	client, err := qwak.NewRealTimeClient(config)
+	require.NoError(s.T(), err)
+
+	// When
+	response, err := client.Predict(qwak.NewPredictionRequest("otf").AddFeatureVector(
+		qwak.NewFeatureVector().WithFeature("State", "PPP"),
+	))
+
+	// Then
+	s.Assert().Nil(response)
+	s.Assert().ErrorIs(err, qwak.ErrEmptyPrediction)
+}
+
+func (s *IntegrationTestSuite) TestExpectColumns() {
+	// Given
+	server := qwaktest.NewServer(qwaktest.ServerOptions{}).
+		RespondWith("otf", map[string]interface{}{"churn": 1, "score": "high"})
+	defer server.Close()
+
+	client, err := qwak.NewRealTimeClient(server.ClientConfig())
+	require.NoError(s.T(), err)
+
+	newRequest := func() *qwak.PredictionRequest {
+		return qwak.NewPredictionRequest("otf").AddFeatureVector(
+			qwak.NewFeatureVector().WithFeature("State", "PPP"),
+		)
+	}
+
+	// When
+	_, err = client.Predict(newRequest().ExpectColumns("churn", "score"))
+	_, driftErr := client.Predict(newRequest().
+		ExpectColumns("churn").
+		ExpectColumnOfType("score", qwak.NumberColumnType))
+
+	// Then
+	s.Assert().NoError(err)
+	var schemaErr *qwak.SchemaMismatchError
+	s.Require().True(errors.As(driftErr, &schemaErr))
+	s.Assert().Equal("otf", schemaErr.ModelId)
+	s.Assert().Len(schemaErr.MismatchedColumns, 1)
+	s.Assert().Empty(schemaErr.MissingColumns)
+}
+
+type churnFeatures struct {
+	State         string `qwak:"State"`
+	AccountLength int    `qwak:"Account_Length"`
+	Internal      string `qwak:"-"`
+}
+
+type churnPrediction struct {
+	Churn  int      `qwak:"churn"`
+	Score  float64  `json:"score"`
+	Labels []string `qwak:"labels,omitempty"`
+}
+
+func (s *IntegrationTestSuite) TestTypedClient() {
+	// Given
+	server := qwaktest.NewServer(qwaktest.ServerOptions{}).
+		HandleModel("otf", func(rows []map[string]interface{}) ([]map[string]interface{}, error) {
+			outputs := make([]map[string]interface{}, len(rows))
+			for idx, row := range rows {
+				if _, ok := row["Internal"]; ok {
+					return nil, errors.New("skipped field was sent")
+				}
+				outputs[idx] = map[string]interface{}{
+					"churn":  row["Account_Length"],
+					"score":  0.5,
+					"labels": []string{row["State"].(string)},
+				}
+			}
+			return outputs, nil
+		}).
+		RespondWith("drifted", map[string]interface{}{"churn": 1})
+	defer server.Close()
+
+	client, err := qwak.NewTypedClient[churnFeatures, churnPrediction](server.ClientConfig(), "otf")
+	require.NoError(s.T(), err)
+	driftedClient, err := qwak.NewTypedClient[churnFeatures, churnPrediction](server.ClientConfig(), "drifted")
+	require.NoError(s.T(), err)
+
+	inputs := []churnFeatures{{State: "PPP", AccountLength: 82, Internal: "x"}, {State: "NY", AccountLength: 12}}
+
+	// When
+	outputs, err := client.Predict(context.Background(), inputs)
+	_, driftErr := driftedClient.Predict(context.Background(), inputs[:1])
+
+	// Then
+	require.NoError(s.T(), err)
+	s.Assert().Equal([]churnPrediction{
+		{Churn: 82, Score: 0.5, Labels: []string{"PPP"}},
+		{Churn: 12, Score: 0.5, Labels: []string{"NY"}},
+	}, outputs)
+	var schemaErr *qwak.SchemaMismatchError
+	s.Require().True(errors.As(driftErr, &schemaErr))
+	s.Assert().Equal([]string{"score"}, schemaErr.MissingColumns)
+}
+
+func (s *IntegrationTestSuite) TestTypedClientWithFakePredictor() {
+	// Given
+	fixture := qwaktest.MustPredictionResponse(map[string]interface{}{"churn": 1, "score": 0.5})
+	fake := qwaktest.NewFakeRealTimeClient().RespondWith("otf", fixture)
+	client, err := qwak.NewTypedClientWithPredictor[churnFeatures, churnPrediction](fake, "otf")
+	require.NoError(s.T(), err)
+	inputs := []churnFeatures{{State: "PPP", AccountLength: 82}}
+
+	// When
+	first, firstErr := client.Predict(context.Background(), inputs)
+	second, secondErr := client.Predict(context.Background(), inputs)
+
+	// Then the fixture of the fake is not released between calls
+	require.NoError(s.T(), firstErr)
+	require.NoError(s.T(), secondErr)
+	s.Assert().Equal([]churnPrediction{{Churn: 1, Score: 0.5}}, first)
+	s.Assert().Equal(first, second)
+	s.Assert().Len(fixture.GetPredictions(), 1)
+}
+
+func (s *IntegrationTestSuite) TestFallbackOnPredictionFailure() {
+	// Given
+	server := qwaktest.NewServer(qwaktest.ServerOptions{PredictFailureRate: 1}).
+		RespondWith("otf", map[string]interface{}{"churn": 1})
+	defer server.Close()
+
+	var metas []qwak.PredictionMeta
+	config := server.ClientConfig()
+	config.Fallback = qwak.StaticFallback(map[string]interface{}{"churn": 0.0})
+	config.OnPrediction = func(request *qwak.PredictionRequest, response *qwak.PredictionResponse, meta qwak.PredictionMeta) {
+		metas = append(metas, meta)
+	}
+	client, err := qwak.NewRealTimeClient(config)
+	require.NoError(s.T(), err)
+
+	failingConfig := server.ClientConfig()
+	failingConfig.Fallback = func(ctx context.Context, request *qwak.PredictionRequest, err error) (*qwak.PredictionResponse, error) {
+		return nil, nil
+	}
+	failingClient, err := qwak.NewRealTimeClient(failingConfig)
+	require.NoError(s.T(), err)
+
+	predictionRequest := qwak.NewPredictionRequest("otf").AddFeatureVectors(
+		qwak.NewFeatureVector().WithFeature("State", "PPP"),
+		qwak.NewFeatureVector().WithFeature("State", "NY"),
+	)
+
+	// When
+	response, err := client.Predict(predictionRequest)
+	_, failingErr := failingClient.Predict(predictionRequest)
+
+	// Then
+	require.NoError(s.T(), err)
+	s.Assert().Len(response.GetPredictions(), 2)
+	value, err := response.GetPredictions()[1].GetValueAsInt("churn")
+	s.Assert().NoError(err)
+	s.Assert().Equal(0, value)
+	s.Require().Len(metas, 1)
+	s.Assert().True(metas[0].Fallback)
+	s.Assert().Error(failingErr)
+}
+
+func (s *IntegrationTestSuite) TestPredictMany() {
+	// Given
+	fake := qwaktest.NewFakeRealTimeClient().
+		RespondWith("churn", qwaktest.MustPredictionResponse(map[string]interface{}{"churn": 1})).
+		RespondWith("ltv", qwaktest.MustPredictionResponse(map[string]interface{}{"ltv": 120.5})).
+		FailWith("fraud", errors.New("model is down"))
+
+	newVector := func() *qwak.FeatureVector {
+		return qwak.NewFeatureVector().WithFeature("State", "PPP")
+	}
+
+	// When
+	outcomes := qwak.PredictMany(context.Background(), fake, map[string]*qwak.PredictionRequest{
+		"churn":  qwak.NewPredictionRequest("churn").AddFeatureVector(newVector()),
+		"ltv":    qwak.NewPredictionRequest("").AddFeatureVector(newVector()),
+		"fraud":  qwak.NewPredictionRequest("fraud").AddFeatureVector(newVector()),
+		"misuse": qwak.NewPredictionRequest("churn").AddFeatureVector(newVector()),
+	})
+
+	// Then
+	s.Require().Len(outcomes, 4)
+	s.Assert().NoError(outcomes["churn"].Err)
+	s.Assert().NoError(outcomes["ltv"].Err)
+	ltv, err := outcomes["ltv"].Response.GetSinglePrediction().GetValueAsFloat("ltv")
+	s.Assert().NoError(err)
+	s.Assert().Equal(120.5, ltv)
+	s.Assert().EqualError(outcomes["fraud"].Err, "model is down")
+	s.Assert().Error(outcomes["misuse"].Err)
+	s.Assert().Len(fake.CallsForModel("churn"), 1)
+}
+
+func (s *IntegrationTestSuite) TestSplitClient() {
+	// Given
+	fake := qwaktest.NewFakeRealTimeClient().
+		RespondWith("churn-v1", qwaktest.MustPredictionResponse(map[string]interface{}{"churn": 1})).
+		RespondWith("churn-v2", qwaktest.MustPredictionResponse(map[string]interface{}{"churn": 0}))
+
+	routes := map[qwak.SplitBranch]int{}
+	split, err := qwak.NewSplitClient(fake, qwak.SplitOptions{
+		ControlModelId:   "churn-v1",
+		TreatmentModelId: "churn-v2",
+		TreatmentPercent: 20,
+		Salt:             "churn-experiment",
+		OnRoute: func(key string, branch qwak.SplitBranch, request *qwak.PredictionRequest) {
+			routes[branch]++
+		},
+	})
+	require.NoError(s.T(), err)
+
+	// When
+	for i := 0; i < 1000; i++ {
+		key := fmt.Sprintf("user-%d", i)
+		_, branch, err := split.Predict(context.Background(), key, qwak.NewPredictionRequest("churn").AddFeatureVector(
+			qwak.NewFeatureVector().WithFeature("State", "PPP"),
+		))
+		s.Require().NoError(err)
+		s.Require().Equal(split.Branch(key), branch)
+	}
+
+	// Then
+	s.Assert().InDelta(200, routes[qwak.TreatmentBranch], 50)
+	s.Assert().Equal(routes[qwak.TreatmentBranch], len(fake.CallsForModel("churn-v2")))
+	s.Assert().Equal(routes[qwak.ControlBranch], len(fake.CallsForModel("churn-v1")))
+	s.Assert().Empty(fake.CallsForModel("churn"))
+
+	_, err = qwak.NewSplitClient(fake, qwak.SplitOptions{ControlModelId: "a", TreatmentModelId: "b", TreatmentPercent: 101})
+	s.Assert().Error(err)
+}
+
+func (s *IntegrationTestSuite) TestFailoverToSecondaryUrl() {
+	// Given
+	var primaryRequests int32
+	primary := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		atomic.AddInt32(&primaryRequests, 1)
+		http.Error(w, "region is down", http.StatusServiceUnavailable)
+	}))
+	defer primary.Close()
+
+	secondary := qwaktest.NewServer(qwaktest.ServerOptions{}).
+		RespondWith("otf", map[string]interface{}{"churn": 1})
+	defer secondary.Close()
+
+	config := secondary.ClientConfig()
+	config.Url = primary.URL
+	config.FailoverUrls = []string{secondary.URL()}
+	config.FailoverCooldown = time.Minute
+	client, err := qwak.NewRealTimeClient(config)
+	require.NoError(s.T(), err)
+
+	predictionRequest := qwak.NewPredictionRequest("otf").AddFeatureVector(
+		qwak.NewFeatureVector().WithFeature("State", "PPP"),
+	)
+
+	// When
+	firstResponse, firstErr := client.Predict(predictionRequest)
+	secondResponse, secondErr := client.Predict(predictionRequest)
+
+	// Then
+	require.NoError(s.T(), firstErr)
+	require.NoError(s.T(), secondErr)
+	s.Assert().Len(firstResponse.GetPredictions(), 1)
+	s.Assert().Len(secondResponse.GetPredictions(), 1)
+	s.Assert().Equal(int32(1), atomic.LoadInt32(&primaryRequests))
+	s.Assert().Equal(2, secondary.PredictRequests("otf"))
+}
+
+func (s *IntegrationTestSuite) TestRecordAndReplay() {
+	// Given
+	server := qwaktest.NewServer(qwaktest.ServerOptions{ApiKey: "secret-key"}).
+		RespondWith("otf", map[string]interface{}{"churn": 1})
+
+	config := server.ClientConfig()
+	recorder := qwaktest.NewRecorder(config.HttpClient)
+	config.HttpClient = recorder
+	recordingClient, err := qwak.NewRealTimeClient(config)
+	require.NoError(s.T(), err)
+
+	predictionRequest := qwak.NewPredictionRequest("otf").AddFeatureVector(
+		qwak.NewFeatureVector().WithFeature("State", "PPP"),
+	)
+	_, err = recordingClient.Predict(predictionRequest)
+	require.NoError(s.T(), err)
+	server.Close()
+
+	cassettePath := filepath.Join(s.T().TempDir(), "cassette.json")
+	require.NoError(s.T(), recorder.Save(cassettePath))
+
+	// When
+	cassette, err := qwaktest.LoadCassette(cassettePath)
+	require.NoError(s.T(), err)
+	config.HttpClient = qwaktest.NewReplayer(cassette)
+	replayingClient, err := qwak.NewRealTimeClient(config)
+	require.NoError(s.T(), err)
+	response, err := replayingClient.Predict(predictionRequest)
+
+	// Then
+	require.NoError(s.T(), err)
+	value, err := response.GetSinglePrediction().GetValueAsInt("churn")
+	s.Assert().NoError(err)
+	s.Assert().Equal(1, value)
+	s.Assert().Len(cassette.Interactions, 2)
+	s.Assert().NotContains(cassette.Interactions[0].Request.Body, "secret-key")
+	raw, err := os.ReadFile(cassettePath)
+	require.NoError(s.T(), err)
+	s.Assert().NotContains(string(raw), "qwaktest-token-")
+	s.Assert().Contains(cassette.Interactions[0].Response.Body, qwaktest.RedactedValue)
+	s.Assert().Greater(replayingClient.TokenInfo().RefreshIn, time.Duration(0), "the replayed token is not renewed on every call")
+}
+
+type countingCodec struct {
+	qwak.EncodingJSONCodec
+	marshalCalls   int
+	unmarshalCalls int
+}
+
+func (c *countingCodec) Marshal(value interface{}) ([]byte, error) {
+	c.marshalCalls++
+	return c.EncodingJSONCodec.Marshal(value)
+}
+
+func (c *countingCodec) Unmarshal(data []byte, value interface{}) error {
+	c.unmarshalCalls++
+	return c.EncodingJSONCodec.Unmarshal(data, value)
+}
+
+func (s *IntegrationTestSuite) TestPredictWithCustomCodec() {
+	// Given
+	server := qwaktest.NewServer(qwaktest.ServerOptions{}).
+		RespondWith("otf", map[string]interface{}{"churn": 1})
+	defer server.Close()
+
+	codec := &countingCodec{}
+	config := server.ClientConfig()
+	config.Codec = codec
+	client, err := qwak.NewRealTimeClient(config)
+	require.NoError(s.T(), err)
+
+	// When
+	response, err := client.Predict(qwak.NewPredictionRequest("otf").AddFeatureVector(
+		qwak.NewFeatureVector().WithFeature("State", "PPP"),
+	))
+
+	// Then
+	require.NoError(s.T(), err)
+	value, err := response.GetSinglePrediction().GetValueAsInt("churn")
+	s.Assert().NoError(err)
+	s.Assert().Equal(1, value)
+	s.Assert().Equal(1, codec.marshalCalls)
+	s.Assert().Equal(1, codec.unmarshalCalls)
+}
+
+func (s *IntegrationTestSuite) TestPredictStreaming() {
+	// Given
+	s.givenQwakClientWithMockedHttpClient()
+
+	s.HttpMock.On("Do", mock.MatchedBy(func(req *http.Request) bool {
+		return req.URL.String() == qwakhttp.DefaultAuthEndpointUri
+	})).Return(it.GetHttpReponse(it.GetAuthResponseWithLongExpiration(), 200), nil).Once()
+
+	streamResponse := it.GetHttpReponse("event: token\ndata: {\"token\":\"a\"}\n\n: keep-alive\n\ndata: [{\"token\":\"b\"}]\n\ndata: [DONE]\n\n", 200)
+	streamResponse.Header = http.Header{"Content-Type": []string{"text/event-stream"}}
+	s.HttpMock.On("Do", mock.MatchedBy(func(req *http.Request) bool {
+		return req.URL.String() == "https://models.donald.qwak.ai/v1/streaming/predict" &&
+			req.Header.Get("accept") == qwakhttp.StreamingAcceptHeader
+	})).Return(streamResponse, nil).Once()
+
+	// When
+	events, err := s.realTimeClient.PredictStreaming(context.Background(), qwak.NewPredictionRequest("streaming").AddFeatureVector(
+		qwak.NewFeatureVector().WithFeature("prompt", "hello"),
+	))
+	require.NoError(s.T(), err)
+
+	var tokens []string
+	var eventNames []string
+	for event := range events {
+		require.NoError(s.T(), event.Err)
+		require.Len(s.T(), event.Predictions, 1)
+		token, err := event.Predictions[0].GetValueAsString("token")
+		s.Assert().NoError(err)
+		tokens = append(tokens, token)
+		eventNames = append(eventNames, event.Event)
+	}
+
+	// Then
+	s.Assert().Equal([]string{"a", "b"}, tokens)
+	s.Assert().Equal([]string{"token", ""}, eventNames)
+	s.HttpMock.Mock.AssertExpectations(s.T())
+}
+
+func (s *IntegrationTestSuite) TestPredictStreamingOutlivesRequestTimeout() {
+	// Given a stream lasting longer than the request timeout, behind an unavailable primary url
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("content-type", "text/event-stream")
+		for idx := 0; idx < 3; idx++ {
+			fmt.Fprintf(w, "data: {\"token\":%d}\n\n", idx)
+			w.(http.Flusher).Flush()
+			time.Sleep(100 * time.Millisecond)
+		}
+	}))
+	defer server.Close()
+	unavailable := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusServiceUnavailable)
+	}))
+	defer unavailable.Close()
+
+	var lock sync.Mutex
+	var statusCodes []int
+	config := qwak.NewLocalClientConfig(unavailable.URL)
+	config.FailoverUrls = []string{server.URL}
+	config.RequestTimeout = 50 * time.Millisecond
+	config.RequestHooks.OnResponse = func(event qwakhttp.RequestEvent) {
+		lock.Lock()
+		defer lock.Unlock()
+		statusCodes = append(statusCodes, event.StatusCode)
+	}
+	client, err := qwak.NewRealTimeClient(config)
+	require.NoError(s.T(), err)
+	defer client.Close()
+	predictionRequest := qwak.NewPredictionRequest("streaming").AddFeatureVector(qwak.NewFeatureVector().WithFeature("x", 1))
+
+	// When
+	events, err := client.PredictStreaming(context.Background(), predictionRequest)
+	require.NoError(s.T(), err)
+
+	var tokens []int
+	for event := range events {
+		require.NoError(s.T(), event.Err)
+		token, err := event.Predictions[0].GetValueAsInt("token")
+		s.Assert().NoError(err)
+		tokens = append(tokens, token)
+	}
+
+	// Then
+	s.Assert().Equal([]int{0, 1, 2}, tokens)
+	lock.Lock()
+	s.Assert().Equal([]int{http.StatusServiceUnavailable, http.StatusOK}, statusCodes)
+	lock.Unlock()
+
+	// When the stream outlives its context
+	ctx, cancel := context.WithTimeout(context.Background(), 150*time.Millisecond)
+	defer cancel()
+	events, err = client.PredictStreaming(ctx, predictionRequest)
+	require.NoError(s.T(), err)
+
+	received := 0
+	for range events {
+		received++
+	}
+
+	// Then
+	s.Assert().Less(received, 3)
+}
+
+func (s *IntegrationTestSuite) TestConnectionStats() {
+	// Given
+	server := qwaktest.NewServer(qwaktest.ServerOptions{}).
+		RespondWith("otf", map[string]interface{}{"churn": 1})
+	defer server.Close()
+
+	config := server.ClientConfig()
+	config.CollectConnectionStats = true
+	client, err := qwak.NewRealTimeClient(config)
+	require.NoError(s.T(), err)
+
+	// When
+	predictionRequest := qwak.NewPredictionRequest("otf").AddFeatureVector(
+		qwak.NewFeatureVector().WithFeature("State", "PPP"),
+	)
+	_, err = client.Predict(predictionRequest)
+	require.NoError(s.T(), err)
+	_, err = client.Predict(predictionRequest)
+	require.NoError(s.T(), err)
+
+	// Then
+	stats := client.ConnectionStats()
+	s.Assert().Equal(int64(3), stats.ConnectionsObtained)
+	s.Assert().Equal(stats.ConnectionsObtained, stats.NewConnections+stats.ReusedConnections)
+	s.Assert().True(stats.ReuseRate() > 0)
+	s.Assert().Equal(int64(0), stats.InFlightRequests)
+}
+
+func (s *IntegrationTestSuite) TestPinEndpointWithDialHook() {
+	// Given
+	server := qwaktest.NewServer(qwaktest.ServerOptions{}).
+		RespondWith("otf", map[string]interface{}{"churn": 1})
+	defer server.Close()
+
+	serverUrl, err := url.Parse(server.URL())
+	require.NoError(s.T(), err)
+
+	var dialedAddresses []string
+	client, err := qwak.NewRealTimeClient(qwak.RealTimeClientConfig{
+		ApiKey:          "key",
+		Url:             "http://models.pinned.test:" + serverUrl.Port(),
+		AuthEndpointUrl: "http://auth.pinned.test:" + serverUrl.Port() + qwaktest.AuthEndpointPath,
+		Transport: qwakhttp.TransportOptions{
+			WrapDialContext: func(dial qwakhttp.DialContextFunc) qwakhttp.DialContextFunc {
+				return func(ctx context.Context, network, addr string) (net.Conn, error) {
+					dialedAddresses = append(dialedAddresses, addr)
+					return dial(ctx, network, serverUrl.Host)
+				}
+			},
+		},
+	})
+	require.NoError(s.T(), err)
+
+	// When
+	_, err = client.Predict(qwak.NewPredictionRequest("otf").AddFeatureVector(
+		qwak.NewFeatureVector().WithFeature("State", "PPP"),
+	))
+
+	// Then
+	require.NoError(s.T(), err)
+	s.Assert().Contains(dialedAddresses, "models.pinned.test:"+serverUrl.Port())
+	s.Assert().Contains(dialedAddresses, "auth.pinned.test:"+serverUrl.Port())
+}
+
+func (s *IntegrationTestSuite) TestPredictionCache() {
+	// Given
+	server := qwaktest.NewServer(qwaktest.ServerOptions{}).
+		RespondWith("otf", map[string]interface{}{"churn": 1})
+	defer server.Close()
+
+	config := server.ClientConfig()
+	config.Cache = qwak.NewMemoryPredictionCache(100, time.Minute)
+	client, err := qwak.NewRealTimeClient(config)
+	require.NoError(s.T(), err)
+
+	// When
+	for i := 0; i < 3; i++ {
+		response, err := client.Predict(qwak.NewPredictionRequest("otf").AddFeatureVector(
+			qwak.NewFeatureVector().WithFeature("State", "PPP"),
+		))
+		require.NoError(s.T(), err)
+		response.Release()
+	}
+	response, err := client.Predict(qwak.NewPredictionRequest("otf").AddFeatureVector(
+		qwak.NewFeatureVector().WithFeature("State", "NY"),
+	))
+
+	// Then
+	require.NoError(s.T(), err)
+	s.Assert().Len(response.GetPredictions(), 1)
+	s.Assert().Equal(2, server.PredictRequests("otf"))
+}
+
+func (s *IntegrationTestSuite) TestCoalesceIdenticalRequests() {
+	// Given
+	server := qwaktest.NewServer(qwaktest.ServerOptions{PredictLatency: 300 * time.Millisecond}).
+		RespondWith("otf", map[string]interface{}{"churn": 1})
+	defer server.Close()
+
+	config := server.ClientConfig()
+	config.CoalesceIdenticalRequests = true
+	client, err := qwak.NewRealTimeClient(config)
+	require.NoError(s.T(), err)
+
+	// When
+	var wg sync.WaitGroup
+	errs := make(chan error, 10)
+	for i := 0; i < 10; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			_, err := client.Predict(qwak.NewPredictionRequest("otf").AddFeatureVector(
+				qwak.NewFeatureVector().WithFeature("State", "PPP"),
+			))
+			errs <- err
+		}()
+	}
+	wg.Wait()
+	close(errs)
+
+	// Then
+	for err := range errs {
+		s.Assert().NoError(err)
+	}
+	s.Assert().Equal(1, server.PredictRequests("otf"))
+}
+
+func (s *IntegrationTestSuite) TestBatcher() {
+	// Given
+	server := qwaktest.NewServer(qwaktest.ServerOptions{}).
+		HandleModel("otf", func(rows []map[string]interface{}) ([]map[string]interface{}, error) {
+			outputs := make([]map[string]interface{}, len(rows))
+			for idx, row := range rows {
+				outputs[idx] = map[string]interface{}{"id": row["id"]}
+			}
+			return outputs, nil
+		})
+	defer server.Close()
+
+	client, err := qwak.NewRealTimeClient(server.ClientConfig())
+	require.NoError(s.T(), err)
+	batcher := qwak.NewBatcher(client, qwak.BatcherOptions{MaxRows: 8, MaxDelay: time.Second})
+	defer batcher.Close()
+
+	// When
+	var wg sync.WaitGroup
+	results := make([]int, 8)
+	for i := 0; i < 8; i++ {
+		wg.Add(1)
+		go func(id int) {
+			defer wg.Done()
+			response, err := batcher.Predict(qwak.NewPredictionRequest("otf").AddFeatureVector(
+				qwak.NewFeatureVector().WithFeature("id", id),
+			))
+			require.NoError(s.T(), err)
+			require.Len(s.T(), response.GetPredictions(), 1)
+			results[id], err = response.GetSinglePrediction().GetValueAsInt("id")
+			require.NoError(s.T(), err)
+		}(i)
+	}
+	wg.Wait()
+
+	// Then
+	s.Assert().Equal([]int{0, 1, 2, 3, 4, 5, 6, 7}, results)
+	s.Assert().Equal(1, server.PredictRequests("otf"))
+}
+
+func (s *IntegrationTestSuite) TestOnPredictionHook() {
+	// Given
+	server := qwaktest.NewServer(qwaktest.ServerOptions{}).
+		RespondWith("otf", map[string]interface{}{"churn": 1})
+	defer server.Close()
+
+	var metas []qwak.PredictionMeta
+	config := server.ClientConfig()
+	config.Cache = qwak.NewMemoryPredictionCache(10, time.Minute)
+	config.OnPrediction = func(request *qwak.PredictionRequest, response *qwak.PredictionResponse, meta qwak.PredictionMeta) {
+		metas = append(metas, meta)
+	}
+	client, err := qwak.NewRealTimeClient(config)
+	require.NoError(s.T(), err)
+
+	// When
+	for i := 0; i < 2; i++ {
+		_, err = client.Predict(qwak.NewPredictionRequest("otf").AddFeatureVector(
+			qwak.NewFeatureVector().WithFeature("State", "PPP"),
+		))
+		require.NoError(s.T(), err)
+	}
+
+	// Then
+	require.Len(s.T(), metas, 2)
+	s.Assert().Equal("otf", metas[0].ModelId)
+	s.Assert().Equal(1, metas[0].Attempts)
+	s.Assert().Equal(200, metas[0].StatusCode)
+	s.Assert().False(metas[0].Cached)
+	s.Assert().True(metas[0].Latency > 0)
+	s.Assert().True(metas[1].Cached)
+	s.Assert().Equal(0, metas[1].Attempts)
+}
+
+func (s *IntegrationTestSuite) givenQwakClientWithMockedHttpClient() {
+
+	client, err := qwak.NewRealTimeClient(qwak.RealTimeClientConfig{
+		ApiKey:      s.ApiKey,
+		Environment: "donald",
+		Context:     s.ctx,
+		HttpClient:  &s.HttpMock,
+	})
+
+	if err != nil {
+		s.Assert().Fail("client init failed", err)
+	}
+
+	s.realTimeClient = client
+}
+
+func (s *IntegrationTestSuite) givenQwakClientWithMockedHttpClientWithRetryPolicy() {
+
+	client, err := qwak.NewRealTimeClient(qwak.RealTimeClientConfig{
+		ApiKey:      s.ApiKey,
+		RetryPolicy: qwakhttp.BasicExponentialBackoffRetryPolicy(),
+		Environment: "donald",
+		Context:     s.ctx,
+		HttpClient:  &s.HttpMock,
+	})
+
+	if err != nil {
+		s.Assert().Fail("client init failed", err)
+	}
+
+	s.realTimeClient = client
+}
+
+func (s *IntegrationTestSuite) TestPredictEach() {
+	// Given
+	rows := make([]map[string]interface{}, 1000)
+	for idx := range rows {
+		rows[idx] = map[string]interface{}{"score": idx}
+	}
+	server := qwaktest.NewServer(qwaktest.ServerOptions{}).RespondWith("batch", rows...)
+	defer server.Close()
+
+	client, err := qwak.NewRealTimeClient(server.ClientConfig())
+	require.NoError(s.T(), err)
+	defer client.Close()
+	predictionRequest := qwak.NewPredictionRequest("batch").AddFeatureVector(qwak.NewFeatureVector().WithFeature("x", 1))
+
+	// When
+	sum := 0
+	err = client.PredictEach(context.Background(), predictionRequest, func(idx int, result *qwak.PredictionResult) error {
+		score, err := result.GetValueAsInt("score")
+		s.Require().Equal(idx, score)
+		sum += score
+		return err
+	})
+
+	// Then
+	require.NoError(s.T(), err)
+	s.Assert().Equal(999*1000/2, sum)
+
+	// When the callback fails
+	stop := errors.New("stop")
+	calls := 0
+	err = client.PredictEach(context.Background(), predictionRequest, func(idx int, result *qwak.PredictionResult) error {
+		calls++
+		if idx == 9 {
+			return stop
+		}
+		return nil
+	})
+
+	// Then
+	s.Assert().ErrorIs(err, stop)
+	s.Assert().Equal(10, calls)
+}
+
+func (s *IntegrationTestSuite) TestPredictEachOutlivesRequestTimeout() {
+	// Given a response lasting longer than the request timeout
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		fmt.Fprint(w, "[")
+		for idx := 0; idx < 3; idx++ {
+			if idx > 0 {
+				fmt.Fprint(w, ",")
+			}
+			fmt.Fprintf(w, "{\"score\":%d}", idx)
+			w.(http.Flusher).Flush()
+			time.Sleep(100 * time.Millisecond)
+		}
+		fmt.Fprint(w, "]")
+	}))
+	defer server.Close()
+
+	config := qwak.NewLocalClientConfig(server.URL)
+	config.RequestTimeout = 50 * time.Millisecond
+	client, err := qwak.NewRealTimeClient(config)
+	require.NoError(s.T(), err)
+	defer client.Close()
+
+	// When
+	var scores []int
+	err = client.PredictEach(context.Background(), qwak.NewPredictionRequest("batch").AddFeatureVector(qwak.NewFeatureVector().WithFeature("x", 1)),
+		func(idx int, result *qwak.PredictionResult) error {
+			score, err := result.GetValueAsInt("score")
+			scores = append(scores, score)
+			return err
+		})
+
+	// Then
+	require.NoError(s.T(), err)
+	s.Assert().Equal([]int{0, 1, 2}, scores)
+}
+
+func (s *IntegrationTestSuite) TestContextTokenOverride() {
+	// Given
+	var lock sync.Mutex
+	var authorizations []string
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		lock.Lock()
+		authorizations = append(authorizations, r.Header.Get("authorization"))
+		lock.Unlock()
+		_, _ = w.Write([]byte(`[{"churn": 1}]`))
+	}))
+	defer server.Close()
+
+	client, err := qwak.NewRealTimeClient(qwak.RealTimeClientConfig{
+		BearerToken: "client-token",
+		Url:         server.URL,
+		Cache:       qwak.NewMemoryPredictionCache(100, time.Minute),
+	})
+	require.NoError(s.T(), err)
+	predictionRequest := qwak.NewPredictionRequest("otf").AddFeatureVector(qwak.NewFeatureVector().WithFeature("State", "PPP"))
+
+	// When
+	_, err = client.PredictWithCtx(context.Background(), predictionRequest)
+	require.NoError(s.T(), err)
+	_, err = client.PredictWithCtx(qwak.WithToken(context.Background(), "tenant-token"), predictionRequest)
+	require.NoError(s.T(), err)
+
+	// Then the override token is sent, and the cached response of the client token is not served
+	s.Assert().Equal([]string{"Bearer client-token", "Bearer tenant-token"}, authorizations)
+}
+
+func (s *IntegrationTestSuite) TestSetApiKey() {
+	// Given
+	server := qwaktest.NewServer(qwaktest.ServerOptions{ApiKey: "rotated-key"}).
+		RespondWith("otf", map[string]interface{}{"churn": 1})
+	defer server.Close()
+
+	config := server.ClientConfig()
+	config.ApiKey = "revoked-key"
+	client, err := qwak.NewRealTimeClient(config)
+	require.NoError(s.T(), err)
+	defer client.Close()
+	predictionRequest := qwak.NewPredictionRequest("otf").AddFeatureVector(qwak.NewFeatureVector().WithFeature("State", "PPP"))
+
+	_, revokedErr := client.Predict(predictionRequest)
+
+	// When
+	err = client.SetApiKey("rotated-key")
+	require.NoError(s.T(), err)
+	_, err = client.Predict(predictionRequest)
+
+	// Then
+	s.Assert().Error(revokedErr)
+	require.NoError(s.T(), err)
+	s.Assert().Equal(2, server.AuthRequests())
+
+	bearerClient, err := qwak.NewRealTimeClient(qwak.RealTimeClientConfig{BearerToken: "token", Url: server.URL()})
+	require.NoError(s.T(), err)
+	s.Assert().Error(bearerClient.SetApiKey("rotated-key"))
+}
+
+func (s *IntegrationTestSuite) TestBackgroundContextBoundsTokenRenewals() {
+	// Given a token to be renewed in the background, whose renewals hang until cancelled
+	var authRequests int32
+	renewalCancelled := make(chan struct{})
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		_, _ = io.Copy(io.Discard, r.Body)
+		if r.URL.Path != "/auth" {
+			fmt.Fprint(w, `[{"churn":1}]`)
+			return
+		}
+		if atomic.AddInt32(&authRequests, 1) > 1 {
+			<-r.Context().Done()
+			close(renewalCancelled)
+			return
+		}
+		fmt.Fprintf(w, `{"accessToken":"token","expiredAt":%d}`, time.Now().Add(authentication.TokenExpirationBuffer+time.Hour).Unix())
+	}))
+	defer server.Close()
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	client, err := qwak.NewRealTimeClient(qwak.RealTimeClientConfig{
+		ApiKey:            "key",
+		Url:               server.URL,
+		AuthEndpointUrl:   server.URL + "/auth",
+		BackgroundContext: ctx,
+	})
+	require.NoError(s.T(), err)
+	defer client.Close()
+	predictionRequest := qwak.NewPredictionRequest("otf").AddFeatureVector(qwak.NewFeatureVector().WithFeature("State", "PPP"))
+
+	_, err = client.PredictWithCtx(context.Background(), predictionRequest)
+	require.NoError(s.T(), err)
+	_, err = client.PredictWithCtx(context.Background(), predictionRequest)
+	require.NoError(s.T(), err)
+	require.Eventually(s.T(), func() bool { return atomic.LoadInt32(&authRequests) == 2 }, time.Second, time.Millisecond)
+
+	// When
+	cancel()
+
+	// Then
+	select {
+	case <-renewalCancelled:
+	case <-time.After(time.Second):
+		s.Fail("the background renewal was not cancelled")
+	}
+}
+
+func (s *IntegrationTestSuite) TestCoalescedCallOutlivesFirstCaller() {
+	// Given
+	server := qwaktest.NewServer(qwaktest.ServerOptions{PredictLatency: 300 * time.Millisecond}).
+		RespondWith("otf", map[string]interface{}{"churn": 1})
+	defer server.Close()
+
+	config := server.ClientConfig()
+	config.CoalesceIdenticalRequests = true
+	client, err := qwak.NewRealTimeClient(config)
+	require.NoError(s.T(), err)
+	newRequest := func() *qwak.PredictionRequest {
+		return qwak.NewPredictionRequest("otf").AddFeatureVector(qwak.NewFeatureVector().WithFeature("State", "PPP"))
+	}
+
+	// When the caller which started the shared call cancels it
+	firstCtx, cancelFirst := context.WithCancel(context.Background())
+	firstErr := make(chan error, 1)
+	go func() {
+		_, err := client.PredictWithCtx(firstCtx, newRequest())
+		firstErr <- err
+	}()
+	require.Eventually(s.T(), func() bool { return server.PredictRequests("otf") == 1 }, time.Second, time.Millisecond)
+
+	secondResult := make(chan error, 1)
+	var secondResponse *qwak.PredictionResponse
+	go func() {
+		var err error
+		secondResponse, err = client.PredictWithCtx(context.Background(), newRequest())
+		secondResult <- err
+	}()
+	time.Sleep(50 * time.Millisecond)
+	cancelFirst()
+
+	// Then
+	s.Assert().ErrorIs(<-firstErr, context.Canceled)
+	require.NoError(s.T(), <-secondResult)
+	churn, err := secondResponse.GetSinglePrediction().GetValueAsInt("churn")
+	require.NoError(s.T(), err)
+	s.Assert().Equal(1, churn)
+	s.Assert().Equal(1, server.PredictRequests("otf"))
+}
+
+func (s *IntegrationTestSuite) TestCoalescedCallCancelledWithoutWaiters() {
+	// Given a model never answering
+	cancelled := make(chan struct{})
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		_, _ = io.Copy(io.Discard, r.Body)
+		<-r.Context().Done()
+		close(cancelled)
+	}))
+	defer server.Close()
+
+	config := qwak.NewLocalClientConfig(server.URL)
+	config.CoalesceIdenticalRequests = true
+	config.RequestTimeout = -1
+	client, err := qwak.NewRealTimeClient(config)
+	require.NoError(s.T(), err)
+	defer client.Close()
+	predictionRequest := qwak.NewPredictionRequest("otf").AddFeatureVector(qwak.NewFeatureVector().WithFeature("State", "PPP"))
+
+	// When every caller gives up
+	ctx, cancel := context.WithTimeout(context.Background(), 100*time.Millisecond)
+	defer cancel()
+	var wg sync.WaitGroup
+	for idx := 0; idx < 2; idx++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			_, err := client.PredictWithCtx(ctx, predictionRequest)
+			s.Assert().ErrorIs(err, context.DeadlineExceeded)
+		}()
+	}
+	wg.Wait()
+
+	// Then the shared call is cancelled
+	select {
+	case <-cancelled:
+	case <-time.After(time.Second):
+		s.Fail("the shared call was not cancelled")
+	}
 }
@@ -0,0 +1,26 @@
+package qwak
+
+import "context"
+
+type tokenKey struct{}
+
+// WithToken returns a context whose predictions are authorized with token instead of the token of the client
+// authenticator, e.g. to pass through the caller credentials in a multi-tenant proxy.
+// The token is sent as is, it is neither renewed nor validated by the client.
+// Such predictions bypass the prediction cache and the coalescing of identical requests
+func WithToken(ctx context.Context, token string) context.Context {
+	return context.WithValue(ctx, tokenKey{}, token)
+}
+
+// getToken returns the token overriding the authenticator in ctx, if any, or a token of the client authenticator
+func (c *RealTimeClient) getToken(ctx context.Context) (string, error) {
+	if token, ok := tokenFromContext(ctx); ok {
+		return token, nil
+	}
+	return c.tokenSource.GetToken(ctx)
+}
+
+func tokenFromContext(ctx context.Context) (string, bool) {
+	token, ok := ctx.Value(tokenKey{}).(string)
+	return token, ok
+}
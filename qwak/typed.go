@@ -0,0 +1,210 @@
+package qwak
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"reflect"
+	"strconv"
+	"strings"
+)
+
+// TypedClient predicts a single model with structs as the feature vectors and the results.
+// Struct fields are mapped to columns by their `qwak` tag, their `json` tag, or their name, in that order.
+// A "-" tag skips the field. Every result must include the columns of Resp fields, except those tagged
+// with the omitempty option, e.g. `qwak:"score,omitempty"`
+type TypedClient[Req any, Resp any] struct {
+	predictor      Predictor
+	modelId        string
+	requestFields  []structField
+	responseFields []structField
+}
+
+// NewTypedClient returns a TypedClient predicting the model using a RealTimeClient created with config
+func NewTypedClient[Req any, Resp any](config RealTimeClientConfig, modelId string) (*TypedClient[Req, Resp], error) {
+	client, err := NewRealTimeClient(config)
+	if err != nil {
+		return nil, err
+	}
+
+	return NewTypedClientWithPredictor[Req, Resp](client, modelId)
+}
+
+// NewTypedClientWithPredictor returns a TypedClient predicting the model using predictor, e.g. a Batcher.
+// The responses of predictor are released once decoded, so a predictor returning the same response to several
+// calls must return a shared one, such as a Filter view or a response from ParsePredictionResponse
+func NewTypedClientWithPredictor[Req any, Resp any](predictor Predictor, modelId string) (*TypedClient[Req, Resp], error) {
+	if len(modelId) == 0 {
+		return nil, fmt.Errorf("model id is missing")
+	}
+
+	requestFields, err := structFields(reflect.TypeOf((*Req)(nil)).Elem())
+	if err != nil {
+		return nil, fmt.Errorf("invalid request type: %w", err)
+	}
+
+	responseFields, err := structFields(reflect.TypeOf((*Resp)(nil)).Elem())
+	if err != nil {
+		return nil, fmt.Errorf("invalid response type: %w", err)
+	}
+
+	return &TypedClient[Req, Resp]{
+		predictor:      predictor,
+		modelId:        modelId,
+		requestFields:  requestFields,
+		responseFields: responseFields,
+	}, nil
+}
+
+// Predict sends the inputs as feature vectors, and returns the result of each input in order
+func (c *TypedClient[Req, Resp]) Predict(ctx context.Context, inputs []Req) ([]Resp, error) {
+	request := NewPredictionRequest(c.modelId)
+	for idx := range inputs {
+		request.AddFeatureVector(c.featureVectorOf(reflect.ValueOf(&inputs[idx]).Elem()))
+	}
+
+	for _, field := range c.responseFields {
+		if !field.omitEmpty {
+			request.ExpectColumns(field.column)
+		}
+	}
+
+	response, err := c.predictor.PredictWithCtx(ctx, request)
+	if err != nil {
+		return nil, err
+	}
+	// shared responses, e.g. cached ones or fixtures of fakes, are not released
+	defer response.Release()
+
+	if len(response.predictions) != len(inputs) {
+		return nil, fmt.Errorf("model returned %d results for %d inputs", len(response.predictions), len(inputs))
+	}
+
+	outputs := make([]Resp, len(inputs))
+	for idx, prediction := range response.predictions {
+		output := reflect.ValueOf(&outputs[idx]).Elem()
+		for _, field := range c.responseFields {
+			value, ok := prediction.valuesMap[field.column]
+			if !ok || value == nil {
+				continue
+			}
+			if err := assignValue(output.FieldByIndex(field.index), value); err != nil {
+				return nil, fmt.Errorf("failed to decode column '%s' of result %d: %w", field.column, idx, err)
+			}
+		}
+	}
+
+	return outputs, nil
+}
+
+func (c *TypedClient[Req, Resp]) featureVectorOf(input reflect.Value) *FeatureVector {
+	vector := NewFeatureVector()
+	for _, field := range c.requestFields {
+		value := input.FieldByIndex(field.index)
+		if field.omitEmpty && value.IsZero() {
+			continue
+		}
+		vector.WithFeature(field.column, value.Interface())
+	}
+	return vector
+}
+
+type structField struct {
+	index     []int
+	column    string
+	omitEmpty bool
+}
+
+// structFields returns the columns mapped to the exported fields of a struct type
+func structFields(structType reflect.Type) ([]structField, error) {
+	if structType.Kind() != reflect.Struct {
+		return nil, fmt.Errorf("%s is not a struct", structType)
+	}
+
+	var fields []structField
+	for _, field := range reflect.VisibleFields(structType) {
+		if !field.IsExported() || (field.Anonymous && field.Type.Kind() == reflect.Struct) {
+			continue
+		}
+
+		tag, ok := field.Tag.Lookup("qwak")
+		if !ok {
+			tag = field.Tag.Get("json")
+		}
+		if tag == "-" {
+			continue
+		}
+
+		name, options, _ := strings.Cut(tag, ",")
+		if name == "" {
+			name = field.Name
+		}
+
+		omitEmpty := false
+		for _, option := range strings.Split(options, ",") {
+			omitEmpty = omitEmpty || option == "omitempty"
+		}
+
+		fields = append(fields, structField{
+			index:     field.Index,
+			column:    name,
+			omitEmpty: omitEmpty,
+		})
+	}
+
+	return fields, nil
+}
+
+// assignValue sets a decoded json value to a field, converting numbers to the field type
+func assignValue(field reflect.Value, value interface{}) error {
+	if number, ok := value.(json.Number); ok && field.Type() != reflect.TypeOf(number) {
+		// integers are parsed from the text of the number to keep their precision
+		switch field.Kind() {
+		case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+			parsed, err := strconv.ParseInt(number.String(), 10, 64)
+			if err == nil {
+				field.SetInt(parsed)
+				return nil
+			}
+		case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+			parsed, err := strconv.ParseUint(number.String(), 10, 64)
+			if err == nil {
+				field.SetUint(parsed)
+				return nil
+			}
+		}
+
+		parsed, err := number.Float64()
+		if err != nil {
+			return err
+		}
+		value = parsed
+	}
+
+	if number, ok := value.(float64); ok {
+		switch field.Kind() {
+		case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+			field.SetInt(int64(number))
+			return nil
+		case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+			field.SetUint(uint64(number))
+			return nil
+		case reflect.Float32, reflect.Float64:
+			field.SetFloat(number)
+			return nil
+		}
+	}
+
+	decoded := reflect.ValueOf(value)
+	if decoded.Type().AssignableTo(field.Type()) {
+		field.Set(decoded)
+		return nil
+	}
+
+	// nested values such as arrays and objects are converted through their json representation
+	raw, err := json.Marshal(value)
+	if err != nil {
+		return err
+	}
+	return json.Unmarshal(raw, field.Addr().Interface())
+}
@@ -0,0 +1,25 @@
+package qwak
+
+import (
+	"reflect"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestStructFieldsOptions(t *testing.T) {
+	type prediction struct {
+		Churn  int      `qwak:"churn"`
+		Score  float64  `json:"score,string,omitempty"`
+		Labels []string `qwak:",omitempty"`
+	}
+
+	fields, err := structFields(reflect.TypeOf(prediction{}))
+
+	require.NoError(t, err)
+	require.Equal(t, []structField{
+		{index: []int{0}, column: "churn"},
+		{index: []int{1}, column: "score", omitEmpty: true},
+		{index: []int{2}, column: "Labels", omitEmpty: true},
+	}, fields)
+}
@@ -0,0 +1,146 @@
+package qwak
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"math"
+	"reflect"
+	"strings"
+)
+
+// ErrRequestTooLarge is returned when an encoded prediction request exceeds RealTimeClientConfig.MaxRequestBytes
+var ErrRequestTooLarge = errors.New("prediction request is too large")
+
+// RequestValidationError is returned by PredictionRequest.Validate, listing every problem found in the request
+type RequestValidationError struct {
+	ModelId  string
+	Problems []string
+}
+
+func (e *RequestValidationError) Error() string {
+	return fmt.Sprintf("invalid prediction request for model '%s': %s", e.ModelId, strings.Join(e.Problems, "; "))
+}
+
+// Validate verifies the request can be sent: the model id is set, there is at least one feature vector,
+// features are named and not set to conflicting values, values can be encoded as json, and each column
+// holds values of a single type.
+// It returns a RequestValidationError listing the problems found
+func (ir *PredictionRequest) Validate() error {
+	return ir.validateWith(defaultFeatureEncoding)
+}
+
+func (ir *PredictionRequest) validateWith(encoding *FeatureEncoding) error {
+	validationErr := &RequestValidationError{ModelId: ir.modelId}
+	if ir.modelId == "" {
+		validationErr.Problems = append(validationErr.Problems, "model id is missing")
+	}
+	if len(ir.featuresVector) == 0 {
+		validationErr.Problems = append(validationErr.Problems, "request has no feature vectors")
+	}
+
+	for idx, vector := range ir.featuresVector {
+		if vector == nil {
+			validationErr.Problems = append(validationErr.Problems, fmt.Sprintf("feature vector at index %d is nil", idx))
+		}
+	}
+	if len(validationErr.Problems) > 0 {
+		return validationErr
+	}
+
+	columnTypes := map[string]ColumnType{}
+	columnFirstIdx := map[string]int{}
+	for idx, vector := range encoding.prepare(ir).featuresVector {
+		validationErr.Problems = append(validationErr.Problems, encoding.duplicateFeatures(idx, vector)...)
+
+		for _, feature := range vector.features {
+			if feature.name == "" {
+				validationErr.Problems = append(validationErr.Problems, fmt.Sprintf("feature vector at index %d has a feature without name", idx))
+				continue
+			}
+
+			value := feature.value
+			if encoded, ok := encoding.encodeValue(value); ok {
+				value = encoded
+			}
+
+			if problem := unsupportedValue(value); problem != "" {
+				validationErr.Problems = append(validationErr.Problems,
+					fmt.Sprintf("feature '%s' at index %d %s", feature.name, idx, problem))
+				continue
+			}
+
+			valueType := columnTypeOf(value)
+			if valueType == AnyColumnType {
+				continue
+			}
+			if previousType, ok := columnTypes[feature.name]; ok && previousType != valueType {
+				validationErr.Problems = append(validationErr.Problems, fmt.Sprintf("column '%s' is a %s at index %d and a %s at index %d",
+					feature.name, previousType, columnFirstIdx[feature.name], valueType, idx))
+				continue
+			}
+			columnTypes[feature.name] = valueType
+			columnFirstIdx[feature.name] = idx
+		}
+	}
+
+	if len(validationErr.Problems) > 0 {
+		return validationErr
+	}
+	return nil
+}
+
+// unsupportedValue describes why a value can't be encoded as json, or returns an empty string
+func unsupportedValue(value interface{}) string {
+	if value == nil {
+		return ""
+	}
+	if _, ok := value.(json.Marshaler); ok {
+		return ""
+	}
+
+	reflected := reflect.ValueOf(value)
+	switch reflected.Kind() {
+	case reflect.Float32, reflect.Float64:
+		if math.IsNaN(reflected.Float()) || math.IsInf(reflected.Float(), 0) {
+			return "is not a finite number"
+		}
+	case reflect.Complex64, reflect.Complex128, reflect.Chan, reflect.Func, reflect.UnsafePointer:
+		return fmt.Sprintf("has unsupported type %T", value)
+	case reflect.Map:
+		if reflected.Type().Key().Kind() != reflect.String {
+			return fmt.Sprintf("has unsupported map key type %s", reflected.Type().Key())
+		}
+	}
+	return ""
+}
+
+// columnTypeOf returns the json type a value is encoded as, AnyColumnType when it can't be told
+func columnTypeOf(value interface{}) ColumnType {
+	if value == nil {
+		return AnyColumnType
+	}
+	if _, ok := value.(json.Marshaler); ok {
+		return AnyColumnType
+	}
+	if _, ok := value.(json.Number); ok {
+		return NumberColumnType
+	}
+
+	switch reflect.ValueOf(value).Kind() {
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64,
+		reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64,
+		reflect.Float32, reflect.Float64:
+		return NumberColumnType
+	case reflect.String:
+		return StringColumnType
+	case reflect.Bool:
+		return BoolColumnType
+	case reflect.Slice, reflect.Array:
+		return ArrayColumnType
+	case reflect.Map, reflect.Struct:
+		return ObjectColumnType
+	default:
+		return AnyColumnType
+	}
+}
@@ -0,0 +1,62 @@
+package qwak
+
+import (
+	"math"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestValidateRequest(t *testing.T) {
+	require.NoError(t, NewPredictionRequest("churn").AddFeatureVectors(
+		NewFeatureVector().WithFeature("age", 31).WithFeature("plan", "basic"),
+		NewFeatureVector().WithFeature("age", 42.5).WithFeature("plan", nil),
+	).Validate())
+
+	err := NewPredictionRequest("").Validate()
+	require.EqualError(t, err, "invalid prediction request for model '': model id is missing; request has no feature vectors")
+
+	err = NewPredictionRequest("churn").AddFeatureVectors(
+		NewFeatureVector().WithFeature("age", 31).WithFeature("", 1),
+		NewFeatureVector().WithFeature("age", "31").WithFeature("score", math.NaN()),
+		NewFeatureVector().WithFeature("callback", func() {}),
+	).Validate()
+
+	var validationErr *RequestValidationError
+	require.ErrorAs(t, err, &validationErr)
+	require.Equal(t, []string{
+		"feature vector at index 0 has a feature without name",
+		"column 'age' is a number at index 0 and a string at index 1",
+		"feature 'score' at index 1 is not a finite number",
+		"feature 'callback' at index 2 has unsupported type func()",
+	}, validationErr.Problems)
+}
+
+func TestDuplicateFeatures(t *testing.T) {
+	request := NewPredictionRequest("churn").AddFeatureVectors(
+		NewFeatureVector().WithFeature("age", 31).WithFeature("age", 31),
+		NewFeatureVector().WithFeature("age", 31).WithFeature("age", 32),
+	)
+
+	err := request.Validate()
+	var validationErr *RequestValidationError
+	require.ErrorAs(t, err, &validationErr)
+	require.Equal(t, []string{"feature 'age' at index 1 is set to conflicting values"}, validationErr.Problems)
+
+	body, err := encodeRequestBody(nil, defaultFeatureEncoding, request)
+	require.NoError(t, err)
+	require.Equal(t, `{"columns":["age"],"index":[0,1],"data":[[31],[32]]}`, string(body))
+
+	reject := &FeatureEncoding{DuplicateFeatures: DuplicateFeaturesReject}
+	_, err = encodeRequestBody(nil, reject, request)
+	require.ErrorAs(t, err, &validationErr)
+	require.Equal(t, []string{
+		"feature 'age' at index 0 is set more than once",
+		"feature 'age' at index 1 is set to conflicting values",
+	}, validationErr.Problems)
+
+	aliased := NewPredictionRequest("churn").AddFeatureVector(NewFeatureVector().WithFeature("age", 31).WithFeature("years", 30))
+	err = aliased.validateWith(&FeatureEncoding{ColumnAliases: map[string]string{"years": "age"}})
+	require.ErrorAs(t, err, &validationErr)
+	require.Contains(t, validationErr.Problems, "feature 'age' at index 0 is set to conflicting values")
+}
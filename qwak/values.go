@@ -0,0 +1,73 @@
+package qwak
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/url"
+	"sort"
+	"strconv"
+)
+
+// FeatureVectorFromValues returns a feature vector with a feature per query or form parameter, e.g. of
+// http.Request.Form, sorted by name. typeHints converts the parameters to their column type:
+// NumberColumnType to an int64 or a float64, BoolColumnType to a bool, ArrayColumnType to a []string of
+// every value of the parameter, and ObjectColumnType from json. Parameters without hint are strings,
+// or a []string when repeated. An error is returned when a parameter can't be converted
+func FeatureVectorFromValues(values url.Values, typeHints map[string]ColumnType) (*FeatureVector, error) {
+	names := make([]string, 0, len(values))
+	for name := range values {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	vector := NewFeatureVector()
+	for _, name := range names {
+		value, err := convertValues(values[name], typeHints[name])
+		if err != nil {
+			return nil, fmt.Errorf("invalid parameter '%s': %w", name, err)
+		}
+		vector.WithFeature(name, value)
+	}
+
+	return vector, nil
+}
+
+func convertValues(values []string, columnType ColumnType) (interface{}, error) {
+	if columnType == ArrayColumnType || (columnType == AnyColumnType && len(values) > 1) {
+		return values, nil
+	}
+
+	if len(values) == 0 {
+		return nil, nil
+	}
+	if len(values) > 1 {
+		return nil, fmt.Errorf("expected a single %s, got %d values", columnType, len(values))
+	}
+	value := values[0]
+
+	switch columnType {
+	case NumberColumnType:
+		if parsed, err := strconv.ParseInt(value, 10, 64); err == nil {
+			return parsed, nil
+		}
+		parsed, err := strconv.ParseFloat(value, 64)
+		if err != nil {
+			return nil, fmt.Errorf("'%s' is not a number", value)
+		}
+		return parsed, nil
+	case BoolColumnType:
+		parsed, err := strconv.ParseBool(value)
+		if err != nil {
+			return nil, fmt.Errorf("'%s' is not a bool", value)
+		}
+		return parsed, nil
+	case ObjectColumnType:
+		var parsed map[string]interface{}
+		if err := json.Unmarshal([]byte(value), &parsed); err != nil {
+			return nil, fmt.Errorf("'%s' is not a json object", value)
+		}
+		return parsed, nil
+	default:
+		return value, nil
+	}
+}
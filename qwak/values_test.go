@@ -0,0 +1,44 @@
+package qwak
+
+import (
+	"net/url"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestFeatureVectorFromValues(t *testing.T) {
+	values, err := url.ParseQuery("age=42&score=0.5&active=true&tags=a&tags=b&name=ann&address=%7B%22city%22%3A%22TLV%22%7D&colors=red")
+	require.NoError(t, err)
+
+	vector, err := FeatureVectorFromValues(values, map[string]ColumnType{
+		"age":     NumberColumnType,
+		"score":   NumberColumnType,
+		"active":  BoolColumnType,
+		"address": ObjectColumnType,
+		"colors":  ArrayColumnType,
+	})
+	require.NoError(t, err)
+
+	expected := map[string]interface{}{
+		"age":     int64(42),
+		"score":   0.5,
+		"active":  true,
+		"tags":    []string{"a", "b"},
+		"name":    "ann",
+		"address": map[string]interface{}{"city": "TLV"},
+		"colors":  []string{"red"},
+	}
+	for name, value := range expected {
+		actual, ok := vector.GetFeature(name)
+		require.True(t, ok, name)
+		require.Equal(t, value, actual, name)
+	}
+
+	_, err = FeatureVectorFromValues(url.Values{"age": {"old"}}, map[string]ColumnType{"age": NumberColumnType})
+	require.Error(t, err)
+	require.Contains(t, err.Error(), "'age'")
+
+	_, err = FeatureVectorFromValues(url.Values{"age": {"1", "2"}}, map[string]ColumnType{"age": NumberColumnType})
+	require.Error(t, err)
+}